@@ -2,16 +2,19 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 type Claims struct {
-	Role string `json:"role"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -22,12 +25,60 @@ const (
 	UserRoleContextKey contextKey = "userRole"
 )
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, string, error) {
+var (
+	ErrRevoked = errors.New("token has been revoked")
+	// ErrRevocationsStale means an admin token can't be trusted because the
+	// revocation list hasn't synced recently.
+	ErrRevocationsStale = errors.New("token revocation list is stale")
+)
+
+// Revocations reports access tokens revoked before they expire.
+type Revocations interface {
+	IsRevoked(jti, userID, sessionID string, issuedAt time.Time) bool
+	Fresh(maxAge time.Duration) bool
+}
+
+type VerifierSettings struct {
+	Issuer   string
+	Audience string
+	// MaxRevocationStaleness is how out of date the revocation list may be
+	// before admin tokens are refused
+	MaxRevocationStaleness time.Duration
+}
+
+// Verifier checks access tokens issued by user-service.
+type Verifier struct {
+	keys        *JWKS
+	revocations Revocations
+	settings    VerifierSettings
+}
+
+func NewVerifier(keys *JWKS, revocations Revocations, settings VerifierSettings) *Verifier {
+	return &Verifier{
+		keys:        keys,
+		revocations: revocations,
+		settings:    settings,
+	}
+}
+
+// ValidateJWT accepts only unrevoked EdDSA tokens signed by a published key,
+// carrying the expected issuer and audience, an expiry and a jti.
+func (v *Verifier) ValidateJWT(ctx context.Context, tokenString string) (uuid.UUID, string, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("missing kid header")
+		}
+		return v.keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(v.settings.Issuer),
+		jwt.WithAudience(v.settings.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
 		return uuid.Nil, "", err
 	}
@@ -45,6 +96,16 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, string, error) {
 		return uuid.Nil, "", fmt.Errorf("missing role in token")
 	}
 
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return uuid.Nil, "", fmt.Errorf("missing jti or iat in token")
+	}
+	if v.revocations.IsRevoked(claims.ID, claims.Subject, claims.SessionID, claims.IssuedAt.Time) {
+		return uuid.Nil, "", ErrRevoked
+	}
+	if claims.Role == "admin" && !v.revocations.Fresh(v.settings.MaxRevocationStaleness) {
+		return uuid.Nil, "", ErrRevocationsStale
+	}
+
 	return userID, claims.Role, nil
 }
 
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minRefetchInterval stops tokens with made-up key IDs from turning into a
+// flood of JWKS requests.
+const minRefetchInterval = 10 * time.Second
+
+type jwk struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+}
+
+// JWKS caches user-service's token verification keys. It refreshes them
+// periodically, and immediately when a token names a key it hasn't seen, so
+// rotated keys are picked up without a restart.
+type JWKS struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]ed25519.PublicKey
+	fetchedAt time.Time
+
+	fetchMu sync.Mutex
+
+	now func() time.Time
+}
+
+func NewJWKS(url string, interval time.Duration) *JWKS {
+	return &JWKS{
+		url:      url,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		interval: interval,
+		keys:     make(map[string]ed25519.PublicKey),
+		now:      time.Now,
+	}
+}
+
+// Run refreshes the keys every interval until ctx is cancelled. Failed
+// refreshes keep the previous keys.
+func (j *JWKS) Run(ctx context.Context) {
+	if err := j.Refresh(ctx); err != nil {
+		log.Printf("Failed to fetch JWKS: %v", err)
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh JWKS, keeping cached keys: %v", err)
+			}
+		}
+	}
+}
+
+// Key returns the public key for kid, refetching the set once if it is
+// unknown.
+func (j *JWKS) Key(ctx context.Context, kid string) (ed25519.PublicKey, error) {
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+
+	// Another request may have refreshed while we waited
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+	j.mu.RLock()
+	recent := j.now().Sub(j.fetchedAt) < minRefetchInterval
+	j.mu.RUnlock()
+	if !recent {
+		if err := j.fetch(ctx); err != nil {
+			return nil, err
+		}
+		if key, ok := j.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (j *JWKS) Refresh(ctx context.Context) error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+	return j.fetch(ctx)
+}
+
+func (j *JWKS) lookup(kid string) (ed25519.PublicKey, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// fetch downloads the key set. Callers must hold fetchMu.
+func (j *JWKS) fetch(ctx context.Context) error {
+	j.mu.Lock()
+	j.fetchedAt = j.now()
+	j.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("JWKS endpoint returned %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		// Skip keys we can't use rather than rejecting the whole set
+		if k.KeyType != "OKP" || k.Curve != "Ed25519" || k.Algorithm != "EdDSA" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		keys[k.KeyID] = ed25519.PublicKey(x)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS has no usable Ed25519 keys")
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	testIssuer   = "user-service"
+	testAudience = "api-gateway"
+)
+
+type testKey struct {
+	kid  string
+	priv ed25519.PrivateKey
+}
+
+func newTestKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return testKey{kid: kid, priv: priv}
+}
+
+// jwksServer serves whatever keys it currently holds and counts fetches
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    []jwk
+	fetches int
+}
+
+func (s *jwksServer) publish(keys ...testKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = s.keys[:0]
+	for _, k := range keys {
+		s.keys = append(s.keys, jwk{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(k.priv.Public().(ed25519.PublicKey)),
+			KeyID:     k.kid,
+			Algorithm: "EdDSA",
+		})
+	}
+}
+
+func (s *jwksServer) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": s.keys})
+}
+
+type noRevocations struct{}
+
+func (noRevocations) IsRevoked(jti, userID, sessionID string, issuedAt time.Time) bool {
+	return false
+}
+
+func (noRevocations) Fresh(maxAge time.Duration) bool { return true }
+
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newTestVerifier(t *testing.T, keys ...testKey) (*Verifier, *jwksServer, *testClock) {
+	t.Helper()
+	srv := &jwksServer{}
+	srv.publish(keys...)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	clock := &testClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	jwks := NewJWKS(ts.URL, time.Minute)
+	jwks.now = clock.now
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	settings := VerifierSettings{
+		Issuer:                 testIssuer,
+		Audience:               testAudience,
+		MaxRevocationStaleness: time.Minute,
+	}
+	return NewVerifier(jwks, noRevocations{}, settings), srv, clock
+}
+
+func testClaims(userID uuid.UUID, expiresIn time.Duration) Claims {
+	now := time.Now()
+	return Claims{
+		Role: "customer",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+	}
+}
+
+func signToken(t *testing.T, key testKey, claims Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = key.kid
+	ss, err := token.SignedString(key.priv)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return ss
+}
+
+func TestValidateJWT(t *testing.T) {
+	current := newTestKey(t, "current")
+	verifier, _, _ := newTestVerifier(t, current)
+	userID := uuid.New()
+
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(userID, time.Hour))
+	hmacToken.Header["kid"] = current.kid
+	// An attacker who knows the public key might try it as an HMAC secret
+	hmacSigned, err := hmacToken.SignedString([]byte(current.priv.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	noneToken := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims(userID, time.Hour))
+	noneToken.Header["kid"] = current.kid
+	noneSigned, err := noneToken.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	// Same kid as a published key, different private key
+	impostor := newTestKey(t, current.kid)
+
+	wrongIssuer := testClaims(userID, time.Hour)
+	wrongIssuer.Issuer = "someone-else"
+	wrongAudience := testClaims(userID, time.Hour)
+	wrongAudience.Audience = jwt.ClaimStrings{"cart-service"}
+	noExpiry := testClaims(userID, time.Hour)
+	noExpiry.ExpiresAt = nil
+	noRole := testClaims(userID, time.Hour)
+	noRole.Role = ""
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", signToken(t, current, testClaims(userID, time.Hour)), false},
+		{"expired", signToken(t, current, testClaims(userID, -time.Minute)), true},
+		{"HS256 with public key as secret", hmacSigned, true},
+		{"alg none", noneSigned, true},
+		{"signed by key not in set", signToken(t, impostor, testClaims(userID, time.Hour)), true},
+		{"unknown kid", signToken(t, newTestKey(t, "unknown"), testClaims(userID, time.Hour)), true},
+		{"missing kid", signToken(t, testKey{priv: current.priv}, testClaims(userID, time.Hour)), true},
+		{"wrong issuer", signToken(t, current, wrongIssuer), true},
+		{"wrong audience", signToken(t, current, wrongAudience), true},
+		{"no expiry", signToken(t, current, noExpiry), true},
+		{"no role", signToken(t, current, noRole), true},
+		{"garbage", "not.a.token", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotID, gotRole, err := verifier.ValidateJWT(context.Background(), tc.token)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ValidateJWT accepted the token")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if gotID != userID || gotRole != "customer" {
+				t.Errorf("got (%s, %q), want (%s, %q)", gotID, gotRole, userID, "customer")
+			}
+		})
+	}
+}
+
+func TestJWKSUnknownKidRefetches(t *testing.T) {
+	old := newTestKey(t, "old")
+	verifier, srv, clock := newTestVerifier(t, old)
+
+	// user-service starts signing with a new key before our next poll
+	next := newTestKey(t, "next")
+	srv.publish(old, next)
+	clock.advance(minRefetchInterval)
+
+	token := signToken(t, next, testClaims(uuid.New(), time.Hour))
+	if _, _, err := verifier.ValidateJWT(context.Background(), token); err != nil {
+		t.Fatalf("ValidateJWT with new kid: %v", err)
+	}
+	if got := srv.fetchCount(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+
+	// Known keys are served from the cache
+	if _, _, err := verifier.ValidateJWT(context.Background(), token); err != nil {
+		t.Fatalf("ValidateJWT again: %v", err)
+	}
+	if got := srv.fetchCount(); got != 2 {
+		t.Errorf("fetches after cached lookup = %d, want 2", got)
+	}
+}
+
+func TestJWKSUnknownKidRefetchIsRateLimited(t *testing.T) {
+	verifier, srv, clock := newTestVerifier(t, newTestKey(t, "current"))
+
+	clock.advance(minRefetchInterval - time.Second)
+	stranger := newTestKey(t, "made-up")
+	for i := 0; i < 5; i++ {
+		token := signToken(t, stranger, testClaims(uuid.New(), time.Hour))
+		if _, _, err := verifier.ValidateJWT(context.Background(), token); err == nil {
+			t.Fatalf("ValidateJWT accepted a token with an unknown kid")
+		}
+	}
+	if got := srv.fetchCount(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+
+	clock.advance(time.Second)
+	token := signToken(t, stranger, testClaims(uuid.New(), time.Hour))
+	if _, _, err := verifier.ValidateJWT(context.Background(), token); err == nil {
+		t.Fatalf("ValidateJWT accepted a token with an unknown kid")
+	}
+	if got := srv.fetchCount(); got != 2 {
+		t.Errorf("fetches after interval = %d, want 2", got)
+	}
+}
+
+func TestJWKSRotatedKey(t *testing.T) {
+	old := newTestKey(t, "old")
+	verifier, srv, _ := newTestVerifier(t, old)
+	oldToken := signToken(t, old, testClaims(uuid.New(), time.Hour))
+	if _, _, err := verifier.ValidateJWT(context.Background(), oldToken); err != nil {
+		t.Fatalf("ValidateJWT before rotation: %v", err)
+	}
+
+	next := newTestKey(t, "next")
+	srv.publish(next)
+	if err := verifier.keys.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	if _, _, err := verifier.ValidateJWT(context.Background(), oldToken); err == nil {
+		t.Errorf("ValidateJWT accepted a token signed by a retired key")
+	}
+	newToken := signToken(t, next, testClaims(uuid.New(), time.Hour))
+	if _, _, err := verifier.ValidateJWT(context.Background(), newToken); err != nil {
+		t.Errorf("ValidateJWT after rotation: %v", err)
+	}
+}
+
+func TestJWKSRefreshKeepsKeysOnFailure(t *testing.T) {
+	current := newTestKey(t, "current")
+	verifier, srv, _ := newTestVerifier(t, current)
+
+	// A set with nothing usable must not wipe the cache
+	srv.mu.Lock()
+	srv.keys = []jwk{{KeyType: "RSA", KeyID: "rsa", Algorithm: "RS256"}}
+	srv.mu.Unlock()
+	if err := verifier.keys.Refresh(context.Background()); err == nil {
+		t.Fatalf("Refresh accepted a set with no Ed25519 keys")
+	}
+
+	token := signToken(t, current, testClaims(uuid.New(), time.Hour))
+	if _, _, err := verifier.ValidateJWT(context.Background(), token); err != nil {
+		t.Errorf("ValidateJWT after failed refresh: %v", err)
+	}
+}
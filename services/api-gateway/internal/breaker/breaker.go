@@ -0,0 +1,173 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type Settings struct {
+	// FailureThreshold is the number of consecutive failures that trips the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probes through
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many probes must succeed before closing again
+	HalfOpenRequests int
+}
+
+type Snapshot struct {
+	Name                string     `json:"name"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker tracks the health of a single upstream.
+type Breaker struct {
+	name     string
+	settings Settings
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probes    int
+	successes int
+
+	now func() time.Time
+}
+
+func New(name string, settings Settings) *Breaker {
+	if settings.FailureThreshold < 1 {
+		settings.FailureThreshold = 1
+	}
+	if settings.HalfOpenRequests < 1 {
+		settings.HalfOpenRequests = 1
+	}
+	return &Breaker{
+		name:     name,
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// Allow reports whether a request may be sent. Every allowed request must be
+// followed by exactly one call to Success, Failure or Abandon.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.settings.OpenTimeout {
+			return ErrOpen
+		}
+		b.state = HalfOpen
+		b.probes = 0
+		b.successes = 0
+		fallthrough
+	case HalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return ErrOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		b.failures = 0
+	case HalfOpen:
+		b.successes++
+		if b.successes >= b.settings.HalfOpenRequests {
+			b.state = Closed
+			b.failures = 0
+		}
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	switch b.state {
+	case Closed:
+		if b.failures >= b.settings.FailureThreshold {
+			b.trip()
+		}
+	case HalfOpen:
+		b.trip()
+	}
+}
+
+// Abandon releases a half-open probe slot without judging the upstream, e.g.
+// when the client went away mid-request.
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// RetryAfter is how long until an open breaker lets probes through.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != Open {
+		return 0
+	}
+	return max(0, b.settings.OpenTimeout-b.now().Sub(b.openedAt))
+}
+
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := Snapshot{
+		Name:                b.name,
+		State:               b.state.String(),
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != Closed {
+		openedAt := b.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+	return snapshot
+}
+
+func (b *Breaker) trip() {
+	b.state = Open
+	b.openedAt = b.now()
+}
@@ -0,0 +1,166 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time { return c.t }
+
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(settings Settings) (*Breaker, *testClock) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := New("test", settings)
+	b.now = clock.now
+	return b, clock
+}
+
+func (b *Breaker) currentState() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// fail sends n requests that all fail
+func fail(t *testing.T, b *Breaker, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("failure %d: Allow: %v", i+1, err)
+		}
+		b.Failure()
+	}
+}
+
+func TestBreakerFailureThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		trips     int
+	}{
+		{name: "threshold of three", threshold: 3, trips: 3},
+		{name: "threshold of one", threshold: 1, trips: 1},
+		{name: "zero threshold trips on the first failure", threshold: 0, trips: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBreaker(Settings{FailureThreshold: tt.threshold, OpenTimeout: time.Minute})
+
+			fail(t, b, tt.trips-1)
+			if got := b.currentState(); got != Closed {
+				t.Fatalf("state after %d failures = %v, want closed", tt.trips-1, got)
+			}
+			fail(t, b, 1)
+			if got := b.currentState(); got != Open {
+				t.Fatalf("state after %d failures = %v, want open", tt.trips, got)
+			}
+			if err := b.Allow(); !errors.Is(err, ErrOpen) {
+				t.Fatalf("Allow on open breaker = %v, want ErrOpen", err)
+			}
+		})
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(Settings{FailureThreshold: 3, OpenTimeout: time.Minute})
+
+	fail(t, b, 2)
+	if err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	b.Success()
+	fail(t, b, 2)
+
+	if got := b.currentState(); got != Closed {
+		t.Fatalf("state = %v, want closed: failures must be consecutive", got)
+	}
+	if got := b.Snapshot().ConsecutiveFailures; got != 2 {
+		t.Errorf("ConsecutiveFailures = %d, want 2", got)
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	settings := Settings{FailureThreshold: 2, OpenTimeout: 10 * time.Second, HalfOpenRequests: 2}
+
+	t.Run("half-open probes close the breaker", func(t *testing.T) {
+		b, clock := newTestBreaker(settings)
+		fail(t, b, 2)
+
+		clock.advance(4 * time.Second)
+		if got := b.RetryAfter(); got != 6*time.Second {
+			t.Errorf("RetryAfter = %v, want 6s", got)
+		}
+		if err := b.Allow(); !errors.Is(err, ErrOpen) {
+			t.Fatalf("Allow before timeout = %v, want ErrOpen", err)
+		}
+
+		clock.advance(6 * time.Second)
+		for i := 0; i < settings.HalfOpenRequests; i++ {
+			if err := b.Allow(); err != nil {
+				t.Fatalf("probe %d: Allow: %v", i+1, err)
+			}
+		}
+		if got := b.currentState(); got != HalfOpen {
+			t.Fatalf("state = %v, want half-open", got)
+		}
+		if err := b.Allow(); !errors.Is(err, ErrOpen) {
+			t.Fatalf("Allow past the probe limit = %v, want ErrOpen", err)
+		}
+
+		b.Success()
+		if got := b.currentState(); got != HalfOpen {
+			t.Fatalf("state after one probe succeeded = %v, want half-open", got)
+		}
+		b.Success()
+		if got := b.currentState(); got != Closed {
+			t.Fatalf("state after all probes succeeded = %v, want closed", got)
+		}
+		if snapshot := b.Snapshot(); snapshot.ConsecutiveFailures != 0 || snapshot.OpenedAt != nil {
+			t.Errorf("snapshot = %+v, want no failures and no opened_at", snapshot)
+		}
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow on closed breaker: %v", err)
+		}
+	})
+
+	t.Run("failed probe reopens the breaker", func(t *testing.T) {
+		b, clock := newTestBreaker(settings)
+		fail(t, b, 2)
+
+		clock.advance(settings.OpenTimeout)
+		if err := b.Allow(); err != nil {
+			t.Fatalf("probe: Allow: %v", err)
+		}
+		b.Failure()
+		if got := b.currentState(); got != Open {
+			t.Fatalf("state after failed probe = %v, want open", got)
+		}
+		if got := b.RetryAfter(); got != settings.OpenTimeout {
+			t.Errorf("RetryAfter = %v, want a fresh %v", got, settings.OpenTimeout)
+		}
+	})
+
+	t.Run("abandoned probe frees its slot", func(t *testing.T) {
+		b, clock := newTestBreaker(Settings{FailureThreshold: 1, OpenTimeout: time.Second, HalfOpenRequests: 1})
+		fail(t, b, 1)
+
+		clock.advance(time.Second)
+		if err := b.Allow(); err != nil {
+			t.Fatalf("probe: Allow: %v", err)
+		}
+		b.Abandon()
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Allow after abandoned probe: %v", err)
+		}
+		if got := b.currentState(); got != HalfOpen {
+			t.Fatalf("state = %v, want half-open", got)
+		}
+	})
+}
@@ -1,9 +1,40 @@
 package config
 
+import "time"
+
 type Config struct {
 	UserServiceURL    string
 	ProductServiceURL string
 	CartServiceURL    string
-	OrderServiceURL		string
-	JWTSecret         string
+	OrderServiceURL   string
+	InternalAuthKey   string
+	RoutesFile        string
+
+	// Access token verification against user-service's published keys
+	JWKSURL             string
+	JWKSRefreshInterval time.Duration
+	JWTIssuer           string
+	JWTAudience         string
+
+	// Access token revocations polled from user-service
+	RevocationPollInterval time.Duration
+	RevocationMaxStaleness time.Duration
+
+	// Upstream transport tuning, shared by every service the gateway proxies to
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConnsPerHost   int
+	FlushInterval         time.Duration
+
+	// Per-upstream circuit breaker and retry policy for safe requests
+	BreakerFailureThreshold int
+	BreakerOpenTimeout      time.Duration
+	BreakerHalfOpenRequests int
+	RetryMaxAttempts        int
+	RetryBaseDelay          time.Duration
+	RetryMaxDelay           time.Duration
+
+	// ReadinessTimeout bounds the fan-out to upstream readiness endpoints
+	ReadinessTimeout time.Duration
 }
@@ -0,0 +1,99 @@
+package internalauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserRole  = "X-User-Role"
+	HeaderCaller    = "X-Internal-Caller"
+	HeaderTimestamp = "X-Internal-Timestamp"
+	HeaderSignature = "X-Internal-Signature"
+)
+
+// MaxBodySize bounds the request bodies that can be signed, since the
+// whole body is read to hash it.
+const MaxBodySize = 1 << 20
+
+var signedHeaders = []string{HeaderUserID, HeaderUserRole, HeaderCaller, HeaderTimestamp, HeaderSignature}
+
+// Signer signs requests to upstream services with a shared HMAC key.
+type Signer struct {
+	key    []byte
+	caller string
+	now    func() time.Time
+}
+
+func NewSigner(key, caller string) *Signer {
+	return &Signer{
+		key:    []byte(key),
+		caller: caller,
+		now:    time.Now,
+	}
+}
+
+// Sign replaces any identity headers on req with the given identity and an
+// HMAC over them, the method, path, query string and a hash of the body.
+// userID and role may be empty. The body is read and replaced, so req can
+// still be sent.
+func (s *Signer) Sign(req *http.Request, userID, role string) error {
+	for _, h := range signedHeaders {
+		req.Header.Del(h)
+	}
+	bodyHash, err := hashBody(req)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+
+	if userID != "" {
+		req.Header.Set(HeaderUserID, userID)
+	}
+	if role != "" {
+		req.Header.Set(HeaderUserRole, role)
+	}
+	req.Header.Set(HeaderCaller, s.caller)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, s.signature(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, bodyHash, s.caller, userID, role, timestamp))
+	return nil
+}
+
+// hashBody returns the hex SHA-256 of r's body, putting an unread copy of
+// the body back in its place.
+func hashBody(r *http.Request) (string, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	r.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("reading body to sign: %w", err)
+	}
+	if len(body) > MaxBodySize {
+		return "", errors.New("body too large to sign")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *Signer) signature(method, path, query, bodyHash, caller, userID, role, timestamp string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(strings.Join([]string{method, path, query, bodyHash, caller, userID, role, timestamp}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
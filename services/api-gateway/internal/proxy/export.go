@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/response"
+)
+
+// maxExportPartSize bounds how much of each service's response is buffered
+const maxExportPartSize = 32 << 20
+
+// exportPart is one file of a data export and the internal endpoint it is
+// read from.
+type exportPart struct {
+	file    string
+	service string
+	path    string
+}
+
+// handleExport gathers what every service holds about the caller into one
+// zip archive. All parts are fetched before anything is written, so a
+// service that fails gets the caller an error instead of a partial archive.
+func (rt *Router) handleExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		response.RespondWithError(w, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+	role, _ := auth.GetUserRoleFromContext(r.Context())
+	id := userID.String()
+
+	parts := []exportPart{
+		{file: "account.json", service: "user-service", path: "/internal/users/" + id + "/export"},
+		{file: "cart.json", service: "cart-service", path: "/internal/cart/" + id},
+		{file: "orders.json", service: "order-service", path: "/internal/users/" + id + "/orders"},
+	}
+
+	bodies := make([][]byte, len(parts))
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func(i int, part exportPart) {
+			defer wg.Done()
+			bodies[i], errs[i] = rt.fetchExportPart(r.Context(), part, id, role)
+		}(i, part)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			response.RespondWithError(w, http.StatusBadGateway, "Couldn't gather your data from "+parts[i].service, err)
+			return
+		}
+	}
+
+	exportedAt := time.Now().UTC()
+	files := make([]string, 0, len(parts))
+	for _, part := range parts {
+		files = append(files, part.file)
+	}
+	manifest, err := json.MarshalIndent(map[string]any{
+		"user_id":     id,
+		"exported_at": exportedAt,
+		"files":       files,
+	}, "", "  ")
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't build export", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, exportedAt.Format("20060102-150405")))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Headers are sent by now, so failures can only be logged
+	zw := zip.NewWriter(w)
+	if err := writeZipEntry(zw, "manifest.json", manifest, exportedAt); err != nil {
+		log.Printf("Failed writing data export for %s: %v", id, err)
+		return
+	}
+	for i, part := range parts {
+		if err := writeZipEntry(zw, part.file, bodies[i], exportedAt); err != nil {
+			log.Printf("Failed writing data export for %s: %v", id, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Failed writing data export for %s: %v", id, err)
+	}
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// fetchExportPart reads one part of an export and indents it for reading.
+func (rt *Router) fetchExportPart(ctx context.Context, part exportPart, userID, role string) ([]byte, error) {
+	resp, err := rt.upstreams[part.service].Get(ctx, part.path, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", part.path, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxExportPartSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxExportPartSize {
+		return nil, fmt.Errorf("%s returned more than %d bytes", part.path, maxExportPartSize)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return nil, fmt.Errorf("%s returned invalid JSON: %w", part.path, err)
+	}
+	return indented.Bytes(), nil
+}
@@ -2,88 +2,248 @@ package proxy
 
 import (
 	"context"
-	"io"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/breaker"
 	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/ratelimit"
 	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/response"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/routes"
 )
 
-func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
+// Router serves the gateway's routes from a route table that can be swapped
+// at runtime without dropping in-flight requests.
+type Router struct {
+	cfg       *config.Config
+	upstreams map[string]*Upstream
+	limiter   ratelimit.Store
+	verifier  *auth.Verifier
+	mux       atomic.Pointer[http.ServeMux]
+}
+
+func NewRouter(cfg *config.Config, verifier *auth.Verifier) (*Router, error) {
+	serviceURLs := map[string]string{
+		"user-service":    cfg.UserServiceURL,
+		"product-service": cfg.ProductServiceURL,
+		"cart-service":    cfg.CartServiceURL,
+		"order-service":   cfg.OrderServiceURL,
+	}
+
+	upstreams := make(map[string]*Upstream, len(serviceURLs))
+	for name, rawURL := range serviceURLs {
+		upstream, err := NewUpstream(name, rawURL, cfg)
+		if err != nil {
+			return nil, err
+		}
+		upstreams[name] = upstream
+	}
+
+	return &Router{
+		cfg:       cfg,
+		upstreams: upstreams,
+		limiter:   ratelimit.NewMemoryStore(),
+		verifier:  verifier,
+	}, nil
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux := rt.mux.Load()
+	if mux == nil {
+		response.RespondWithError(w, http.StatusServiceUnavailable, "Gateway routes not loaded", nil)
+		return
+	}
+	mux.ServeHTTP(w, r)
+}
+
+// Handler reports the handler and pattern the active routes would use for r.
+func (rt *Router) Handler(r *http.Request) (http.Handler, string) {
+	mux := rt.mux.Load()
+	if mux == nil {
+		return rt, ""
+	}
+	return mux.Handler(r)
+}
+
+// Reload reads the route table from cfg.RoutesFile and, if it is valid,
+// replaces the active routes. On error the previous routes stay in place.
+func (rt *Router) Reload() error {
+	services := make([]string, 0, len(rt.upstreams))
+	for name := range rt.upstreams {
+		services = append(services, name)
+	}
+
+	table, err := routes.Load(rt.cfg.RoutesFile, services)
+	if err != nil {
+		return err
+	}
+
+	mux, err := rt.buildMux(table)
+	if err != nil {
+		return err
+	}
+	rt.mux.Store(mux)
+	log.Printf("Loaded %d routes from %s", len(table.Routes), rt.cfg.RoutesFile)
+	return nil
+}
+
+func (rt *Router) buildMux(table *routes.Table) (mux *http.ServeMux, err error) {
+	// ServeMux panics on conflicting patterns; report that as a load error
+	defer func() {
+		if rec := recover(); rec != nil {
+			mux, err = nil, fmt.Errorf("registering routes: %v", rec)
+		}
+	}()
+
+	mux = http.NewServeMux()
+
 	// Health check
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "api-gateway"})
 	})
+	mux.HandleFunc("GET /health/live", func(w http.ResponseWriter, r *http.Request) {
+		response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "api-gateway"})
+	})
+	mux.HandleFunc("GET /health/ready", rt.handleReady)
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	// Circuit breaker state for on-call
+	mux.HandleFunc("GET /admin/upstreams", adminMiddleware(rt.verifier, rt.handleUpstreams))
 
-	// ===== USER SERVICE ROUTES =====
-	// Public user routes (no auth required)
-	mux.HandleFunc("POST /api/users", proxyHandler(cfg.UserServiceURL, "/api/users"))
-	mux.HandleFunc("POST /api/login", proxyHandler(cfg.UserServiceURL, "/api/login"))
-	mux.HandleFunc("POST /api/refresh", proxyHandler(cfg.UserServiceURL, "/api/refresh"))
-	mux.HandleFunc("POST /api/revoke", proxyHandler(cfg.UserServiceURL, "/api/revoke"))
-
-	// Protected user routes
-	mux.HandleFunc("GET /api/me", authMiddleware(cfg, proxyToUserService(cfg, "/internal/users/{userID}")))
-
-	// ===== PRODUCT SERVICE ROUTES =====
-	// Public product routes (no auth required)
-	mux.HandleFunc("GET /api/products", proxyHandler(cfg.ProductServiceURL, "/api/products"))
-	mux.HandleFunc("GET /api/products/{productID}", proxyWithPathHandler(cfg.ProductServiceURL, "/api/products/"))
-
-	// Admin product routes (auth + admin role required)
-	mux.HandleFunc("POST /admin/products", adminMiddleware(cfg, proxyHandler(cfg.ProductServiceURL, "/api/products")))
-	mux.HandleFunc("PATCH /admin/products/{productID}", adminMiddleware(cfg, proxyWithPathHandler(cfg.ProductServiceURL, "/api/products/")))
-	mux.HandleFunc("DELETE /admin/products/{productID}", adminMiddleware(cfg, proxyWithPathHandler(cfg.ProductServiceURL, "/api/products/")))
-
-	// Cart routes (all require auth, all need X-User-ID header)
-	mux.HandleFunc("GET /api/cart", authMiddleware(cfg, proxyWithUserIDHandler(cfg.CartServiceURL, "/api/cart")))
-	mux.HandleFunc("POST /api/cart/items", authMiddleware(cfg, proxyWithUserIDHandler(cfg.CartServiceURL, "/api/cart/items")))
-	mux.HandleFunc("PATCH /api/cart/items/{itemID}", authMiddleware(cfg, proxyWithUserIDAndPathHandler(cfg.CartServiceURL, "/api/cart/items/", "itemID")))
-	mux.HandleFunc("DELETE /api/cart/items/{itemID}", authMiddleware(cfg, proxyWithUserIDAndPathHandler(cfg.CartServiceURL, "/api/cart/items/", "itemID")))
-	mux.HandleFunc("DELETE /api/cart", authMiddleware(cfg, proxyWithUserIDHandler(cfg.CartServiceURL, "/api/cart")))
-
-	// Order routes (all require auth, all need X-User-ID header)
-	mux.HandleFunc("POST /api/orders", authMiddleware(cfg, proxyWithUserIDHandler(cfg.OrderServiceURL, "/api/orders")))
-	mux.HandleFunc("GET /api/orders", authMiddleware(cfg, proxyWithUserIDHandler(cfg.OrderServiceURL, "/api/orders")))
-	mux.HandleFunc("GET /api/orders/{orderID}", authMiddleware(cfg, proxyWithUserIDAndPathHandler(cfg.OrderServiceURL, "/api/orders/", "orderID")))
-	mux.HandleFunc("DELETE /api/orders/{orderID}", authMiddleware(cfg, proxyWithUserIDAndPathHandler(cfg.OrderServiceURL, "/api/orders/", "orderID")))
+	// Data export, gathered from every service
+	mux.HandleFunc("GET /api/me/export", authMiddleware(rt.verifier, rt.rateLimit(table, "account", rt.handleExport)))
+
+	for _, route := range table.Routes {
+		handler := routeHandler(rt.upstreams[route.Service], route)
+		if route.RateLimit != "" {
+			handler = rt.rateLimit(table, route.RateLimit, handler)
+		}
+		// Auth runs first so the limiter can key on the user ID
+		switch route.Auth {
+		case routes.AuthUser:
+			handler = authMiddleware(rt.verifier, handler)
+		case routes.AuthAdmin:
+			handler = adminMiddleware(rt.verifier, handler)
+		}
+		mux.HandleFunc(route.Method+" "+route.Pattern, handler)
+	}
+	return mux, nil
 }
 
-// proxyHandler creates a simple proxy handler for a target service
-func proxyHandler(targetURL, path string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		proxyRequest(w, r, targetURL+path)
+// rateLimit applies the table's named rate limit to handler, if the table
+// defines it.
+func (rt *Router) rateLimit(table *routes.Table, name string, handler http.HandlerFunc) http.HandlerFunc {
+	limit, ok := table.RateLimits[name]
+	if !ok {
+		return handler
 	}
+	return ratelimit.Middleware(rt.limiter, ratelimit.Policy{
+		Name:     name,
+		Requests: limit.Requests,
+		Window:   time.Duration(limit.Window),
+		Burst:    limit.Burst,
+	}, handler)
 }
 
-// proxyWithPathHandler proxies requests and preserves the path parameter
-func proxyWithPathHandler(targetURL, basePath string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Extract path value from the request
-		pathValue := r.PathValue("productID")
-		proxyRequest(w, r, targetURL+basePath+pathValue)
+func (rt *Router) handleUpstreams(w http.ResponseWriter, r *http.Request) {
+	type upstreamStatus struct {
+		breaker.Snapshot
+		URL string `json:"url"`
+	}
+
+	statuses := make([]upstreamStatus, 0, len(rt.upstreams))
+	for _, upstream := range rt.upstreams {
+		statuses = append(statuses, upstreamStatus{
+			Snapshot: upstream.Health(),
+			URL:      upstream.Target.String(),
+		})
 	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	response.RespondWithJSON(w, http.StatusOK, statuses)
 }
 
-// proxyToUserService handles the /api/me endpoint by getting user details
-func proxyToUserService(cfg *config.Config, pathPattern string) http.HandlerFunc {
+// handleReady probes every upstream's readiness endpoint concurrently and
+// answers 503 unless all of them are ready within cfg.ReadinessTimeout.
+func (rt *Router) handleReady(w http.ResponseWriter, r *http.Request) {
+	type serviceStatus struct {
+		Name      string `json:"name"`
+		Status    string `json:"status"`
+		LatencyMS int64  `json:"latency_ms"`
+		Error     string `json:"error,omitempty"`
+	}
+	type readiness struct {
+		Status   string          `json:"status"`
+		Services []serviceStatus `json:"services"`
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), rt.cfg.ReadinessTimeout)
+	defer cancel()
+
+	results := make(chan serviceStatus, len(rt.upstreams))
+	for _, upstream := range rt.upstreams {
+		go func(upstream *Upstream) {
+			start := time.Now()
+			err := upstream.Ready(ctx)
+			status := serviceStatus{
+				Name:      upstream.Name,
+				Status:    "ok",
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = "unavailable"
+				status.Error = err.Error()
+			}
+			results <- status
+		}(upstream)
+	}
+
+	result := readiness{Status: "ok"}
+	code := http.StatusOK
+	for range rt.upstreams {
+		status := <-results
+		if status.Status != "ok" {
+			result.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+		result.Services = append(result.Services, status)
+	}
+	sort.Slice(result.Services, func(i, j int) bool { return result.Services[i].Name < result.Services[j].Name })
+
+	response.RespondWithJSON(w, code, result)
+}
+
+// routeHandler proxies a matched request to the route's upstream path
+func routeHandler(upstream *Upstream, route routes.Route) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := auth.GetUserIDFromContext(r.Context())
-		if !ok {
-			response.RespondWithError(w, http.StatusUnauthorized, "Unauthorized", nil)
-			return
+		var userID, role string
+		if route.Auth != routes.AuthPublic {
+			id, ok := auth.GetUserIDFromContext(r.Context())
+			if !ok {
+				response.RespondWithError(w, http.StatusUnauthorized, "Unauthorized", nil)
+				return
+			}
+			userID = id.String()
+			role, _ = auth.GetUserRoleFromContext(r.Context())
 		}
 
-		targetURL := cfg.UserServiceURL + strings.Replace(pathPattern, "{userID}", userID.String(), 1)
-		proxyRequest(w, r, targetURL)
+		path := route.ExpandPath(r, userID)
+		if !route.InjectIdentity {
+			userID, role = "", ""
+		}
+		upstream.Forward(w, r, path, userID, role)
 	}
 }
 
 // authMiddleware validates JWT token and adds user info to context
-func authMiddleware(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+func authMiddleware(verifier *auth.Verifier, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token, err := auth.GetBearerToken(r.Header)
 		if err != nil {
@@ -91,7 +251,11 @@ func authMiddleware(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc
 			return
 		}
 
-		userID, role, err := auth.ValidateJWT(token, cfg.JWTSecret)
+		userID, role, err := verifier.ValidateJWT(r.Context(), token)
+		if errors.Is(err, auth.ErrRevocationsStale) {
+			response.RespondWithError(w, http.StatusServiceUnavailable, "token revocation status unavailable", err)
+			return
+		}
 		if err != nil {
 			response.RespondWithError(w, http.StatusUnauthorized, "invalid or expired token", err)
 			return
@@ -105,7 +269,7 @@ func authMiddleware(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc
 }
 
 // adminMiddleware validates JWT and checks for admin role
-func adminMiddleware(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+func adminMiddleware(verifier *auth.Verifier, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token, err := auth.GetBearerToken(r.Header)
 		if err != nil {
@@ -113,7 +277,11 @@ func adminMiddleware(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc
 			return
 		}
 
-		userID, role, err := auth.ValidateJWT(token, cfg.JWTSecret)
+		userID, role, err := verifier.ValidateJWT(r.Context(), token)
+		if errors.Is(err, auth.ErrRevocationsStale) {
+			response.RespondWithError(w, http.StatusServiceUnavailable, "token revocation status unavailable", err)
+			return
+		}
 		if err != nil {
 			response.RespondWithError(w, http.StatusUnauthorized, "invalid or expired token", err)
 			return
@@ -130,77 +298,3 @@ func adminMiddleware(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc
 		next(w, r.WithContext(ctx))
 	}
 }
-
-// proxyRequest forwards the request to the target URL
-func proxyRequest(w http.ResponseWriter, r *http.Request, targetURL string) {
-	client := &http.Client{}
-
-	// Create new request
-	proxyReq, err := http.NewRequest(r.Method, targetURL, r.Body)
-	if err != nil {
-		log.Printf("Error creating proxy request: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, "Internal server error", err)
-		return
-	}
-
-	// Copy headers
-	for key, values := range r.Header {
-		for _, value := range values {
-			proxyReq.Header.Add(key, value)
-		}
-	}
-
-	// Copy cookies
-	for _, cookie := range r.Cookies() {
-		proxyReq.AddCookie(cookie)
-	}
-
-	// Make the request
-	resp, err := client.Do(proxyReq)
-	if err != nil {
-		log.Printf("Error proxying request to %s: %v", targetURL, err)
-		response.RespondWithError(w, http.StatusBadGateway, "Service unavailable", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
-		}
-	}
-
-	// Copy status code
-	w.WriteHeader(resp.StatusCode)
-
-	// Copy response body
-	io.Copy(w, resp.Body)
-}
-
-func proxyWithUserIDHandler(targetURL, path string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := auth.GetUserIDFromContext(r.Context())
-		if !ok {
-			response.RespondWithError(w, http.StatusUnauthorized, "Unauthorized", nil)
-			return
-		}
-		// Add X-User-ID header
-		r.Header.Set("X-User-ID", userID.String())
-		proxyRequest(w, r, targetURL+path)
-	}
-}
-
-func proxyWithUserIDAndPathHandler(targetURL, basePath, paramName string, endPath ...string) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := auth.GetUserIDFromContext(r.Context())
-		if !ok {
-			response.RespondWithError(w, http.StatusUnauthorized, "Unauthorized", nil)
-			return
-		}
-		r.Header.Set("X-User-ID", userID.String())
-		pathValue := r.PathValue(paramName)
-		endValue := strings.Join(endPath, "")
-		proxyRequest(w, r, targetURL+basePath+pathValue+endValue)
-	}
-}
@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/config"
+)
+
+// readyServer answers /health/ready with status after delay
+func readyServer(t *testing.T, status int, delay time.Duration) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health/ready" {
+			t.Errorf("probe path = %s, want /health/ready", r.URL.Path)
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestHandleReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		product    int
+		orderDelay time.Duration
+		wantStatus int
+		wantDown   []string
+	}{
+		{
+			name:       "all ready",
+			product:    http.StatusOK,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "upstream not ready",
+			product:    http.StatusServiceUnavailable,
+			wantStatus: http.StatusServiceUnavailable,
+			wantDown:   []string{"product-service"},
+		},
+		{
+			name:       "upstream too slow",
+			product:    http.StatusOK,
+			orderDelay: time.Second,
+			wantStatus: http.StatusServiceUnavailable,
+			wantDown:   []string{"order-service"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				UserServiceURL:    readyServer(t, http.StatusOK, 0),
+				ProductServiceURL: readyServer(t, tt.product, 0),
+				CartServiceURL:    readyServer(t, http.StatusOK, 0),
+				OrderServiceURL:   readyServer(t, http.StatusOK, tt.orderDelay),
+				ReadinessTimeout:  100 * time.Millisecond,
+			}
+			rt, err := NewRouter(cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			rt.handleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Status   string `json:"status"`
+				Services []struct {
+					Name   string `json:"name"`
+					Status string `json:"status"`
+				} `json:"services"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{"cart-service", "order-service", "product-service", "user-service"}
+			if len(body.Services) != len(want) {
+				t.Fatalf("got %d services, want %d", len(body.Services), len(want))
+			}
+			down := map[string]bool{}
+			for _, name := range tt.wantDown {
+				down[name] = true
+			}
+			for i, s := range body.Services {
+				if s.Name != want[i] {
+					t.Errorf("service %d = %s, want %s: services must be sorted", i, s.Name, want[i])
+				}
+				wantStatus := "ok"
+				if down[s.Name] {
+					wantStatus = "unavailable"
+				}
+				if s.Status != wantStatus {
+					t.Errorf("%s status = %s, want %s", s.Name, s.Status, wantStatus)
+				}
+			}
+		})
+	}
+}
+
+// fakeRevocations revokes the listed jtis and is fresh or stale as told.
+type fakeRevocations struct {
+	revoked map[string]bool
+	fresh   bool
+}
+
+func (f fakeRevocations) IsRevoked(jti, userID, sessionID string, issuedAt time.Time) bool {
+	return f.revoked[jti]
+}
+
+func (f fakeRevocations) Fresh(maxAge time.Duration) bool { return f.fresh }
+
+// testVerifier returns a verifier trusting a fresh key, served as a JWKS,
+// and a function that signs tokens for role with it.
+func testVerifier(t *testing.T, revocations auth.Revocations) (*auth.Verifier, func(role, jti string) string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(pub),
+			"kid": "test",
+			"alg": "EdDSA",
+		}}})
+	}))
+	t.Cleanup(jwks.Close)
+
+	keys := auth.NewJWKS(jwks.URL, time.Minute)
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verifier := auth.NewVerifier(keys, revocations, auth.VerifierSettings{
+		Issuer:                 "user-service",
+		Audience:               "api-gateway",
+		MaxRevocationStaleness: time.Minute,
+	})
+
+	sign := func(role, jti string) string {
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, auth.Claims{
+			Role: role,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				Issuer:    "user-service",
+				Audience:  jwt.ClaimStrings{"api-gateway"},
+				Subject:   uuid.NewString(),
+				ID:        jti,
+			},
+		})
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	return verifier, sign
+}
+
+func TestAuthMiddlewareRevocations(t *testing.T) {
+	tests := []struct {
+		name       string
+		admin      bool
+		role       string
+		jti        string
+		fresh      bool
+		wantStatus int
+	}{
+		{name: "customer", role: "customer", jti: "ok", fresh: true, wantStatus: http.StatusOK},
+		{name: "revoked", role: "customer", jti: "revoked", fresh: true, wantStatus: http.StatusUnauthorized},
+		// Customers are let through on a stale list, admins are not: a
+		// revoked admin session has to stop working straight away
+		{name: "customer, stale list", role: "customer", jti: "ok", wantStatus: http.StatusOK},
+		{name: "admin, stale list", role: "admin", jti: "ok", wantStatus: http.StatusServiceUnavailable},
+		{name: "admin route", admin: true, role: "admin", jti: "ok", fresh: true, wantStatus: http.StatusOK},
+		{name: "admin route, revoked", admin: true, role: "admin", jti: "revoked", fresh: true, wantStatus: http.StatusUnauthorized},
+		{name: "admin route, stale list", admin: true, role: "admin", jti: "ok", wantStatus: http.StatusServiceUnavailable},
+		{name: "admin route, customer", admin: true, role: "customer", jti: "ok", fresh: true, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, sign := testVerifier(t, fakeRevocations{revoked: map[string]bool{"revoked": true}, fresh: tt.fresh})
+			next := func(w http.ResponseWriter, r *http.Request) {}
+			handler := authMiddleware(verifier, next)
+			if tt.admin {
+				handler = adminMiddleware(verifier, next)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+			r.Header.Set("Authorization", "Bearer "+sign(tt.role, tt.jti))
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/breaker"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// resilientTransport guards an upstream with a circuit breaker and retries
+// safe requests that fail with a network error or a gateway-class status.
+type resilientTransport struct {
+	base    http.RoundTripper
+	breaker *breaker.Breaker
+	retry   RetryPolicy
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isRetryable(req) {
+		attempts = max(1, t.retry.MaxAttempts)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := t.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if req.Context().Err() != nil {
+			// The client gave up; that says nothing about the upstream
+			t.breaker.Abandon()
+			return resp, err
+		}
+
+		failed := err != nil || isUpstreamFailure(resp.StatusCode)
+		if !failed {
+			t.breaker.Success()
+			return resp, nil
+		}
+		t.breaker.Failure()
+
+		if attempt >= attempts {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(t.backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff returns a delay with full jitter, growing exponentially per attempt
+func (t *resilientTransport) backoff(attempt int) time.Duration {
+	delay := t.retry.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.retry.MaxDelay {
+		delay = t.retry.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+func isRetryable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
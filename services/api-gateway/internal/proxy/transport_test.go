@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/breaker"
+)
+
+// roundTripFunc answers requests from a function and counts the calls
+type roundTripFunc struct {
+	calls int
+	fn    func(calls int) (*http.Response, error)
+}
+
+func (f *roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	return f.fn(f.calls)
+}
+
+func statusResponse(status int) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader("")),
+		Header:     make(http.Header),
+	}
+}
+
+func newTestTransport(base http.RoundTripper, maxAttempts int) *resilientTransport {
+	return &resilientTransport{
+		base:    base,
+		breaker: breaker.New("test", breaker.Settings{FailureThreshold: 100, OpenTimeout: time.Minute}),
+		retry: RetryPolicy{
+			MaxAttempts: maxAttempts,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    2 * time.Millisecond,
+		},
+	}
+}
+
+func TestResilientTransportRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		body      io.Reader
+		fn        func(calls int) (*http.Response, error)
+		wantCalls int
+	}{
+		{
+			name:      "GET retried on 503",
+			method:    http.MethodGet,
+			fn:        func(int) (*http.Response, error) { return statusResponse(http.StatusServiceUnavailable), nil },
+			wantCalls: 3,
+		},
+		{
+			name:      "HEAD retried on network error",
+			method:    http.MethodHead,
+			fn:        func(int) (*http.Response, error) { return nil, errors.New("connection refused") },
+			wantCalls: 3,
+		},
+		{
+			name:   "GET stops retrying once it succeeds",
+			method: http.MethodGet,
+			fn: func(calls int) (*http.Response, error) {
+				if calls < 2 {
+					return statusResponse(http.StatusBadGateway), nil
+				}
+				return statusResponse(http.StatusOK), nil
+			},
+			wantCalls: 2,
+		},
+		{
+			name:      "GET not retried on 500",
+			method:    http.MethodGet,
+			fn:        func(int) (*http.Response, error) { return statusResponse(http.StatusInternalServerError), nil },
+			wantCalls: 1,
+		},
+		{
+			name:      "POST not retried",
+			method:    http.MethodPost,
+			fn:        func(int) (*http.Response, error) { return statusResponse(http.StatusServiceUnavailable), nil },
+			wantCalls: 1,
+		},
+		{
+			name:      "PUT not retried",
+			method:    http.MethodPut,
+			fn:        func(int) (*http.Response, error) { return nil, errors.New("connection reset") },
+			wantCalls: 1,
+		},
+		{
+			name:      "DELETE not retried",
+			method:    http.MethodDelete,
+			fn:        func(int) (*http.Response, error) { return statusResponse(http.StatusGatewayTimeout), nil },
+			wantCalls: 1,
+		},
+		{
+			name:      "GET with a body not retried",
+			method:    http.MethodGet,
+			body:      strings.NewReader("{}"),
+			fn:        func(int) (*http.Response, error) { return statusResponse(http.StatusServiceUnavailable), nil },
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &roundTripFunc{fn: tt.fn}
+			transport := newTestTransport(base, 3)
+
+			req := httptest.NewRequest(tt.method, "http://upstream/api/products", tt.body)
+			resp, _ := transport.RoundTrip(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if base.calls != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", base.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResilientTransportReturnsLastFailure(t *testing.T) {
+	base := &roundTripFunc{fn: func(int) (*http.Response, error) {
+		return statusResponse(http.StatusServiceUnavailable), nil
+	}}
+	transport := newTestTransport(base, 2)
+
+	resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://upstream/", nil))
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestResilientTransportStopsWhenBreakerOpens(t *testing.T) {
+	base := &roundTripFunc{fn: func(int) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}}
+	transport := newTestTransport(base, 5)
+	transport.breaker = breaker.New("test", breaker.Settings{FailureThreshold: 2, OpenTimeout: time.Minute})
+
+	_, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, "http://upstream/", nil))
+	if !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("RoundTrip error = %v, want ErrOpen", err)
+	}
+	if base.calls != 2 {
+		t.Errorf("upstream calls = %d, want 2", base.calls)
+	}
+}
+
+func TestResilientTransportCanceledRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	base := &roundTripFunc{fn: func(int) (*http.Response, error) {
+		cancel()
+		return nil, context.Canceled
+	}}
+	transport := newTestTransport(base, 3)
+
+	req := httptest.NewRequest(http.MethodGet, "http://upstream/", nil).WithContext(ctx)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RoundTrip error = %v, want context.Canceled", err)
+	}
+	if base.calls != 1 {
+		t.Errorf("upstream calls = %d, want 1", base.calls)
+	}
+	if got := transport.breaker.Snapshot().ConsecutiveFailures; got != 0 {
+		t.Errorf("ConsecutiveFailures = %d, want 0", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		limit   time.Duration
+	}{
+		{
+			name:    "first attempt bounded by base delay",
+			policy:  RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second},
+			attempt: 1,
+			limit:   100 * time.Millisecond,
+		},
+		{
+			name:    "grows exponentially",
+			policy:  RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second},
+			attempt: 3,
+			limit:   400 * time.Millisecond,
+		},
+		{
+			name:    "capped by max delay",
+			policy:  RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second},
+			attempt: 6,
+			limit:   time.Second,
+		},
+		{
+			name:    "overflow capped by max delay",
+			policy:  RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second},
+			attempt: 64,
+			limit:   time.Second,
+		},
+		{
+			name:    "no max delay",
+			policy:  RetryPolicy{BaseDelay: 100 * time.Millisecond},
+			attempt: 1,
+			limit:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &resilientTransport{retry: tt.policy}
+			for i := 0; i < 1000; i++ {
+				delay := transport.backoff(tt.attempt)
+				if delay < 0 || delay > tt.limit {
+					t.Fatalf("backoff(%d) = %v, want within [0, %v]", tt.attempt, delay, tt.limit)
+				}
+				if delay > tt.policy.MaxDelay {
+					t.Fatalf("backoff(%d) = %v exceeds MaxDelay %v", tt.attempt, delay, tt.policy.MaxDelay)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/breaker"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/requestid"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/response"
+)
+
+type forwardKey struct{}
+
+// forward describes where and as whom a request is sent upstream
+type forward struct {
+	path   string
+	userID string
+	role   string
+}
+
+// Upstream is a backend service reached through its own pooled transport.
+type Upstream struct {
+	Name    string
+	Target  *url.URL
+	proxy   *httputil.ReverseProxy
+	signer  *internalauth.Signer
+	breaker *breaker.Breaker
+	// probe checks readiness over the pooled transport, bypassing the
+	// breaker so probes neither trip it nor get rejected by it
+	probe *http.Client
+	// client makes the gateway's own requests, with the same breaker and
+	// retries as proxied ones
+	client *http.Client
+}
+
+func NewUpstream(name, rawURL string, cfg *config.Config) (*Upstream, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s URL: %w", name, err)
+	}
+	if target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid %s URL: %q", name, rawURL)
+	}
+
+	u := &Upstream{
+		Name:   name,
+		Target: target,
+		signer: internalauth.NewSigner(cfg.InternalAuthKey, "api-gateway"),
+		breaker: breaker.New(name, breaker.Settings{
+			FailureThreshold: cfg.BreakerFailureThreshold,
+			OpenTimeout:      cfg.BreakerOpenTimeout,
+			HalfOpenRequests: cfg.BreakerHalfOpenRequests,
+		}),
+	}
+	transport := newTransport(cfg)
+	u.probe = &http.Client{Transport: transport}
+	resilient := &resilientTransport{
+		base:    metrics.Transport(name, transport),
+		breaker: u.breaker,
+		retry: RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+			MaxDelay:    cfg.RetryMaxDelay,
+		},
+	}
+	u.client = &http.Client{Transport: resilient}
+	u.proxy = &httputil.ReverseProxy{
+		Rewrite:       u.rewrite,
+		Transport:     resilient,
+		FlushInterval: cfg.FlushInterval,
+		ErrorHandler:  u.handleError,
+	}
+	return u, nil
+}
+
+func newTransport(cfg *config.Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// Forward sends r to path on the upstream, keeping the original query
+// string, and streams the response back. path must already be escaped.
+// userID and role are sent as signed identity headers when non-empty.
+func (u *Upstream) Forward(w http.ResponseWriter, r *http.Request, path, userID, role string) {
+	// The signature covers the body, so it is read up front where a client
+	// that sends too much can still be told so
+	if r.Body != nil && r.Body != http.NoBody {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, internalauth.MaxBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.RespondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large", err)
+			return
+		}
+		if err != nil {
+			response.RespondWithError(w, http.StatusBadRequest, "Couldn't read request body", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+
+	ctx := context.WithValue(r.Context(), forwardKey{}, forward{
+		path:   path,
+		userID: userID,
+		role:   role,
+	})
+	u.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// rewrite points the outbound request at the upstream. Hop-by-hop headers are
+// already stripped by httputil.ReverseProxy, and any client-supplied
+// X-Forwarded-* headers are replaced with values observed by the gateway.
+// Client-supplied identity headers are always replaced by the signer.
+func (u *Upstream) rewrite(pr *httputil.ProxyRequest) {
+	pr.SetURL(u.Target)
+	fwd, _ := pr.In.Context().Value(forwardKey{}).(forward)
+	if fwd.path != "" {
+		escaped := strings.TrimSuffix(u.Target.EscapedPath(), "/") + fwd.path
+		unescaped, err := url.PathUnescape(escaped)
+		if err != nil {
+			unescaped = escaped
+		}
+		pr.Out.URL.Path = unescaped
+		pr.Out.URL.RawPath = escaped
+	}
+	pr.SetXForwarded()
+	if err := u.signer.Sign(pr.Out, fwd.userID, fwd.role); err != nil {
+		// Forward has already read the body, so this shouldn't happen. The
+		// request goes out unsigned and the upstream rejects it.
+		log.Printf("Error signing %s %s for %s: %v", pr.Out.Method, pr.Out.URL.Path, u.Name, err)
+	}
+}
+
+// Get requests path from the upstream as userID and role, for responses the
+// gateway uses itself rather than passing through.
+func (u *Upstream) Get(ctx context.Context, path, userID, role string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.Target.JoinPath(path).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	if err := u.signer.Sign(req, userID, role); err != nil {
+		return nil, fmt.Errorf("signing request: %w", err)
+	}
+	return u.client.Do(req)
+}
+
+// Health reports the upstream's circuit breaker state
+func (u *Upstream) Health() breaker.Snapshot {
+	return u.breaker.Snapshot()
+}
+
+// Ready asks the upstream's readiness endpoint whether it can serve traffic.
+func (u *Upstream) Ready(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.Target.JoinPath("/health/ready").String(), nil)
+	if err != nil {
+		return err
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+
+	resp, err := u.probe.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("readiness returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (u *Upstream) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, breaker.ErrOpen) {
+		retryAfter := int(math.Ceil(u.breaker.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+		response.RespondWithError(w, http.StatusServiceUnavailable, u.Name+" is temporarily unavailable", nil)
+		return
+	}
+	log.Printf("Error proxying %s %s to %s: %v", r.Method, r.URL.Path, u.Name, err)
+	response.RespondWithError(w, http.StatusBadGateway, u.Name+" is unavailable", nil)
+}
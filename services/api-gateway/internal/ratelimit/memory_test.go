@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testClock is a manually advanced clock for MemoryStore.now
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time { return c.t }
+
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*MemoryStore, *testClock) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.now = clock.now
+	s.lastSweep = clock.t
+	return s, clock
+}
+
+func take(t *testing.T, s *MemoryStore, key string, policy Policy) Result {
+	t.Helper()
+	result, err := s.Take(context.Background(), key, policy)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	return result
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		allowed int
+	}{
+		{
+			name:    "burst caps the bucket",
+			policy:  Policy{Name: "test", Requests: 60, Window: time.Minute, Burst: 3},
+			allowed: 3,
+		},
+		{
+			name:    "no burst holds a full window",
+			policy:  Policy{Name: "test", Requests: 5, Window: time.Minute},
+			allowed: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestStore()
+			for i := 0; i < tt.allowed; i++ {
+				result := take(t, s, "k", tt.policy)
+				if !result.Allowed {
+					t.Fatalf("request %d denied, want allowed", i+1)
+				}
+				if result.Limit != tt.allowed {
+					t.Errorf("Limit = %d, want %d", result.Limit, tt.allowed)
+				}
+				if want := tt.allowed - i - 1; result.Remaining != want {
+					t.Errorf("request %d: Remaining = %d, want %d", i+1, result.Remaining, want)
+				}
+			}
+			if result := take(t, s, "k", tt.policy); result.Allowed {
+				t.Fatalf("request %d allowed past the burst", tt.allowed+1)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	// One token per second, three at most
+	policy := Policy{Name: "test", Requests: 60, Window: time.Minute, Burst: 3}
+	s, clock := newTestStore()
+
+	for i := 0; i < 3; i++ {
+		take(t, s, "k", policy)
+	}
+
+	clock.advance(1500 * time.Millisecond)
+	if result := take(t, s, "k", policy); !result.Allowed {
+		t.Fatal("denied after one token refilled")
+	}
+	result := take(t, s, "k", policy)
+	if result.Allowed {
+		t.Fatal("allowed with only half a token in the bucket")
+	}
+	if result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 500ms", result.RetryAfter)
+	}
+
+	// A long idle period refills to the burst and no further
+	clock.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if result := take(t, s, "k", policy); !result.Allowed {
+			t.Fatalf("request %d denied after a full refill", i+1)
+		}
+	}
+	if result := take(t, s, "k", policy); result.Allowed {
+		t.Fatal("bucket refilled past its burst")
+	}
+}
+
+func TestMemoryStoreRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     Policy
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{
+			name:       "one token per second",
+			policy:     Policy{Name: "test", Requests: 60, Window: time.Minute, Burst: 3},
+			retryAfter: time.Second,
+			reset:      3 * time.Second,
+		},
+		{
+			name:       "one token every six seconds",
+			policy:     Policy{Name: "test", Requests: 10, Window: time.Minute, Burst: 2},
+			retryAfter: 6 * time.Second,
+			reset:      12 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestStore()
+			for i := 0; i < tt.policy.Burst; i++ {
+				take(t, s, "k", tt.policy)
+			}
+			result := take(t, s, "k", tt.policy)
+			if result.Allowed {
+				t.Fatal("allowed on an empty bucket")
+			}
+			if result.RetryAfter != tt.retryAfter {
+				t.Errorf("RetryAfter = %v, want %v", result.RetryAfter, tt.retryAfter)
+			}
+			if result.Reset != tt.reset {
+				t.Errorf("Reset = %v, want %v", result.Reset, tt.reset)
+			}
+			if result.Remaining != 0 {
+				t.Errorf("Remaining = %d, want 0", result.Remaining)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	policy := Policy{Name: "test", Requests: 60, Window: time.Minute, Burst: 1}
+	s, _ := newTestStore()
+
+	if result := take(t, s, "a", policy); !result.Allowed {
+		t.Fatal("first request for a denied")
+	}
+	if result := take(t, s, "a", policy); result.Allowed {
+		t.Fatal("second request for a allowed")
+	}
+	if result := take(t, s, "b", policy); !result.Allowed {
+		t.Fatal("b denied by a's bucket")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	policy := Policy{Name: "test", Requests: 60, Window: time.Minute, Burst: 3}
+	s, clock := newTestStore()
+
+	take(t, s, "a", policy)
+	clock.advance(sweepInterval + time.Second)
+	take(t, s, "b", policy)
+
+	if _, ok := s.buckets["a"]; ok {
+		t.Error("refilled bucket survived the sweep")
+	}
+	if _, ok := s.buckets["b"]; !ok {
+		t.Error("bucket in use was swept")
+	}
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/response"
+)
+
+// Policy is a token bucket that refills Requests tokens every Window and
+// holds at most Burst tokens.
+type Policy struct {
+	Name     string
+	Requests int
+	Window   time.Duration
+	Burst    int
+}
+
+func (p Policy) capacity() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+	return float64(p.Requests)
+}
+
+// refillRate is the number of tokens added per second
+func (p Policy) refillRate() float64 {
+	return float64(p.Requests) / p.Window.Seconds()
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next token is available when denied
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must be safe for concurrent use
+// so they can be shared between gateway instances.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// Middleware limits requests per authenticated user, or per client IP for
+// anonymous requests. It must run after auth middleware to see the user ID.
+func Middleware(store Store, policy Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := policy.Name + ":" + clientKey(r)
+
+		result, err := store.Take(r.Context(), key, policy)
+		if err != nil {
+			// Fail open: a broken limiter should not take the API down
+			log.Printf("rate limiter error for %s: %v", key, err)
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			response.RespondWithError(w, http.StatusTooManyRequests, "rate limit exceeded", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if userID, ok := auth.GetUserIDFromContext(r.Context()); ok {
+		return "user:" + userID.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/auth"
+)
+
+type fakeStore struct {
+	result Result
+	err    error
+	keys   []string
+}
+
+func (f *fakeStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	f.keys = append(f.keys, key)
+	return f.result, f.err
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     Result
+		err        error
+		wantStatus int
+		wantCalled bool
+		wantHeader map[string]string
+	}{
+		{
+			name:       "allowed",
+			result:     Result{Allowed: true, Limit: 5, Remaining: 4, Reset: 1200 * time.Millisecond},
+			wantStatus: http.StatusOK,
+			wantCalled: true,
+			wantHeader: map[string]string{
+				"RateLimit-Limit":     "5",
+				"RateLimit-Remaining": "4",
+				"RateLimit-Reset":     "2",
+				"Retry-After":         "",
+			},
+		},
+		{
+			name:       "denied",
+			result:     Result{Limit: 5, Remaining: 0, Reset: 30 * time.Second, RetryAfter: 1500 * time.Millisecond},
+			wantStatus: http.StatusTooManyRequests,
+			wantHeader: map[string]string{
+				"RateLimit-Limit":     "5",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "30",
+				"Retry-After":         "2",
+			},
+		},
+		{
+			name:       "store error fails open",
+			err:        errors.New("store unavailable"),
+			wantStatus: http.StatusOK,
+			wantCalled: true,
+			wantHeader: map[string]string{
+				"RateLimit-Limit": "",
+				"Retry-After":     "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{result: tt.result, err: tt.err}
+			called := false
+			h := Middleware(store, Policy{Name: "login"}, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+			req.RemoteAddr = "203.0.113.7:51234"
+			rec := httptest.NewRecorder()
+			h(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantCalled {
+				t.Errorf("next called = %v, want %v", called, tt.wantCalled)
+			}
+			for name, want := range tt.wantHeader {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if len(store.keys) != 1 || store.keys[0] != "login:ip:203.0.113.7" {
+				t.Errorf("keys = %v, want [login:ip:203.0.113.7]", store.keys)
+			}
+		})
+	}
+}
+
+func TestMiddlewareWithMemoryStore(t *testing.T) {
+	policy := Policy{Name: "login", Requests: 10, Window: time.Minute, Burst: 2}
+	s, _ := newTestStore()
+	h := Middleware(s, policy, func(w http.ResponseWriter, r *http.Request) {})
+
+	codes := make([]int, 0, 3)
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		rec = httptest.NewRecorder()
+		h(rec, req)
+		codes = append(codes, rec.Code)
+	}
+
+	want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("status codes = %v, want %v", codes, want)
+		}
+	}
+	// One token every six seconds
+	if got := rec.Header().Get("Retry-After"); got != "6" {
+		t.Errorf("Retry-After = %q, want %q", got, "6")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	userID := uuid.MustParse("6f1c2b8e-3a4d-4e5f-8a9b-0c1d2e3f4a5b")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userID     *uuid.UUID
+		want       string
+	}{
+		{
+			name:       "anonymous uses the client IP",
+			remoteAddr: "198.51.100.4:40000",
+			want:       "ip:198.51.100.4",
+		},
+		{
+			name:       "IPv6 address",
+			remoteAddr: "[2001:db8::1]:40000",
+			want:       "ip:2001:db8::1",
+		},
+		{
+			name:       "address without a port",
+			remoteAddr: "198.51.100.4",
+			want:       "ip:198.51.100.4",
+		},
+		{
+			name:       "authenticated uses the user ID",
+			remoteAddr: "198.51.100.4:40000",
+			userID:     &userID,
+			want:       "user:" + userID.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.userID != nil {
+				req = req.WithContext(context.WithValue(req.Context(), auth.UserIDContextKey, *tt.userID))
+			}
+			if got := clientKey(req); got != tt.want {
+				t.Errorf("clientKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
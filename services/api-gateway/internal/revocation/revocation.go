@@ -0,0 +1,168 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/internalauth"
+)
+
+// overlap re-requests a little of the previous window on every poll so
+// revocations committed while the last poll was running aren't missed.
+const overlap = 5 * time.Second
+
+// List is a local copy of user-service's access token revocations, kept up
+// to date by polling its feed.
+type List struct {
+	url      string
+	client   *http.Client
+	signer   *internalauth.Signer
+	interval time.Duration
+
+	mu       sync.RWMutex
+	tokens   map[string]time.Time // jti -> token expiry
+	users    map[string]time.Time // user ID -> revoked_before
+	sessions map[string]time.Time // session ID -> expiry of its last token
+	asOf     time.Time
+	synced   time.Time
+
+	now func() time.Time
+}
+
+func NewList(userServiceURL string, signer *internalauth.Signer, interval time.Duration) *List {
+	return &List{
+		url:      userServiceURL + "/internal/revocations",
+		client:   &http.Client{Timeout: 5 * time.Second},
+		signer:   signer,
+		interval: interval,
+		tokens:   make(map[string]time.Time),
+		users:    make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Run polls the feed every interval until ctx is cancelled.
+func (l *List) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		if err := l.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to sync token revocations: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches revocations made since the last successful sync.
+func (l *List) Sync(ctx context.Context) error {
+	l.mu.RLock()
+	since := l.asOf
+	l.mu.RUnlock()
+
+	feedURL := l.url
+	if !since.IsZero() {
+		feedURL += "?since=" + url.QueryEscape(since.Add(-overlap).Format(time.RFC3339Nano))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return err
+	}
+	if err := l.signer.Sign(req, "", ""); err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("revocation feed returned %d", resp.StatusCode)
+	}
+
+	var feed struct {
+		AsOf   time.Time `json:"as_of"`
+		Tokens []struct {
+			JTI       string    `json:"jti"`
+			ExpiresAt time.Time `json:"expires_at"`
+		} `json:"tokens"`
+		Users []struct {
+			UserID        string    `json:"user_id"`
+			RevokedBefore time.Time `json:"revoked_before"`
+		} `json:"users"`
+		Sessions []struct {
+			SessionID string    `json:"session_id"`
+			ExpiresAt time.Time `json:"expires_at"`
+		} `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return fmt.Errorf("decoding revocation feed: %w", err)
+	}
+
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, t := range feed.Tokens {
+		l.tokens[t.JTI] = t.ExpiresAt
+	}
+	for _, u := range feed.Users {
+		// iat has whole seconds, so compare at that granularity
+		revokedBefore := u.RevokedBefore.Truncate(time.Second)
+		if revokedBefore.After(l.users[u.UserID]) {
+			l.users[u.UserID] = revokedBefore
+		}
+	}
+	for _, s := range feed.Sessions {
+		l.sessions[s.SessionID] = s.ExpiresAt
+	}
+	// Revoked tokens that have expired would be rejected anyway
+	for jti, expiresAt := range l.tokens {
+		if now.After(expiresAt) {
+			delete(l.tokens, jti)
+		}
+	}
+	for id, expiresAt := range l.sessions {
+		if now.After(expiresAt) {
+			delete(l.sessions, id)
+		}
+	}
+	l.asOf = feed.AsOf
+	l.synced = now
+	return nil
+}
+
+// IsRevoked reports whether the token with jti, issued to userID at
+// issuedAt under sessionID, has been revoked.
+func (l *List) IsRevoked(jti, userID, sessionID string, issuedAt time.Time) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.tokens[jti]; ok {
+		return true
+	}
+	if _, ok := l.sessions[sessionID]; ok && sessionID != "" {
+		return true
+	}
+	revokedBefore, ok := l.users[userID]
+	return ok && issuedAt.Before(revokedBefore)
+}
+
+// Fresh reports whether the list synced successfully within maxAge.
+func (l *List) Fresh(maxAge time.Duration) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return !l.synced.IsZero() && l.now().Sub(l.synced) <= maxAge
+}
@@ -0,0 +1,208 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/internalauth"
+)
+
+// feedServer plays user-service's revocation feed, answering each poll with
+// the next of its responses and recording the since it was asked for.
+type feedServer struct {
+	mu        sync.Mutex
+	responses []any
+	status    int
+	since     []string
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/internal/revocations" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get(internalauth.HeaderSignature) == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.since = append(s.since, r.URL.Query().Get("since"))
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	json.NewEncoder(w).Encode(s.responses[0])
+	s.responses = s.responses[1:]
+}
+
+func (s *feedServer) fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+type feed struct {
+	AsOf     time.Time     `json:"as_of"`
+	Tokens   []feedToken   `json:"tokens"`
+	Users    []feedUser    `json:"users"`
+	Sessions []feedSession `json:"sessions"`
+}
+
+type feedToken struct {
+	JTI       string    `json:"jti"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type feedUser struct {
+	UserID        string    `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+type feedSession struct {
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newTestList(t *testing.T, srv *feedServer, now *time.Time) *List {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	l := NewList(ts.URL, internalauth.NewSigner("test-key", "api-gateway"), time.Second)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestSync(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	// user-service's clock, which the gateway's must not be mixed with
+	asOf := now.Add(-time.Minute)
+
+	srv := &feedServer{responses: []any{
+		feed{
+			AsOf:     asOf,
+			Tokens:   []feedToken{{JTI: "jti-1", UserID: "user-1", ExpiresAt: now.Add(time.Hour)}},
+			Users:    []feedUser{{UserID: "user-2", RevokedBefore: now.Add(-time.Hour + 500*time.Millisecond)}},
+			Sessions: []feedSession{{SessionID: "session-1", UserID: "user-3", ExpiresAt: now.Add(30 * time.Minute)}},
+		},
+		feed{
+			AsOf:  asOf.Add(2 * time.Second),
+			Users: []feedUser{{UserID: "user-2", RevokedBefore: now.Add(-2 * time.Hour)}},
+		},
+	}}
+	l := newTestList(t, srv, &now)
+
+	if l.Fresh(time.Hour) {
+		t.Error("Fresh before the first sync")
+	}
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if !l.Fresh(time.Second) {
+		t.Error("not Fresh right after a sync")
+	}
+
+	issued := now.Add(-time.Hour)
+	tests := []struct {
+		name      string
+		jti       string
+		userID    string
+		sessionID string
+		issuedAt  time.Time
+		want      bool
+	}{
+		{name: "revoked token", jti: "jti-1", userID: "user-1", issuedAt: issued, want: true},
+		{name: "another token of that user", jti: "jti-9", userID: "user-1", issuedAt: issued},
+		{name: "user revoked, issued before", jti: "jti-2", userID: "user-2", issuedAt: issued.Add(-time.Second), want: true},
+		// iat has whole seconds, so a token from the revocation's second
+		// is not caught by it
+		{name: "user revoked, issued that second", jti: "jti-3", userID: "user-2", issuedAt: issued},
+		{name: "user revoked, issued after", jti: "jti-4", userID: "user-2", issuedAt: issued.Add(time.Second)},
+		{name: "ended session", jti: "jti-5", userID: "user-3", sessionID: "session-1", issuedAt: issued, want: true},
+		{name: "other session", jti: "jti-6", userID: "user-3", sessionID: "session-2", issuedAt: issued},
+		{name: "no session", jti: "jti-7", userID: "user-4", issuedAt: issued},
+	}
+	check := func(t *testing.T) {
+		for _, tt := range tests {
+			if got := l.IsRevoked(tt.jti, tt.userID, tt.sessionID, tt.issuedAt); got != tt.want {
+				t.Errorf("%s: IsRevoked = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+	check(t)
+
+	// The next poll asks from user-service's as_of, less the overlap, and an
+	// older user revocation arriving again doesn't roll the cutoff back
+	now = now.Add(2 * time.Second)
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	wantSince := []string{"", asOf.Add(-overlap).Format(time.RFC3339Nano)}
+	if len(srv.since) != 2 || srv.since[0] != wantSince[0] || srv.since[1] != wantSince[1] {
+		t.Errorf("polled since %q, want %q", srv.since, wantSince)
+	}
+	check(t)
+}
+
+func TestSyncForgetsExpired(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := &feedServer{responses: []any{
+		feed{
+			AsOf:     now,
+			Tokens:   []feedToken{{JTI: "jti-1", UserID: "user-1", ExpiresAt: now.Add(time.Minute)}},
+			Sessions: []feedSession{{SessionID: "session-1", UserID: "user-1", ExpiresAt: now.Add(time.Minute)}},
+		},
+		feed{AsOf: now.Add(2 * time.Minute)},
+	}}
+	l := newTestList(t, srv, &now)
+
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(l.tokens) != 0 || len(l.sessions) != 0 {
+		t.Errorf("kept expired revocations: tokens %v, sessions %v", l.tokens, l.sessions)
+	}
+}
+
+func TestSyncFailure(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := &feedServer{responses: []any{
+		feed{AsOf: now, Tokens: []feedToken{{JTI: "jti-1", UserID: "user-1", ExpiresAt: now.Add(time.Hour)}}},
+	}}
+	l := newTestList(t, srv, &now)
+
+	if err := l.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	srv.fail(http.StatusInternalServerError)
+	now = now.Add(time.Minute)
+	if err := l.Sync(context.Background()); err == nil {
+		t.Fatal("Sync succeeded against a failing feed")
+	}
+	// What was learned is kept, but the list goes stale and the next poll
+	// still asks from the last good as_of
+	if !l.IsRevoked("jti-1", "user-1", "", now) {
+		t.Error("forgot a revocation after a failed sync")
+	}
+	if l.Fresh(30 * time.Second) {
+		t.Error("still Fresh a minute after the last good sync")
+	}
+	if !l.Fresh(2 * time.Minute) {
+		t.Error("not Fresh within maxAge of the last good sync")
+	}
+	if l.asOf != now.Add(-time.Minute) {
+		t.Errorf("as_of = %v after a failed sync, want it unchanged", l.asOf)
+	}
+}
@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type AuthLevel string
+
+const (
+	AuthPublic AuthLevel = "public"
+	AuthUser   AuthLevel = "user"
+	AuthAdmin  AuthLevel = "admin"
+)
+
+// UserIDParam is replaced in upstream paths with the authenticated user's ID.
+const UserIDParam = "auth.userID"
+
+type Route struct {
+	Method         string    `json:"method"`
+	Pattern        string    `json:"pattern"`
+	Service        string    `json:"service"`
+	UpstreamPath   string    `json:"upstream_path"`
+	Auth           AuthLevel `json:"auth"`
+	InjectIdentity bool      `json:"inject_identity"`
+	RateLimit      string    `json:"rate_limit"`
+}
+
+// RateLimit is a named token bucket policy that routes refer to by name.
+type RateLimit struct {
+	Requests int      `json:"requests"`
+	Window   Duration `json:"window"`
+	Burst    int      `json:"burst"`
+}
+
+type Table struct {
+	RateLimits map[string]RateLimit `json:"rate_limits"`
+	Routes     []Route              `json:"routes"`
+}
+
+// Duration decodes JSON strings such as "1m" or "500ms".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Load reads a route table from disk and validates it against the known
+// upstream services.
+func Load(path string, services []string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening route table: %w", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+
+	var table Table
+	if err := decoder.Decode(&table); err != nil {
+		return nil, fmt.Errorf("decoding route table %s: %w", path, err)
+	}
+
+	if err := table.Validate(services); err != nil {
+		return nil, fmt.Errorf("invalid route table %s: %w", path, err)
+	}
+	return &table, nil
+}
+
+func (t *Table) Validate(services []string) error {
+	if len(t.Routes) == 0 {
+		return errors.New("no routes defined")
+	}
+
+	known := make(map[string]bool, len(services))
+	for _, s := range services {
+		known[s] = true
+	}
+
+	var errs []error
+	for name, limit := range t.RateLimits {
+		if limit.Requests <= 0 || limit.Window <= 0 || limit.Burst < 0 {
+			errs = append(errs, fmt.Errorf("rate limit %q: requests and window must be positive and burst non-negative", name))
+		}
+	}
+
+	seen := make(map[string]bool, len(t.Routes))
+	for i, route := range t.Routes {
+		if route.RateLimit != "" {
+			if _, ok := t.RateLimits[route.RateLimit]; !ok {
+				errs = append(errs, fmt.Errorf("route %d (%s %s): unknown rate limit %q", i, route.Method, route.Pattern, route.RateLimit))
+			}
+		}
+		if err := route.validate(known); err != nil {
+			errs = append(errs, fmt.Errorf("route %d (%s %s): %w", i, route.Method, route.Pattern, err))
+			continue
+		}
+		key := route.Method + " " + route.Pattern
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("route %d: duplicate route %s", i, key))
+		}
+		seen[key] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (r Route) validate(services map[string]bool) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return fmt.Errorf("unsupported method %q", r.Method)
+	}
+
+	if !strings.HasPrefix(r.Pattern, "/") {
+		return errors.New("pattern must start with /")
+	}
+	if !strings.HasPrefix(r.UpstreamPath, "/") {
+		return errors.New("upstream_path must start with /")
+	}
+	if !services[r.Service] {
+		return fmt.Errorf("unknown service %q", r.Service)
+	}
+
+	switch r.Auth {
+	case AuthPublic, AuthUser, AuthAdmin:
+	default:
+		return fmt.Errorf("auth must be one of public, user or admin, got %q", r.Auth)
+	}
+	if r.InjectIdentity && r.Auth == AuthPublic {
+		return errors.New("inject_identity requires an authenticated route")
+	}
+
+	wildcards, err := patternWildcards(r.Pattern)
+	if err != nil {
+		return err
+	}
+	params, err := templateParams(r.UpstreamPath)
+	if err != nil {
+		return err
+	}
+	for _, param := range params {
+		if param == UserIDParam {
+			if r.Auth == AuthPublic {
+				return fmt.Errorf("{%s} requires an authenticated route", UserIDParam)
+			}
+			continue
+		}
+		if !wildcards[param] {
+			return fmt.Errorf("upstream_path parameter {%s} is not in pattern", param)
+		}
+	}
+	return nil
+}
+
+// ExpandPath fills in the upstream path template for a matched request.
+func (r Route) ExpandPath(req *http.Request, userID string) string {
+	var b strings.Builder
+	rest := r.UpstreamPath
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			b.WriteString(rest)
+			return b.String()
+		}
+		end := strings.IndexByte(rest[start:], '}') + start
+		b.WriteString(rest[:start])
+
+		param := rest[start+1 : end]
+		if param == UserIDParam {
+			b.WriteString(userID)
+		} else {
+			b.WriteString(url.PathEscape(req.PathValue(param)))
+		}
+		rest = rest[end+1:]
+	}
+}
+
+func patternWildcards(pattern string) (map[string]bool, error) {
+	params, err := templateParams(pattern)
+	if err != nil {
+		return nil, err
+	}
+	wildcards := make(map[string]bool, len(params))
+	for _, p := range params {
+		wildcards[strings.TrimSuffix(p, "...")] = true
+	}
+	return wildcards, nil
+}
+
+func templateParams(path string) ([]string, error) {
+	var params []string
+	rest := path
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			return params, nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in %q", path)
+		}
+		name := rest[start+1 : start+end]
+		if name == "" {
+			return nil, fmt.Errorf("empty parameter in %q", path)
+		}
+		params = append(params, name)
+		rest = rest[start+end+1:]
+	}
+}
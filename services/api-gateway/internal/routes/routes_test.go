@@ -0,0 +1,326 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testServices = []string{"user-service", "product-service"}
+
+func validRoute() Route {
+	return Route{
+		Method:       http.MethodGet,
+		Pattern:      "/api/products/{productID}",
+		Service:      "product-service",
+		UpstreamPath: "/api/products/{productID}",
+		Auth:         AuthPublic,
+		RateLimit:    "catalog",
+	}
+}
+
+func TestTableValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  func() []Route
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			routes: func() []Route { return []Route{validRoute()} },
+		},
+		{
+			name: "unknown service",
+			routes: func() []Route {
+				r := validRoute()
+				r.Service = "inventory-service"
+				return []Route{r}
+			},
+			wantErr: `unknown service "inventory-service"`,
+		},
+		{
+			name: "bad auth",
+			routes: func() []Route {
+				r := validRoute()
+				r.Auth = "staff"
+				return []Route{r}
+			},
+			wantErr: `auth must be one of public, user or admin, got "staff"`,
+		},
+		{
+			name: "missing auth",
+			routes: func() []Route {
+				r := validRoute()
+				r.Auth = ""
+				return []Route{r}
+			},
+			wantErr: `auth must be one of public, user or admin, got ""`,
+		},
+		{
+			name: "undefined rate limit",
+			routes: func() []Route {
+				r := validRoute()
+				r.RateLimit = "search"
+				return []Route{r}
+			},
+			wantErr: `unknown rate limit "search"`,
+		},
+		{
+			name: "duplicate method and pattern",
+			routes: func() []Route {
+				r := validRoute()
+				other := validRoute()
+				other.UpstreamPath = "/api/v2/products/{productID}"
+				return []Route{r, other}
+			},
+			wantErr: "route 1: duplicate route GET /api/products/{productID}",
+		},
+		{
+			name: "same pattern with another method",
+			routes: func() []Route {
+				r := validRoute()
+				other := validRoute()
+				other.Method = http.MethodDelete
+				other.Auth = AuthAdmin
+				return []Route{r, other}
+			},
+		},
+		{
+			name: "unsupported method",
+			routes: func() []Route {
+				r := validRoute()
+				r.Method = "FETCH"
+				return []Route{r}
+			},
+			wantErr: `unsupported method "FETCH"`,
+		},
+		{
+			name: "upstream parameter missing from pattern",
+			routes: func() []Route {
+				r := validRoute()
+				r.UpstreamPath = "/api/products/{id}"
+				return []Route{r}
+			},
+			wantErr: "upstream_path parameter {id} is not in pattern",
+		},
+		{
+			name: "user ID on a public route",
+			routes: func() []Route {
+				r := validRoute()
+				r.UpstreamPath = "/api/users/{auth.userID}"
+				return []Route{r}
+			},
+			wantErr: "{auth.userID} requires an authenticated route",
+		},
+		{
+			name: "user ID on an authenticated route",
+			routes: func() []Route {
+				r := validRoute()
+				r.Pattern = "/api/users/me"
+				r.UpstreamPath = "/api/users/{auth.userID}"
+				r.Service = "user-service"
+				r.Auth = AuthUser
+				return []Route{r}
+			},
+		},
+		{
+			name: "inject identity on a public route",
+			routes: func() []Route {
+				r := validRoute()
+				r.InjectIdentity = true
+				return []Route{r}
+			},
+			wantErr: "inject_identity requires an authenticated route",
+		},
+		{
+			name: "unclosed parameter",
+			routes: func() []Route {
+				r := validRoute()
+				r.UpstreamPath = "/api/products/{productID"
+				return []Route{r}
+			},
+			wantErr: "unclosed {",
+		},
+		{
+			name:    "no routes",
+			routes:  func() []Route { return nil },
+			wantErr: "no routes defined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := Table{
+				RateLimits: map[string]RateLimit{
+					"catalog": {Requests: 300, Window: Duration(time.Minute), Burst: 60},
+				},
+				Routes: tt.routes(),
+			}
+			err := table.Validate(testServices)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate: want error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate: error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTableValidateRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   RateLimit
+		wantErr bool
+	}{
+		{name: "valid", limit: RateLimit{Requests: 10, Window: Duration(time.Minute), Burst: 5}},
+		{name: "no burst", limit: RateLimit{Requests: 10, Window: Duration(time.Minute)}},
+		{name: "zero requests", limit: RateLimit{Window: Duration(time.Minute)}, wantErr: true},
+		{name: "zero window", limit: RateLimit{Requests: 10}, wantErr: true},
+		{name: "negative burst", limit: RateLimit{Requests: 10, Window: Duration(time.Minute), Burst: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := Table{
+				RateLimits: map[string]RateLimit{"catalog": tt.limit},
+				Routes:     []Route{validRoute()},
+			}
+			err := table.Validate(testServices)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			json: `{
+				"rate_limits": {"catalog": {"requests": 300, "window": "1m", "burst": 60}},
+				"routes": [{"method": "GET", "pattern": "/api/products", "service": "product-service", "upstream_path": "/api/products", "auth": "public", "rate_limit": "catalog"}]
+			}`,
+		},
+		{
+			name:    "unknown field",
+			json:    `{"routes": [{"method": "GET", "pattern": "/api/products", "service": "product-service", "upstream_path": "/api/products", "auth": "public", "timeout": "1s"}]}`,
+			wantErr: `unknown field "timeout"`,
+		},
+		{
+			name:    "bad window",
+			json:    `{"rate_limits": {"catalog": {"requests": 300, "window": "soon"}}, "routes": []}`,
+			wantErr: "decoding route table",
+		},
+		{
+			name:    "invalid route",
+			json:    `{"routes": [{"method": "GET", "pattern": "/api/products", "service": "search-service", "upstream_path": "/api/products", "auth": "public"}]}`,
+			wantErr: `unknown service "search-service"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "routes.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			table, err := Load(path, testServices)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load: unexpected error: %v", err)
+				}
+				if got := time.Duration(table.RateLimits["catalog"].Window); got != time.Minute {
+					t.Errorf("window = %v, want 1m", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestLoadRouteTable checks the route table the gateway ships with.
+func TestLoadRouteTable(t *testing.T) {
+	services := []string{"user-service", "product-service", "cart-service", "order-service"}
+	if _, err := Load("../../routes.json", services); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		upstreamPath string
+		pathValues   map[string]string
+		userID       string
+		want         string
+	}{
+		{
+			name:         "static",
+			upstreamPath: "/api/products",
+			want:         "/api/products",
+		},
+		{
+			name:         "user ID",
+			upstreamPath: "/api/users/{auth.userID}/cart",
+			userID:       "6f1c2b8e-3a4d-4e5f-8a9b-0c1d2e3f4a5b",
+			want:         "/api/users/6f1c2b8e-3a4d-4e5f-8a9b-0c1d2e3f4a5b/cart",
+		},
+		{
+			name:         "path value",
+			upstreamPath: "/api/products/{productID}",
+			pathValues:   map[string]string{"productID": "42"},
+			want:         "/api/products/42",
+		},
+		{
+			name:         "user ID and path value",
+			upstreamPath: "/api/carts/{auth.userID}/items/{itemID}",
+			pathValues:   map[string]string{"itemID": "7"},
+			userID:       "u1",
+			want:         "/api/carts/u1/items/7",
+		},
+		{
+			name:         "path value is escaped",
+			upstreamPath: "/api/categories/{slug}",
+			pathValues:   map[string]string{"slug": "tea/coffee"},
+			want:         "/api/categories/tea%2Fcoffee",
+		},
+		{
+			name:         "parameter at the start of a segment",
+			upstreamPath: "/{productID}.json",
+			pathValues:   map[string]string{"productID": "42"},
+			want:         "/42.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.pathValues {
+				req.SetPathValue(name, value)
+			}
+			route := Route{UpstreamPath: tt.upstreamPath}
+			if got := route.ExpandPath(req, tt.userID); got != tt.want {
+				t.Errorf("ExpandPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
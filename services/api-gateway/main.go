@@ -1,19 +1,33 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/auth"
 	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/metrics"
 	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/proxy"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/requestid"
+	"github.com/herodragmon/scalable-ecommerce/services/api-gateway/internal/revocation"
 )
 
 func main() {
 	godotenv.Load()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -24,23 +38,105 @@ func main() {
 		ProductServiceURL: getEnvOrDefault("PRODUCT_SERVICE_URL", "http://localhost:8082"),
 		CartServiceURL:    getEnvOrDefault("CART_SERVICE_URL", "http://localhost:8083"),
 		OrderServiceURL:   getEnvOrDefault("ORDER_SERVICE_URL", "http://localhost:8084"),
-		JWTSecret:         os.Getenv("SECRET_KEY"),
+		InternalAuthKey:   os.Getenv("INTERNAL_AUTH_KEY"),
+		RoutesFile:        getEnvOrDefault("ROUTES_FILE", "routes.json"),
+
+		JWKSURL:             os.Getenv("JWKS_URL"),
+		JWKSRefreshInterval: getDurationOrDefault("JWKS_REFRESH_INTERVAL", 5*time.Minute),
+		JWTIssuer:           getEnvOrDefault("JWT_ISSUER", "user-service"),
+		JWTAudience:         getEnvOrDefault("JWT_AUDIENCE", "api-gateway"),
+
+		RevocationPollInterval: getDurationOrDefault("REVOCATION_POLL_INTERVAL", 2*time.Second),
+		RevocationMaxStaleness: getDurationOrDefault("REVOCATION_MAX_STALENESS", 30*time.Second),
+
+		DialTimeout:           getDurationOrDefault("UPSTREAM_DIAL_TIMEOUT", 5*time.Second),
+		ResponseHeaderTimeout: getDurationOrDefault("UPSTREAM_RESPONSE_HEADER_TIMEOUT", 15*time.Second),
+		IdleConnTimeout:       getDurationOrDefault("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second),
+		MaxIdleConnsPerHost:   getIntOrDefault("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 100),
+		FlushInterval:         getDurationOrDefault("PROXY_FLUSH_INTERVAL", 100*time.Millisecond),
+
+		BreakerFailureThreshold: getIntOrDefault("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerOpenTimeout:      getDurationOrDefault("BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		BreakerHalfOpenRequests: getIntOrDefault("BREAKER_HALF_OPEN_REQUESTS", 1),
+		RetryMaxAttempts:        getIntOrDefault("RETRY_MAX_ATTEMPTS", 3),
+		RetryBaseDelay:          getDurationOrDefault("RETRY_BASE_DELAY", 100*time.Millisecond),
+		RetryMaxDelay:           getDurationOrDefault("RETRY_MAX_DELAY", time.Second),
+
+		ReadinessTimeout: getDurationOrDefault("READINESS_TIMEOUT", 3*time.Second),
+	}
+	shutdownTimeout := getDurationOrDefault("SHUTDOWN_TIMEOUT", 15*time.Second)
+
+	if cfg.InternalAuthKey == "" {
+		log.Fatal("INTERNAL_AUTH_KEY is not set")
 	}
 
-	mux := http.NewServeMux()
-	proxy.RegisterRoutes(mux, cfg)
+	if cfg.JWKSURL == "" {
+		cfg.JWKSURL = strings.TrimSuffix(cfg.UserServiceURL, "/") + "/.well-known/jwks.json"
+	}
+	jwks := auth.NewJWKS(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+	go jwks.Run(ctx)
+	revocations := revocation.NewList(
+		strings.TrimSuffix(cfg.UserServiceURL, "/"),
+		internalauth.NewSigner(cfg.InternalAuthKey, "api-gateway"),
+		cfg.RevocationPollInterval,
+	)
+	go revocations.Run(ctx)
+	verifier := auth.NewVerifier(jwks, revocations, auth.VerifierSettings{
+		Issuer:                 cfg.JWTIssuer,
+		Audience:               cfg.JWTAudience,
+		MaxRevocationStaleness: cfg.RevocationMaxStaleness,
+	})
+
+	router, err := proxy.NewRouter(cfg, verifier)
+	if err != nil {
+		log.Fatalf("failed to create router: %v", err)
+	}
+	if err := router.Reload(); err != nil {
+		log.Fatalf("failed to load routes: %v", err)
+	}
+	go reloadOnSIGHUP(router)
 
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + port,
+		Handler:           requestid.Middleware(metrics.Middleware(router, router)),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
 	}
 
-	log.Printf("API Gateway starting on port %s", port)
-	log.Printf("User service: %s", cfg.UserServiceURL)
-	log.Printf("Product service: %s", cfg.ProductServiceURL)
-	log.Printf("Cart service: %s", cfg.CartServiceURL)
-	log.Printf("Order service: %s", cfg.OrderServiceURL)
-	log.Fatal(server.ListenAndServe())
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("API Gateway starting on port %s", port)
+		log.Printf("User service: %s", cfg.UserServiceURL)
+		log.Printf("Product service: %s", cfg.ProductServiceURL)
+		log.Printf("Cart service: %s", cfg.CartServiceURL)
+		log.Printf("Order service: %s", cfg.OrderServiceURL)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Printf("API Gateway shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to drain HTTP server: %v", err)
+	}
+	log.Printf("API Gateway stopped")
+}
+
+func reloadOnSIGHUP(router *proxy.Router) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		if err := router.Reload(); err != nil {
+			log.Printf("Route reload failed, keeping previous routes: %v", err)
+		}
+	}
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -49,3 +145,27 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid duration for %s: %v", key, err)
+	}
+	return d
+}
+
+func getIntOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid integer for %s: %v", key, err)
+	}
+	return n
+}
@@ -8,38 +8,55 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/requestid"
 )
 
 type ProductClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	Signer     *internalauth.Signer
 }
 
-type Product struct {
-	ID         uuid.UUID `json:"ID"`
-	Name       string    `json:"Name"`
-	PriceCents int32     `json:"PriceCents"`
-	Stock      int32     `json:"Stock"`
+// Variant is a product variant as product-service sells it, priced at the
+// product's price unless the variant overrides it.
+type Variant struct {
+	ID         uuid.UUID `json:"id"`
+	ProductID  uuid.UUID `json:"product_id"`
+	Name       string    `json:"name"`
+	SKU        string    `json:"sku"`
+	PriceCents int32     `json:"price_cents"`
+	Stock      int32     `json:"stock"`
 }
 
-func NewProductClient(baseURL string, timeout time.Duration) *ProductClient {
+func NewProductClient(baseURL string, timeout time.Duration, signer *internalauth.Signer) *ProductClient {
 	return &ProductClient{
 		BaseURL: baseURL,
+		Signer:  signer,
 		HTTPClient: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: metrics.Transport("product-service", http.DefaultTransport),
 		},
 	}
 }
 
-func (c *ProductClient) GetProduct(ctx context.Context, productID uuid.UUID) (*Product, bool, error) {
-	if productID == uuid.Nil {
+func (c *ProductClient) GetVariant(ctx context.Context, variantID uuid.UUID) (*Variant, bool, error) {
+	if variantID == uuid.Nil {
 		return nil, false, fmt.Errorf("invalid UUID: nil")
 	}
-	url := fmt.Sprintf("%s/api/products/%s", c.BaseURL, productID.String())
+	url := fmt.Sprintf("%s/internal/variants/%s", c.BaseURL, variantID.String())
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, false, fmt.Errorf("error getting the response: %w", err)
 	}
+	if err := c.Signer.Sign(req, "", ""); err != nil {
+		return nil, false, fmt.Errorf("signing request: %w", err)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, false, fmt.Errorf("calling product service: %w", err)
@@ -52,9 +69,9 @@ func (c *ProductClient) GetProduct(ctx context.Context, productID uuid.UUID) (*P
 		return nil, false, fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
 
-	var product Product
-	if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
+	var variant Variant
+	if err := json.NewDecoder(resp.Body).Decode(&variant); err != nil {
 		return nil, false, fmt.Errorf("decoding response: %w", err)
 	}
-	return &product, true, nil
+	return &variant, true, nil
 }
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/requestid"
+)
+
+func TestProductClientForwardsRequestID(t *testing.T) {
+	signer := internalauth.NewSigner("test-key", "cart-service")
+	variantID := uuid.New()
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{name: "with request ID", requestID: "req-123"},
+		{name: "without request ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var verifyErr error
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(requestid.Header)
+				verifyErr = signer.Verify(r)
+				json.NewEncoder(w).Encode(Variant{ID: variantID, ProductID: uuid.New(), Name: "Mug", PriceCents: 900})
+			}))
+			defer server.Close()
+
+			ctx := context.Background()
+			if tt.requestID != "" {
+				ctx = requestid.NewContext(ctx, tt.requestID)
+			}
+			c := NewProductClient(server.URL, time.Second, signer)
+			variant, found, err := c.GetVariant(ctx, variantID)
+			if err != nil || !found {
+				t.Fatalf("GetVariant = %v, %v, %v", variant, found, err)
+			}
+			if got != tt.requestID {
+				t.Errorf("X-Request-ID = %q, want %q", got, tt.requestID)
+			}
+			if verifyErr != nil {
+				t.Errorf("request not signed: %v", verifyErr)
+			}
+		})
+	}
+}
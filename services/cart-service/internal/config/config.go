@@ -1,11 +1,17 @@
 package config
 
 import (
-	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/database"
+	"database/sql"
+
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/client"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/rabbitmq"
 )
+
 type Config struct {
 	DB            *database.Queries
+	DBConn        *sql.DB
 	Platform      string
 	ProductClient *client.ProductClient
+	Consumer      *rabbitmq.Consumer
 }
@@ -15,6 +15,7 @@ const addCartItem = `-- name: AddCartItem :one
 INSERT INTO cart_items (
   cart_id,
   product_id,
+  variant_id,
   quantity,
   price_cents,
   created_at,
@@ -24,14 +25,16 @@ INSERT INTO cart_items (
     $2,
     $3,
     $4,
+    $5,
     now(),
     now()
-) RETURNING id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+) RETURNING id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 `
 
 type AddCartItemParams struct {
 	CartID     uuid.UUID
 	ProductID  uuid.UUID
+	VariantID  uuid.UUID
 	Quantity   int32
 	PriceCents int32
 }
@@ -40,6 +43,7 @@ func (q *Queries) AddCartItem(ctx context.Context, arg AddCartItemParams) (CartI
 	row := q.db.QueryRowContext(ctx, addCartItem,
 		arg.CartID,
 		arg.ProductID,
+		arg.VariantID,
 		arg.Quantity,
 		arg.PriceCents,
 	)
@@ -52,6 +56,7 @@ func (q *Queries) AddCartItem(ctx context.Context, arg AddCartItemParams) (CartI
 		&i.PriceCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.VariantID,
 	)
 	return i, err
 }
@@ -100,6 +105,19 @@ func (q *Queries) DeleteCart(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteCartByUserID = `-- name: DeleteCartByUserID :execrows
+DELETE FROM carts
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteCartByUserID(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCartByUserID, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteCartItem = `-- name: DeleteCartItem :exec
 DELETE FROM cart_items
 WHERE id = $1
@@ -129,7 +147,7 @@ func (q *Queries) GetCartByUserID(ctx context.Context, userID uuid.UUID) (Cart,
 }
 
 const getCartItemByID = `-- name: GetCartItemByID :one
-SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 FROM cart_items
 WHERE id = $1
 `
@@ -145,23 +163,24 @@ func (q *Queries) GetCartItemByID(ctx context.Context, id uuid.UUID) (CartItem,
 		&i.PriceCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.VariantID,
 	)
 	return i, err
 }
 
-const getCartItemByProductID = `-- name: GetCartItemByProductID :one
-SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+const getCartItemByVariantID = `-- name: GetCartItemByVariantID :one
+SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 FROM cart_items
-WHERE cart_id = $1 AND product_id = $2
+WHERE cart_id = $1 AND variant_id = $2
 `
 
-type GetCartItemByProductIDParams struct {
+type GetCartItemByVariantIDParams struct {
 	CartID    uuid.UUID
-	ProductID uuid.UUID
+	VariantID uuid.UUID
 }
 
-func (q *Queries) GetCartItemByProductID(ctx context.Context, arg GetCartItemByProductIDParams) (CartItem, error) {
-	row := q.db.QueryRowContext(ctx, getCartItemByProductID, arg.CartID, arg.ProductID)
+func (q *Queries) GetCartItemByVariantID(ctx context.Context, arg GetCartItemByVariantIDParams) (CartItem, error) {
+	row := q.db.QueryRowContext(ctx, getCartItemByVariantID, arg.CartID, arg.VariantID)
 	var i CartItem
 	err := row.Scan(
 		&i.ID,
@@ -171,12 +190,13 @@ func (q *Queries) GetCartItemByProductID(ctx context.Context, arg GetCartItemByP
 		&i.PriceCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.VariantID,
 	)
 	return i, err
 }
 
 const getCartItems = `-- name: GetCartItems :many
-SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 FROM cart_items
 WHERE cart_id = $1
 `
@@ -198,6 +218,7 @@ func (q *Queries) GetCartItems(ctx context.Context, cartID uuid.UUID) ([]CartIte
 			&i.PriceCents,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.VariantID,
 		); err != nil {
 			return nil, err
 		}
@@ -218,7 +239,7 @@ SET
   quantity = $2,
   updated_at = now()
 WHERE id = $1
-RETURNING id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+RETURNING id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 `
 
 type UpdateCartItemQuantityParams struct {
@@ -237,6 +258,7 @@ func (q *Queries) UpdateCartItemQuantity(ctx context.Context, arg UpdateCartItem
 		&i.PriceCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.VariantID,
 	)
 	return i, err
 }
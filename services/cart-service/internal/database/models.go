@@ -25,4 +25,5 @@ type CartItem struct {
 	PriceCents int32
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+	VariantID  uuid.UUID
 }
@@ -9,14 +9,20 @@ import (
 	"github.com/google/uuid"
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/metrics"
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/response"
 )
 
 func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "cart-service"})
+	mux.HandleFunc("GET /health", handlerLive)
+	mux.HandleFunc("GET /health/live", handlerLive)
+
+	mux.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		handlerReady(cfg, w, r)
 	})
 
+	mux.Handle("GET /metrics", metrics.Handler())
+
 	mux.HandleFunc("GET /api/cart", func(w http.ResponseWriter, r *http.Request) {
 		handlerCartGet(cfg, w, r)
 	})
@@ -49,6 +55,7 @@ func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 type CartItemResponse struct {
 	ID         uuid.UUID `json:"id"`
 	ProductID  uuid.UUID `json:"product_id"`
+	VariantID  uuid.UUID `json:"variant_id"`
 	Quantity   int32     `json:"quantity"`
 	PriceCents int32     `json:"price_cents"`
 }
@@ -97,6 +104,7 @@ func handlerCartGet(cfg *config.Config, w http.ResponseWriter, r *http.Request)
 		itemResponses[i] = CartItemResponse{
 			ID:         item.ID,
 			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
 			Quantity:   item.Quantity,
 			PriceCents: item.PriceCents,
 		}
@@ -111,6 +119,7 @@ func handlerCartGet(cfg *config.Config, w http.ResponseWriter, r *http.Request)
 
 func handlerCartAddItem(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
 	type addItemRequest struct {
+		VariantID uuid.UUID `json:"variant_id"`
 		ProductID uuid.UUID `json:"product_id"`
 		Quantity  int32     `json:"quantity"`
 	}
@@ -133,18 +142,24 @@ func handlerCartAddItem(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if item.ProductID == uuid.Nil || item.Quantity <= 0 {
-		response.RespondWithError(w, http.StatusBadRequest, "invalid product_id or quantity", err)
+	// A bare product_id from an older client means the product's default
+	// variant. product-service gives every default variant its product's ID,
+	// including those migrated from before variants existed.
+	if item.VariantID == uuid.Nil {
+		item.VariantID = item.ProductID
+	}
+	if item.VariantID == uuid.Nil || item.Quantity <= 0 {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid variant_id or quantity", err)
 		return
 	}
 
-	product, exists, err := cfg.ProductClient.GetProduct(r.Context(), item.ProductID)
+	variant, exists, err := cfg.ProductClient.GetVariant(r.Context(), item.VariantID)
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "error checking product", err)
 		return
 	}
 	if !exists {
-		response.RespondWithError(w, http.StatusNotFound, "product not found", nil)
+		response.RespondWithError(w, http.StatusNotFound, "product variant not found", nil)
 		return
 	}
 
@@ -162,9 +177,9 @@ func handlerCartAddItem(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	existingItem, err := cfg.DB.GetCartItemByProductID(r.Context(), database.GetCartItemByProductIDParams{
+	existingItem, err := cfg.DB.GetCartItemByVariantID(r.Context(), database.GetCartItemByVariantIDParams{
 		CartID:    cart.ID,
-		ProductID: item.ProductID,
+		VariantID: variant.ID,
 	})
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		response.RespondWithError(w, http.StatusInternalServerError, "couldn't check cart", err)
@@ -179,9 +194,10 @@ func handlerCartAddItem(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 	} else {
 		cartItem, err = cfg.DB.AddCartItem(r.Context(), database.AddCartItemParams{
 			CartID:     cart.ID,
-			ProductID:  item.ProductID,
+			ProductID:  variant.ProductID,
+			VariantID:  variant.ID,
 			Quantity:   item.Quantity,
-			PriceCents: product.PriceCents,
+			PriceCents: variant.PriceCents,
 		})
 	}
 	if err != nil {
@@ -191,6 +207,7 @@ func handlerCartAddItem(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 	response.RespondWithJSON(w, http.StatusCreated, CartItemResponse{
 		ID:         cartItem.ID,
 		ProductID:  cartItem.ProductID,
+		VariantID:  cartItem.VariantID,
 		Quantity:   cartItem.Quantity,
 		PriceCents: cartItem.PriceCents,
 	})
@@ -259,6 +276,7 @@ func handlerCartUpdateItem(cfg *config.Config, w http.ResponseWriter, r *http.Re
 	response.RespondWithJSON(w, http.StatusOK, CartItemResponse{
 		ID:         updatedItem.ID,
 		ProductID:  updatedItem.ProductID,
+		VariantID:  updatedItem.VariantID,
 		Quantity:   updatedItem.Quantity,
 		PriceCents: updatedItem.PriceCents,
 	})
@@ -371,6 +389,7 @@ func handlerInternalCartGet(cfg *config.Config, w http.ResponseWriter, r *http.R
 		itemResponses[i] = CartItemResponse{
 			ID:         item.ID,
 			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
 			Quantity:   item.Quantity,
 			PriceCents: item.PriceCents,
 		}
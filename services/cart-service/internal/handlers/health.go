@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/response"
+)
+
+// readinessTimeout bounds each dependency check so a hung dependency reports
+// as down instead of stalling the probe.
+const readinessTimeout = 2 * time.Second
+
+type readiness struct {
+	Status  string            `json:"status"`
+	Service string            `json:"service"`
+	Checks  map[string]string `json:"checks"`
+}
+
+func handlerLive(w http.ResponseWriter, r *http.Request) {
+	response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "cart-service"})
+}
+
+// handlerReady reports whether the service's dependencies are reachable.
+func handlerReady(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	respondReadiness(w, r, map[string]func(context.Context) error{
+		"database": cfg.DBConn.PingContext,
+		"rabbitmq": func(context.Context) error { return cfg.Consumer.Ready() },
+	})
+}
+
+// respondReadiness runs checks and reports the results. It answers 503 if
+// any check fails so orchestrators stop routing to the service.
+func respondReadiness(w http.ResponseWriter, r *http.Request, checks map[string]func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	result := readiness{
+		Status:  "ok",
+		Service: "cart-service",
+		Checks:  make(map[string]string, len(checks)),
+	}
+	status := http.StatusOK
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			log.Printf("readiness check %s failed: %v", name, err)
+			result.Checks[name] = "unavailable"
+			result.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+
+	response.RespondWithJSON(w, status, result)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// pingDriver opens connections whose Ping returns err
+type pingDriver struct {
+	err error
+}
+
+func (d pingDriver) Open(string) (driver.Conn, error) { return pingConn{err: d.err}, nil }
+
+type pingConn struct {
+	err error
+}
+
+func (c pingConn) Ping(context.Context) error          { return c.err }
+func (c pingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c pingConn) Close() error                        { return nil }
+func (c pingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func TestRespondReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		pingErr    error
+		rabbitErr  error
+		wantStatus int
+		wantCheck  string
+	}{
+		{name: "all up", wantStatus: http.StatusOK, wantCheck: "ok"},
+		{name: "database down", pingErr: errors.New("connection refused"), wantStatus: http.StatusServiceUnavailable, wantCheck: "unavailable"},
+		{name: "rabbitmq down", rabbitErr: errors.New("channel closed"), wantStatus: http.StatusServiceUnavailable, wantCheck: "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "ping-" + uuid.NewString()
+			sql.Register(name, pingDriver{err: tt.pingErr})
+			db, err := sql.Open(name, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rec := httptest.NewRecorder()
+			respondReadiness(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil), map[string]func(context.Context) error{
+				"database": db.PingContext,
+				"rabbitmq": func(context.Context) error { return tt.rabbitErr },
+			})
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body readiness
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Service != "cart-service" {
+				t.Errorf("service = %q, want cart-service", body.Service)
+			}
+			if body.Checks["database"] != tt.wantCheck {
+				t.Errorf("database check = %q, want %q", body.Checks["database"], tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestHandlerLive(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handlerLive(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RabbitMQ consumer counters, by routing key. A nack counts as nacked and as
+// either requeued or dead-lettered, so a rising dead-letter rate points at
+// messages that can never be processed.
+var (
+	MessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_consumed_total",
+		Help: "Messages delivered to the consumer, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesAcked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_acked_total",
+		Help: "Messages acknowledged after processing, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesNacked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_nacked_total",
+		Help: "Messages rejected, with or without requeue, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesRequeued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_requeued_total",
+		Help: "Messages rejected and put back on the queue, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_dead_lettered_total",
+		Help: "Messages rejected without requeue and sent to the dead-letter queue, by routing key.",
+	}, []string{"routing_key"})
+)
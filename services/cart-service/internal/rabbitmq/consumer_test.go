@@ -0,0 +1,157 @@
+package rabbitmq
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/database"
+)
+
+// execDriver records the statements it is asked to execute and answers them
+// with err, or one row affected.
+type execDriver struct {
+	mu    sync.Mutex
+	err   error
+	execs []string
+	args  [][]driver.Value
+}
+
+func (d *execDriver) Open(string) (driver.Conn, error) { return execConn{d}, nil }
+
+type execConn struct{ d *execDriver }
+
+func (c execConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c execConn) Close() error                        { return nil }
+func (c execConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c execConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.execs = append(c.d.execs, query)
+	c.d.args = append(c.d.args, values)
+	if c.d.err != nil {
+		return nil, c.d.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func newTestConsumer(t *testing.T, d *execDriver) *Consumer {
+	t.Helper()
+	name := "exec-" + uuid.NewString()
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Consumer{queries: database.New(db)}
+}
+
+// acknowledger records how a delivery was settled.
+type acknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestHandleUserDeleted(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name        string
+		body        string
+		dbErr       error
+		deliveries  int64
+		wantDeleted bool
+		wantAck     bool
+		wantRequeue bool
+	}{
+		{
+			name:        "cart cleared",
+			body:        `{"user_id":"` + userID.String() + `","timestamp":"2026-01-02T03:04:05Z"}`,
+			wantDeleted: true,
+			wantAck:     true,
+		},
+		{
+			name:        "database down",
+			body:        `{"user_id":"` + userID.String() + `","timestamp":"2026-01-02T03:04:05Z"}`,
+			dbErr:       errors.New("connection refused"),
+			wantDeleted: true,
+			wantRequeue: true,
+		},
+		{
+			// The quorum queue counts earlier deliveries in x-delivery-count;
+			// on the last one the message is dead-lettered instead
+			name:        "database down on the last delivery",
+			body:        `{"user_id":"` + userID.String() + `","timestamp":"2026-01-02T03:04:05Z"}`,
+			dbErr:       errors.New("connection refused"),
+			deliveries:  maxDeliveries - 1,
+			wantDeleted: true,
+		},
+		{
+			// Redelivering a message that can't be parsed won't help
+			name: "malformed",
+			body: `{"user_id":`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &execDriver{err: tt.dbErr}
+			c := newTestConsumer(t, d)
+			ack := &acknowledger{}
+
+			c.handle(context.Background(), amqp.Delivery{
+				Acknowledger: ack,
+				RoutingKey:   "user.deleted",
+				Headers:      amqp.Table{"x-delivery-count": tt.deliveries},
+				Body:         []byte(tt.body),
+			})
+
+			if ack.acked != tt.wantAck || ack.nacked == tt.wantAck {
+				t.Fatalf("acked = %v, nacked = %v, want acked %v", ack.acked, ack.nacked, tt.wantAck)
+			}
+			if ack.requeue != tt.wantRequeue {
+				t.Errorf("requeue = %v, want %v", ack.requeue, tt.wantRequeue)
+			}
+			if !tt.wantDeleted {
+				if len(d.execs) != 0 {
+					t.Errorf("ran %v, want nothing", d.execs)
+				}
+				return
+			}
+			if len(d.execs) != 1 || !strings.Contains(d.execs[0], "DELETE FROM carts") {
+				t.Fatalf("ran %v, want the user's cart deleted", d.execs)
+			}
+			if d.args[0][0] != userID.String() {
+				t.Errorf("deleted cart of %v, want %s", d.args[0][0], userID)
+			}
+		})
+	}
+}
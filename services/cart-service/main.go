@@ -1,52 +1,93 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/client"
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/database"
 	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/handlers"
-	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/client"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/rabbitmq"
+	"github.com/herodragmon/scalable-ecommerce/services/cart-service/internal/requestid"
 )
 
 func main() {
 	godotenv.Load()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	platform := os.Getenv("PLATFORM")
 	dbURL := os.Getenv("DB_URL")
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8083"
 	}
+	shutdownTimeout := 15 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid duration for SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = d
+	}
+	internalAuthKey := os.Getenv("INTERNAL_AUTH_KEY")
+	if internalAuthKey == "" {
+		log.Fatal("INTERNAL_AUTH_KEY is not set")
+	}
+	signer := internalauth.NewSigner(internalAuthKey, "cart-service")
+	rabbitmqURL := os.Getenv("RABBITMQ_URL")
+	if rabbitmqURL == "" {
+		log.Fatal("RABBITMQ_URL is not set")
+	}
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("failed to open database connection: %v", err)
 	}
-	defer db.Close()
 
 	if err := db.Ping(); err != nil {
 		log.Fatalf("failed to ping database: %v", err)
 	}
 	productServiceURL := os.Getenv("PRODUCT_SERVICE_URL")
-  if productServiceURL == "" {
+	if productServiceURL == "" {
 		log.Fatal("PRODUCT_SERVICE_URL is not set")
-  }
-	productClient := client.NewProductClient(productServiceURL, 10*time.Second)
+	}
+	productClient := client.NewProductClient(productServiceURL, 10*time.Second, signer)
+
+	dbQueries := database.New(metrics.InstrumentDB(db))
 
-	dbQueries := database.New(db)
+	consumer, err := rabbitmq.NewConsumer(rabbitmqURL, dbQueries)
+	if err != nil {
+		log.Fatalf("failed to consume: %v", err)
+	}
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := consumer.Start(ctx); err != nil {
+			log.Printf("consumer stopped: %v", err)
+		}
+	}()
 
 	cfg := &config.Config{
-		DB:       dbQueries,
-		Platform: platform,
+		DB:            dbQueries,
+		DBConn:        db,
+		Platform:      platform,
 		ProductClient: productClient,
+		Consumer:      consumer,
 	}
 
 	mux := http.NewServeMux()
@@ -54,9 +95,36 @@ func main() {
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: requestid.Middleware(metrics.Middleware(mux, internalauth.Middleware(signer, mux))),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Cart service starting on port %s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("server failed: %v", err)
+	case <-ctx.Done():
 	}
+	stop()
+	log.Printf("Cart service shutting down")
 
-	log.Printf("Cart service starting on port %s", port)
-	log.Fatal(server.ListenAndServe())
+	// Drain HTTP first so in-flight requests can still use the dependencies
+	// closed below
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to drain HTTP server: %v", err)
+	}
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		log.Printf("timed out waiting for consumer to finish")
+	}
+	consumer.Close()
+	db.Close()
+	log.Printf("Cart service stopped")
 }
@@ -15,12 +15,12 @@ INSERT INTO carts (
 ) RETURNING *;
 
 -- name: GetCartItems :many
-SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 FROM cart_items
 WHERE cart_id = $1;
 
 -- name: GetCartItemByID :one
-SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 FROM cart_items
 WHERE id = $1;
 
@@ -28,6 +28,7 @@ WHERE id = $1;
 INSERT INTO cart_items (
   cart_id,
   product_id,
+  variant_id,
   quantity,
   price_cents,
   created_at,
@@ -37,6 +38,7 @@ INSERT INTO cart_items (
     $2,
     $3,
     $4,
+    $5,
     now(),
     now()
 ) RETURNING *;
@@ -61,8 +63,12 @@ WHERE cart_id = $1;
 DELETE FROM carts
 WHERE id = $1;
 
--- name: GetCartItemByProductID :one
-SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at
+-- name: GetCartItemByVariantID :one
+SELECT id, cart_id, product_id, quantity, price_cents, created_at, updated_at, variant_id
 FROM cart_items
-WHERE cart_id = $1 AND product_id = $2;
+WHERE cart_id = $1 AND variant_id = $2;
 
+
+-- name: DeleteCartByUserID :execrows
+DELETE FROM carts
+WHERE user_id = $1;
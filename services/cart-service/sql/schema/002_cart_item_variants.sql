@@ -0,0 +1,19 @@
+-- +goose Up
+-- Cart items hold the product variant being bought. Every product's default
+-- variant has the product's ID, so existing items point at it.
+ALTER TABLE cart_items ADD COLUMN variant_id UUID;
+UPDATE cart_items SET variant_id = product_id;
+ALTER TABLE cart_items ALTER COLUMN variant_id SET NOT NULL;
+
+ALTER TABLE cart_items DROP CONSTRAINT cart_items_cart_id_product_id_key;
+ALTER TABLE cart_items ADD CONSTRAINT cart_items_cart_id_variant_id_key UNIQUE (cart_id, variant_id);
+
+-- +goose Down
+-- Keep one line per product so the old constraint can come back
+DELETE FROM cart_items a
+USING cart_items b
+WHERE a.cart_id = b.cart_id AND a.product_id = b.product_id AND a.id > b.id;
+
+ALTER TABLE cart_items DROP CONSTRAINT cart_items_cart_id_variant_id_key;
+ALTER TABLE cart_items ADD CONSTRAINT cart_items_cart_id_product_id_key UNIQUE (cart_id, product_id);
+ALTER TABLE cart_items DROP COLUMN variant_id;
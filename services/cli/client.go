@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
 	"time"
 )
 
@@ -16,10 +19,14 @@ type Client struct {
 }
 
 func NewClient(baseURL string) *Client {
+	// The jar keeps the refresh token cookie so the server can tell which
+	// session is ours
+	jar, _ := cookiejar.New(nil)
 	return &Client{
 		BaseURL: baseURL,
 		HTTPClient: &http.Client{
 			Timeout: 10 * time.Second,
+			Jar:     jar,
 		},
 	}
 }
@@ -84,7 +91,47 @@ func (c *Client) Login(email, password string) (*LoginResponse, error) {
 		"email":    email,
 		"password": password,
 	}
-	respBody, err := c.doRequest("POST", "/api/login", body)
+	return c.login("/api/login", body)
+}
+
+// LoginMFA completes a login that answered with mfa_required.
+func (c *Client) LoginMFA(mfaToken, code string) (*LoginResponse, error) {
+	body := map[string]string{
+		"mfa_token": mfaToken,
+		"code":      code,
+	}
+	return c.login("/api/login/mfa", body)
+}
+
+// LoginMFASetup starts two-factor enrollment for a login that answered
+// with mfa_setup_required.
+func (c *Client) LoginMFASetup(mfaToken string) (*TOTPEnrollment, error) {
+	body := map[string]string{
+		"mfa_token": mfaToken,
+	}
+	respBody, err := c.doRequest("POST", "/api/login/mfa/setup", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var enrollment TOTPEnrollment
+	if err := json.Unmarshal(respBody, &enrollment); err != nil {
+		return nil, fmt.Errorf("failed to parse enrollment: %w", err)
+	}
+
+	return &enrollment, nil
+}
+
+func (c *Client) LoginMFASetupConfirm(mfaToken, code string) (*LoginResponse, error) {
+	body := map[string]string{
+		"mfa_token": mfaToken,
+		"code":      code,
+	}
+	return c.login("/api/login/mfa/setup/confirm", body)
+}
+
+func (c *Client) login(path string, body interface{}) (*LoginResponse, error) {
+	respBody, err := c.doRequest("POST", path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -98,20 +145,230 @@ func (c *Client) Login(email, password string) (*LoginResponse, error) {
 	return &loginResp, nil
 }
 
+// Addresses
+
+func (c *Client) GetAddresses() ([]Address, error) {
+	respBody, err := c.doRequest("GET", "/api/me/addresses", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []Address
+	if err := json.Unmarshal(respBody, &addresses); err != nil {
+		return nil, fmt.Errorf("failed to parse addresses: %w", err)
+	}
+
+	return addresses, nil
+}
+
+func (c *Client) CreateAddress(address Address) (*Address, error) {
+	respBody, err := c.doRequest("POST", "/api/me/addresses", address)
+	if err != nil {
+		return nil, err
+	}
+
+	var created Address
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return nil, fmt.Errorf("failed to parse address: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (c *Client) UpdateAddress(address Address) error {
+	_, err := c.doRequest("PUT", "/api/me/addresses/"+address.ID, address)
+	return err
+}
+
+func (c *Client) DeleteAddress(addressID string) error {
+	_, err := c.doRequest("DELETE", "/api/me/addresses/"+addressID, nil)
+	return err
+}
+
+// Two-factor authentication
+
+func (c *Client) GetMFAStatus() (*MFAStatus, error) {
+	respBody, err := c.doRequest("GET", "/api/mfa", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var status MFAStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse two-factor status: %w", err)
+	}
+
+	return &status, nil
+}
+
+func (c *Client) EnrollTOTP() (*TOTPEnrollment, error) {
+	respBody, err := c.doRequest("POST", "/api/mfa/totp/enroll", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var enrollment TOTPEnrollment
+	if err := json.Unmarshal(respBody, &enrollment); err != nil {
+		return nil, fmt.Errorf("failed to parse enrollment: %w", err)
+	}
+
+	return &enrollment, nil
+}
+
+func (c *Client) ConfirmTOTP(code string) ([]string, error) {
+	body := map[string]string{
+		"code": code,
+	}
+	respBody, err := c.doRequest("POST", "/api/mfa/totp/confirm", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse recovery codes: %w", err)
+	}
+
+	return resp.RecoveryCodes, nil
+}
+
+func (c *Client) DisableTOTP(code string) error {
+	body := map[string]string{
+		"code": code,
+	}
+	_, err := c.doRequest("POST", "/api/mfa/totp/disable", body)
+	return err
+}
+
+// Account
+
+// ExportData downloads a zip archive of everything the store holds about the
+// current user.
+func (c *Client) ExportData() ([]byte, error) {
+	return c.doRequest("GET", "/api/me/export", nil)
+}
+
+// DeleteAccount permanently deletes the current user's account. code is only
+// needed when two-factor authentication is on.
+func (c *Client) DeleteAccount(password, code string) error {
+	body := map[string]string{
+		"password": password,
+		"code":     code,
+	}
+	_, err := c.doRequest("DELETE", "/api/me", body)
+	return err
+}
+
+// Sessions
+
+func (c *Client) GetSessions() ([]Session, error) {
+	respBody, err := c.doRequest("GET", "/api/sessions", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	if err := json.Unmarshal(respBody, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to parse sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (c *Client) RevokeSession(sessionID string) error {
+	_, err := c.doRequest("DELETE", "/api/sessions/"+sessionID, nil)
+	return err
+}
+
+func (c *Client) RevokeAllSessions() error {
+	_, err := c.doRequest("DELETE", "/api/sessions", nil)
+	return err
+}
+
 // Products
 
-func (c *Client) GetProducts() ([]Product, error) {
-	respBody, err := c.doRequest("GET", "/api/products", nil)
+func (c *Client) ListProducts(filter ProductFilter, cursor string, limit int) (*ProductPage, error) {
+	query := url.Values{}
+	if filter.Sort != "" {
+		query.Set("sort", filter.Sort)
+	}
+	if filter.Category != nil {
+		query.Set("category", filter.Category.Slug)
+	}
+	if filter.MinPriceCents > 0 {
+		query.Set("min_price", strconv.Itoa(filter.MinPriceCents))
+	}
+	if filter.MaxPriceCents > 0 {
+		query.Set("max_price", strconv.Itoa(filter.MaxPriceCents))
+	}
+	if filter.InStock {
+		query.Set("in_stock", "true")
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	query.Set("limit", strconv.Itoa(limit))
+
+	respBody, err := c.doRequest("GET", "/api/products?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	var products []Product
-	if err := json.Unmarshal(respBody, &products); err != nil {
+	var page ProductPage
+	if err := json.Unmarshal(respBody, &page); err != nil {
 		return nil, fmt.Errorf("failed to parse products: %w", err)
 	}
 
-	return products, nil
+	return &page, nil
+}
+
+func (c *Client) SearchProducts(q string, limit int) (*SearchResults, error) {
+	query := url.Values{}
+	query.Set("q", q)
+	query.Set("limit", strconv.Itoa(limit))
+
+	respBody, err := c.doRequest("GET", "/api/products/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results SearchResults
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse search results: %w", err)
+	}
+
+	return &results, nil
+}
+
+// GetProduct returns an active product with its variants.
+func (c *Client) GetProduct(productID string) (*Product, error) {
+	respBody, err := c.doRequest("GET", "/api/products/"+productID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var product Product
+	if err := json.Unmarshal(respBody, &product); err != nil {
+		return nil, fmt.Errorf("failed to parse product: %w", err)
+	}
+
+	return &product, nil
+}
+
+func (c *Client) GetCategories() ([]Category, error) {
+	respBody, err := c.doRequest("GET", "/api/categories", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []Category
+	if err := json.Unmarshal(respBody, &categories); err != nil {
+		return nil, fmt.Errorf("failed to parse categories: %w", err)
+	}
+
+	return categories, nil
 }
 
 // Cart
@@ -130,9 +387,9 @@ func (c *Client) GetCart() (*Cart, error) {
 	return &cart, nil
 }
 
-func (c *Client) AddToCart(productID string, quantity int) error {
+func (c *Client) AddToCart(variantID string, quantity int) error {
 	body := map[string]interface{}{
-		"product_id": productID,
+		"variant_id": variantID,
 		"quantity":   quantity,
 	}
 	_, err := c.doRequest("POST", "/api/cart/items", body)
@@ -181,10 +438,11 @@ func (c *Client) CancelOrder(orderID string) error {
 
 // Admin - Products
 
-func (c *Client) CreateProduct(name, description string, priceCents, stock int) (*Product, error) {
+func (c *Client) CreateProduct(name, description, sku string, priceCents, stock int) (*Product, error) {
 	body := map[string]interface{}{
 		"name":        name,
 		"description": description,
+		"sku":         sku,
 		"price_cents": priceCents,
 		"stock":       stock,
 	}
@@ -205,3 +463,73 @@ func (c *Client) DeleteProduct(productID string) error {
 	_, err := c.doRequest("DELETE", "/admin/products/"+productID, nil)
 	return err
 }
+
+// Admin - Users
+
+func (c *Client) ListUsers(search string, limit, offset int) (*UserPage, error) {
+	query := url.Values{}
+	if search != "" {
+		query.Set("search", search)
+	}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	respBody, err := c.doRequest("GET", "/admin/users?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var page UserPage
+	if err := json.Unmarshal(respBody, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse users: %w", err)
+	}
+
+	return &page, nil
+}
+
+func (c *Client) GetUser(userID string) (*AdminUser, error) {
+	respBody, err := c.doRequest("GET", "/admin/users/"+userID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var user AdminUser
+	if err := json.Unmarshal(respBody, &user); err != nil {
+		return nil, fmt.Errorf("failed to parse user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (c *Client) SetUserRole(userID, role string) error {
+	body := map[string]string{
+		"role": role,
+	}
+	_, err := c.doRequest("PUT", "/admin/users/"+userID+"/role", body)
+	return err
+}
+
+func (c *Client) DisableUser(userID string) error {
+	_, err := c.doRequest("POST", "/admin/users/"+userID+"/disable", nil)
+	return err
+}
+
+func (c *Client) EnableUser(userID string) error {
+	_, err := c.doRequest("POST", "/admin/users/"+userID+"/enable", nil)
+	return err
+}
+
+func (c *Client) ForceLogout(userID string) error {
+	_, err := c.doRequest("POST", "/admin/users/"+userID+"/revoke-tokens", nil)
+	return err
+}
+
+func (c *Client) UnlockUser(userID string) error {
+	_, err := c.doRequest("POST", "/admin/users/"+userID+"/unlock", nil)
+	return err
+}
+
+func (c *Client) DeleteUser(userID string) error {
+	_, err := c.doRequest("DELETE", "/admin/users/"+userID, nil)
+	return err
+}
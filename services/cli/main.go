@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -81,6 +82,21 @@ func promptPrice(label string) int {
 	}
 }
 
+// promptOptionalPrice is promptPrice that accepts a blank answer, returned as 0.
+func promptOptionalPrice(label string) int {
+	for {
+		input := prompt(label)
+		if input == "" {
+			return 0
+		}
+		price, err := strconv.ParseFloat(input, 64)
+		if err == nil && price > 0 {
+			return int(price * 100)
+		}
+		fmt.Println("Please enter a valid price (e.g., 19.99).")
+	}
+}
+
 // Auth Menu
 
 func showAuthMenu() {
@@ -111,17 +127,57 @@ func handleLogin() {
 	password := promptPassword("Password: ")
 
 	resp, err := client.Login(email, password)
+	if err == nil && resp.MFARequired {
+		code := prompt("Authentication code (or recovery code): ")
+		resp, err = client.LoginMFA(resp.MFAToken, code)
+	} else if err == nil && resp.MFASetupRequired {
+		resp, err = handleRequiredMFASetup(resp.MFAToken)
+	}
 	if err != nil {
 		fmt.Printf("Login failed: %s\n", err)
 		pressEnterToContinue()
 		return
 	}
 
+	if len(resp.RecoveryCodes) > 0 {
+		printRecoveryCodes(resp.RecoveryCodes)
+		pressEnterToContinue()
+	}
+
 	currentUser = &resp.User
 	clearScreen()
 	fmt.Printf("Welcome back, %s!\n", currentUser.Email)
 }
 
+// handleRequiredMFASetup walks through enrolling an authenticator app when
+// the account's role requires two-factor authentication.
+func handleRequiredMFASetup(mfaToken string) (*LoginResponse, error) {
+	fmt.Print("\nYour account requires two-factor authentication.\n\n")
+	enrollment, err := client.LoginMFASetup(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	printEnrollment(enrollment)
+	code := prompt("Authentication code: ")
+	return client.LoginMFASetupConfirm(mfaToken, code)
+}
+
+func printEnrollment(enrollment *TOTPEnrollment) {
+	fmt.Println("Add this account to your authenticator app:")
+	fmt.Printf("  Secret: %s\n", enrollment.Secret)
+	fmt.Printf("  URI:    %s\n", enrollment.ProvisioningURI)
+	fmt.Println()
+}
+
+func printRecoveryCodes(codes []string) {
+	fmt.Println("\nRecovery codes (each works once if you lose your authenticator):")
+	for _, code := range codes {
+		fmt.Printf("  %s\n", code)
+	}
+	fmt.Println("Store them somewhere safe, they won't be shown again.")
+}
+
 func handleRegister() {
 	fmt.Println("\n--- Register ---")
 	email := prompt("Email: ")
@@ -146,14 +202,19 @@ func showMainMenu() {
 	if currentUser.Role == "admin" {
 		fmt.Print(" [ADMIN]")
 	}
-	fmt.Println("\n")
+	fmt.Print("\n\n")
 	fmt.Println("1. Browse Products")
 	fmt.Println("2. View Cart")
 	fmt.Println("3. My Orders")
+	fmt.Println("4. My Sessions")
+	fmt.Println("5. Two-Factor Authentication")
+	fmt.Println("6. My Addresses")
+	fmt.Println("7. My Data and Account")
 	if currentUser.Role == "admin" {
-		fmt.Println("5. Admin: Manage Products")
+		fmt.Println("8. Admin: Manage Products")
+		fmt.Println("9. Admin: Manage Users")
 	}
-	fmt.Println("4. Logout")
+	fmt.Println("0. Logout")
 	fmt.Println()
 
 	choice := prompt("Enter choice: ")
@@ -166,16 +227,30 @@ func showMainMenu() {
 	case "3":
 		showOrders()
 	case "4":
+		showSessions()
+	case "0":
 		client.Token = ""
 		currentUser = nil
 		clearScreen()
 		fmt.Println("Logged out successfully.")
 	case "5":
+		showMFA()
+	case "6":
+		showAddresses()
+	case "7":
+		showAccount()
+	case "8":
 		if currentUser.Role == "admin" {
 			showAdminMenu()
 		} else {
 			fmt.Println("Invalid choice.")
 		}
+	case "9":
+		if currentUser.Role == "admin" {
+			showManageUsers()
+		} else {
+			fmt.Println("Invalid choice.")
+		}
 	default:
 		fmt.Println("Invalid choice.")
 	}
@@ -183,46 +258,294 @@ func showMainMenu() {
 
 // Products
 
-func showProducts() {
+const productPageSize = 10
+
+// browseProducts pages through the catalog until the user picks a product
+// to act on. It returns false if they go back instead.
+func browseProducts(title, action string) (Product, bool) {
+	filter := ProductFilter{}
+	// cursors[i] fetches page i+1; the first page needs none
+	cursors := []string{""}
+	for {
+		clearScreen()
+		fmt.Printf("\n--- %s ---\n\n", title)
+
+		page, err := client.ListProducts(filter, cursors[len(cursors)-1], productPageSize)
+		if err != nil {
+			fmt.Printf("Failed to fetch products: %s\n", err)
+			pressEnterToContinue()
+			return Product{}, false
+		}
+
+		if desc := describeProductFilter(filter); desc != "" {
+			fmt.Println(desc)
+		}
+		if len(page.Products) == 0 {
+			fmt.Println("No products found.")
+		} else {
+			fmt.Printf("%-4s %-30s %-10s %-10s\n", "#", "Name", "Price", "Stock")
+			fmt.Println(strings.Repeat("-", 58))
+			for i, p := range page.Products {
+				fmt.Printf("%-4d %-30s %-10s %-10d\n", i+1, p.Name, formatPrice(p.PriceCents), p.Stock)
+			}
+			fmt.Printf("\nPage %d\n", len(cursors))
+		}
+
+		fmt.Println()
+		fmt.Printf("Enter product number to %s, 'n'/'p' for next/previous page, 'c' for categories, 's' to sort, 'f' to filter, '/' to search, or 0 to go back.\n", action)
+		choice := strings.ToLower(prompt("Choice: "))
+
+		switch choice {
+		case "0", "":
+			return Product{}, false
+		case "n":
+			if page.NextCursor != "" {
+				cursors = append(cursors, page.NextCursor)
+			}
+			continue
+		case "p":
+			if len(cursors) > 1 {
+				cursors = cursors[:len(cursors)-1]
+			}
+			continue
+		case "c":
+			if category, ok := pickCategory(); ok {
+				filter.Category = category
+				cursors = []string{""}
+			}
+			continue
+		case "s":
+			filter.Sort = promptProductSort()
+			cursors = []string{""}
+			continue
+		case "f":
+			filter.MinPriceCents = promptOptionalPrice("Min price (blank for none): ")
+			filter.MaxPriceCents = promptOptionalPrice("Max price (blank for none): ")
+			filter.InStock = strings.ToLower(prompt("In stock only? (y/n): ")) == "y"
+			cursors = []string{""}
+			continue
+		case "/":
+			if product, ok := searchProducts(action); ok {
+				return product, true
+			}
+			continue
+		}
+
+		num, err := strconv.Atoi(choice)
+		if err != nil || num < 1 || num > len(page.Products) {
+			fmt.Println("Invalid product number.")
+			pressEnterToContinue()
+			continue
+		}
+
+		return page.Products[num-1], true
+	}
+}
+
+// searchProducts asks for search terms and shows the best matches. It
+// returns the product picked from them, or false to go back to browsing.
+func searchProducts(action string) (Product, bool) {
+	q := prompt("Search for: ")
+	if q == "" {
+		return Product{}, false
+	}
+
 	clearScreen()
-	fmt.Println("\n--- Products ---\n")
+	fmt.Printf("\n--- Search: %s ---\n\n", q)
 
-	products, err := client.GetProducts()
+	results, err := client.SearchProducts(q, productPageSize)
 	if err != nil {
-		fmt.Printf("Failed to fetch products: %s\n", err)
+		fmt.Printf("Failed to search products: %s\n", err)
 		pressEnterToContinue()
-		return
+		return Product{}, false
 	}
 
-	if len(products) == 0 {
-		fmt.Println("No products available.")
+	if len(results.Results) == 0 {
+		fmt.Println("No products match your search.")
 		pressEnterToContinue()
-		return
+		return Product{}, false
 	}
 
-	// Display products
-	fmt.Printf("%-4s %-30s %-10s %-10s\n", "#", "Name", "Price", "Stock")
-	fmt.Println(strings.Repeat("-", 58))
-	for i, p := range products {
-		fmt.Printf("%-4d %-30s %-10s %-10d\n", i+1, p.Name, formatPrice(p.PriceCents), p.Stock)
+	for i, r := range results.Results {
+		fmt.Printf("%-4d %s  %s  (stock: %d)\n", i+1, highlight(r.Headline), formatPrice(r.PriceCents), r.Stock)
+		if r.Snippet != "" {
+			fmt.Printf("     %s\n", highlight(r.Snippet))
+		}
 	}
 
 	fmt.Println()
-	fmt.Println("Enter product number to add to cart, or 0 to go back.")
+	fmt.Printf("Enter product number to %s, or 0 to go back.\n", action)
 	choice := promptInt("Choice: ")
-
 	if choice == 0 {
-		return
+		return Product{}, false
 	}
-
-	if choice < 1 || choice > len(products) {
+	if choice > len(results.Results) {
 		fmt.Println("Invalid product number.")
 		pressEnterToContinue()
+		return Product{}, false
+	}
+
+	r := results.Results[choice-1]
+	return Product{ID: r.ID, Name: r.Name, PriceCents: r.PriceCents, Stock: r.Stock}, true
+}
+
+// highlight shows the words a search matched, which arrive wrapped in **,
+// in bold.
+func highlight(s string) string {
+	parts := strings.Split(s, "**")
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			if i%2 == 1 {
+				b.WriteString("\033[1m")
+			} else {
+				b.WriteString("\033[0m")
+			}
+		}
+		b.WriteString(part)
+	}
+	if len(parts)%2 == 0 {
+		b.WriteString("\033[0m")
+	}
+	return b.String()
+}
+
+// pickCategory lets the user drill down the category tree. It returns the
+// category to browse, nil for all products, and false if they cancel.
+func pickCategory() (*Category, bool) {
+	tree, err := client.GetCategories()
+	if err != nil {
+		fmt.Printf("Failed to fetch categories: %s\n", err)
+		pressEnterToContinue()
+		return nil, false
+	}
+
+	// path holds the categories opened so far, outermost first
+	var path []*Category
+	for {
+		level := tree
+		breadcrumb := []string{"All"}
+		for _, c := range path {
+			breadcrumb = append(breadcrumb, c.Name)
+		}
+		if len(path) > 0 {
+			level = path[len(path)-1].Children
+		}
+
+		clearScreen()
+		fmt.Print("\n--- Categories ---\n\n")
+		fmt.Println(strings.Join(breadcrumb, " > "))
+		fmt.Println()
+		if len(level) == 0 {
+			fmt.Println("No categories here.")
+		}
+		for i, c := range level {
+			more := ""
+			if len(c.Children) > 0 {
+				more = " >"
+			}
+			fmt.Printf("%d. %s%s\n", i+1, c.Name, more)
+		}
+
+		fmt.Println()
+		fmt.Printf("Enter category number to open it, 'a' to browse %s, 'b' to go up, or 0 to cancel.\n", breadcrumb[len(breadcrumb)-1])
+		choice := strings.ToLower(prompt("Choice: "))
+
+		switch choice {
+		case "0", "":
+			return nil, false
+		case "a":
+			if len(path) == 0 {
+				return nil, true
+			}
+			return path[len(path)-1], true
+		case "b":
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+			continue
+		}
+
+		num, err := strconv.Atoi(choice)
+		if err != nil || num < 1 || num > len(level) {
+			fmt.Println("Invalid category number.")
+			pressEnterToContinue()
+			continue
+		}
+
+		category := &level[num-1]
+		if len(category.Children) == 0 {
+			return category, true
+		}
+		path = append(path, category)
+	}
+}
+
+var productSorts = []struct {
+	value string
+	label string
+}{
+	{"newest", "Newest"},
+	{"price_asc", "Price: low to high"},
+	{"price_desc", "Price: high to low"},
+	{"name", "Name"},
+}
+
+func promptProductSort() string {
+	fmt.Println()
+	for i, s := range productSorts {
+		fmt.Printf("%d. %s\n", i+1, s.label)
+	}
+	for {
+		choice := promptInt("Sort by: ")
+		if choice >= 1 && choice <= len(productSorts) {
+			return productSorts[choice-1].value
+		}
+		fmt.Println("Invalid choice.")
+	}
+}
+
+// describeProductFilter summarises the sort and filters in effect, or
+// returns "" when there are none.
+func describeProductFilter(filter ProductFilter) string {
+	var parts []string
+	if filter.Category != nil {
+		parts = append(parts, "Category: "+filter.Category.Name)
+	}
+	for _, s := range productSorts {
+		if filter.Sort == s.value && s.value != "newest" {
+			parts = append(parts, "Sort: "+s.label)
+		}
+	}
+	if filter.MinPriceCents > 0 {
+		parts = append(parts, "From "+formatPrice(filter.MinPriceCents))
+	}
+	if filter.MaxPriceCents > 0 {
+		parts = append(parts, "Up to "+formatPrice(filter.MaxPriceCents))
+	}
+	if filter.InStock {
+		parts = append(parts, "In stock only")
+	}
+	return strings.Join(parts, " | ")
+}
+
+func showProducts() {
+	product, ok := browseProducts("Products", "add to cart")
+	if !ok {
+		return
+	}
+
+	variant, ok := pickVariant(product)
+	if !ok {
 		return
 	}
 
-	product := products[choice-1]
-	quantity := promptInt(fmt.Sprintf("Quantity for '%s': ", product.Name))
+	name := product.Name
+	if opts := describeOptions(variant.Options); opts != "" {
+		name += " (" + opts + ")"
+	}
+
+	quantity := promptInt(fmt.Sprintf("Quantity for '%s': ", name))
 
 	if quantity == 0 {
 		fmt.Println("Cancelled.")
@@ -230,22 +553,79 @@ func showProducts() {
 		return
 	}
 
-	err = client.AddToCart(product.ID, quantity)
+	err := client.AddToCart(variant.ID, quantity)
 	if err != nil {
 		fmt.Printf("Failed to add to cart: %s\n", err)
 		pressEnterToContinue()
 		return
 	}
 
-	fmt.Printf("Added %d x %s to cart!\n", quantity, product.Name)
+	fmt.Printf("Added %d x %s to cart!\n", quantity, name)
 	pressEnterToContinue()
 }
 
+// pickVariant asks which variant of a product to buy. Products with a single
+// variant skip the question.
+func pickVariant(product Product) (Variant, bool) {
+	details, err := client.GetProduct(product.ID)
+	if err != nil {
+		fmt.Printf("Failed to fetch product: %s\n", err)
+		pressEnterToContinue()
+		return Variant{}, false
+	}
+	if len(details.Variants) == 0 {
+		fmt.Println("This product is not available.")
+		pressEnterToContinue()
+		return Variant{}, false
+	}
+	if len(details.Variants) == 1 {
+		return details.Variants[0], true
+	}
+
+	fmt.Printf("\n--- %s ---\n\n", details.Name)
+	fmt.Printf("%-4s %-30s %-10s %-10s\n", "#", "Options", "Price", "Stock")
+	fmt.Println(strings.Repeat("-", 58))
+	for i, v := range details.Variants {
+		opts := describeOptions(v.Options)
+		if opts == "" {
+			opts = "Standard"
+		}
+		fmt.Printf("%-4d %-30s %-10s %-10d\n", i+1, opts, formatPrice(v.PriceCents), v.Stock)
+	}
+
+	fmt.Println()
+	choice := promptInt("Choose a variant (0 to go back): ")
+	if choice == 0 {
+		return Variant{}, false
+	}
+	if choice > len(details.Variants) {
+		fmt.Println("Invalid variant number.")
+		pressEnterToContinue()
+		return Variant{}, false
+	}
+	return details.Variants[choice-1], true
+}
+
+// describeOptions formats variant options as "color: red, size: M".
+func describeOptions(options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+": "+options[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
 // Cart
 
 func showCart() {
 	clearScreen()
-	fmt.Println("\n--- Your Cart ---\n")
+	fmt.Print("\n--- Your Cart ---\n\n")
 
 	cart, err := client.GetCart()
 	if err != nil {
@@ -306,6 +686,11 @@ func handleCheckout() {
 	fmt.Printf("Order ID: %s\n", order.ID)
 	fmt.Printf("Status: %s\n", order.Status)
 	fmt.Printf("Total: %s\n", formatPrice(order.TotalCents))
+	if a := order.ShippingAddress; a != nil {
+		fmt.Printf("Ship to: %s, %s, %s %s, %s\n", a.RecipientName, a.Line1, a.PostalCode, a.City, a.Country)
+	} else {
+		fmt.Println("Ship to: no address on file (add one under My Addresses)")
+	}
 	fmt.Println("========================================")
 	pressEnterToContinue()
 }
@@ -326,7 +711,7 @@ func handleClearCart() {
 
 func showOrders() {
 	clearScreen()
-	fmt.Println("\n--- Your Orders ---\n")
+	fmt.Print("\n--- Your Orders ---\n\n")
 
 	orders, err := client.GetOrders()
 	if err != nil {
@@ -387,11 +772,349 @@ func showOrders() {
 	pressEnterToContinue()
 }
 
+// Sessions
+
+func showSessions() {
+	clearScreen()
+	fmt.Print("\n--- My Sessions ---\n\n")
+
+	sessions, err := client.GetSessions()
+	if err != nil {
+		fmt.Printf("Failed to fetch sessions: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("You have no active sessions.")
+		pressEnterToContinue()
+		return
+	}
+
+	// Display sessions
+	fmt.Printf("%-4s %-16s %-16s %-16s %-24s\n", "#", "Started", "Last Used", "IP Address", "Device")
+	fmt.Println(strings.Repeat("-", 80))
+	for i, s := range sessions {
+		device := s.UserAgent
+		if len(device) > 24 {
+			device = device[:21] + "..."
+		}
+		if s.Current {
+			device += " (this device)"
+		}
+		fmt.Printf("%-4d %-16s %-16s %-16s %-24s\n", i+1, s.CreatedAt.Local().Format("2006-01-02 15:04"), s.LastUsedAt.Local().Format("2006-01-02 15:04"), s.IPAddress, device)
+	}
+
+	fmt.Println()
+	fmt.Println("Enter session number to log it out, 'a' to log out everywhere, or 0 to go back.")
+	choice := prompt("Choice: ")
+
+	if choice == "0" || choice == "" {
+		return
+	}
+
+	if strings.ToLower(choice) == "a" {
+		confirm := prompt("Log out of every session, including this one? (y/n): ")
+		if strings.ToLower(confirm) != "y" {
+			fmt.Println("Cancelled.")
+			pressEnterToContinue()
+			return
+		}
+
+		if err := client.RevokeAllSessions(); err != nil {
+			fmt.Printf("Failed to log out everywhere: %s\n", err)
+			pressEnterToContinue()
+			return
+		}
+
+		client.Token = ""
+		currentUser = nil
+		clearScreen()
+		fmt.Println("Logged out of all sessions.")
+		return
+	}
+
+	num, err := strconv.Atoi(choice)
+	if err != nil || num < 1 || num > len(sessions) {
+		fmt.Println("Invalid session number.")
+		pressEnterToContinue()
+		return
+	}
+
+	session := sessions[num-1]
+	if err := client.RevokeSession(session.ID); err != nil {
+		fmt.Printf("Failed to log out session: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	if session.Current {
+		client.Token = ""
+		currentUser = nil
+		clearScreen()
+		fmt.Println("Logged out successfully.")
+		return
+	}
+
+	fmt.Println("Session logged out.")
+	pressEnterToContinue()
+}
+
+// Addresses
+
+func showAddresses() {
+	clearScreen()
+	fmt.Print("\n--- My Addresses ---\n\n")
+
+	addresses, err := client.GetAddresses()
+	if err != nil {
+		fmt.Printf("Failed to fetch addresses: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	if len(addresses) == 0 {
+		fmt.Println("You have no saved addresses.")
+	}
+	for i, a := range addresses {
+		var defaults []string
+		if a.IsDefaultShipping {
+			defaults = append(defaults, "default shipping")
+		}
+		if a.IsDefaultBilling {
+			defaults = append(defaults, "default billing")
+		}
+		label := a.Label
+		if len(defaults) > 0 {
+			label = strings.TrimSpace(label + " (" + strings.Join(defaults, ", ") + ")")
+		}
+		fmt.Printf("%d. %s\n", i+1, label)
+		fmt.Printf("   %s, %s, %s %s, %s\n", a.RecipientName, a.Line1, a.PostalCode, a.City, a.Country)
+	}
+
+	fmt.Println()
+	fmt.Println("Enter 'a' to add an address, an address number to manage it, or 0 to go back.")
+	choice := strings.ToLower(prompt("Choice: "))
+
+	if choice == "0" || choice == "" {
+		return
+	}
+
+	if choice == "a" {
+		handleAddAddress()
+		return
+	}
+
+	num, err := strconv.Atoi(choice)
+	if err != nil || num < 1 || num > len(addresses) {
+		fmt.Println("Invalid address number.")
+		pressEnterToContinue()
+		return
+	}
+
+	manageAddress(addresses[num-1])
+}
+
+func handleAddAddress() {
+	fmt.Print("\n--- Add Address ---\n\n")
+	address := Address{
+		Label:         prompt("Label (e.g. Home, optional): "),
+		RecipientName: prompt("Recipient name: "),
+		Line1:         prompt("Address line 1: "),
+		Line2:         prompt("Address line 2 (optional): "),
+		City:          prompt("City: "),
+		Region:        prompt("State/region (optional): "),
+		PostalCode:    prompt("Postal code: "),
+		Country:       prompt("Country code (e.g. US): "),
+		Phone:         prompt("Phone (optional): "),
+	}
+
+	if _, err := client.CreateAddress(address); err != nil {
+		fmt.Printf("Failed to add address: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	fmt.Println("Address added.")
+	pressEnterToContinue()
+}
+
+func manageAddress(address Address) {
+	fmt.Println()
+	fmt.Println("1. Make default for shipping and billing")
+	fmt.Println("2. Delete")
+	fmt.Println("0. Back")
+	fmt.Println()
+
+	switch prompt("Choice: ") {
+	case "1":
+		address.IsDefaultShipping = true
+		address.IsDefaultBilling = true
+		if err := client.UpdateAddress(address); err != nil {
+			fmt.Printf("Failed to update address: %s\n", err)
+			pressEnterToContinue()
+			return
+		}
+		fmt.Println("Default address updated.")
+	case "2":
+		if err := client.DeleteAddress(address.ID); err != nil {
+			fmt.Printf("Failed to delete address: %s\n", err)
+			pressEnterToContinue()
+			return
+		}
+		fmt.Println("Address deleted.")
+	default:
+		return
+	}
+	pressEnterToContinue()
+}
+
+// Two-factor authentication
+
+func showMFA() {
+	clearScreen()
+	fmt.Print("\n--- Two-Factor Authentication ---\n\n")
+
+	status, err := client.GetMFAStatus()
+	if err != nil {
+		fmt.Printf("Failed to fetch two-factor status: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	if !status.Enabled {
+		fmt.Println("Two-factor authentication is off.")
+		confirm := prompt("Set it up now? (y/n): ")
+		if strings.ToLower(confirm) != "y" {
+			return
+		}
+
+		enrollment, err := client.EnrollTOTP()
+		if err != nil {
+			fmt.Printf("Failed to start setup: %s\n", err)
+			pressEnterToContinue()
+			return
+		}
+
+		printEnrollment(enrollment)
+		codes, err := client.ConfirmTOTP(prompt("Authentication code: "))
+		if err != nil {
+			fmt.Printf("Failed to enable two-factor authentication: %s\n", err)
+			pressEnterToContinue()
+			return
+		}
+
+		fmt.Println("Two-factor authentication is on.")
+		printRecoveryCodes(codes)
+		pressEnterToContinue()
+		return
+	}
+
+	fmt.Println("Two-factor authentication is on.")
+	fmt.Printf("Unused recovery codes: %d\n", status.RecoveryCodesRemaining)
+	if status.Required {
+		fmt.Println("Your role requires it, so it can't be turned off.")
+		pressEnterToContinue()
+		return
+	}
+
+	fmt.Println()
+	confirm := prompt("Turn it off? (y/n): ")
+	if strings.ToLower(confirm) != "y" {
+		return
+	}
+
+	if err := client.DisableTOTP(prompt("Authentication code (or recovery code): ")); err != nil {
+		fmt.Printf("Failed to disable two-factor authentication: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	fmt.Println("Two-factor authentication is off.")
+	pressEnterToContinue()
+}
+
+// Account
+
+func showAccount() {
+	clearScreen()
+	fmt.Print("\n--- My Data and Account ---\n\n")
+	fmt.Println("1. Download my data")
+	fmt.Println("2. Delete my account")
+	fmt.Println("0. Back")
+	fmt.Println()
+
+	choice := prompt("Choice: ")
+
+	switch choice {
+	case "1":
+		handleExportData()
+	case "2":
+		handleDeleteAccount()
+	case "0":
+		return
+	default:
+		fmt.Println("Invalid choice.")
+		pressEnterToContinue()
+	}
+}
+
+func handleExportData() {
+	fmt.Println("Gathering your data...")
+	archive, err := client.ExportData()
+	if err != nil {
+		fmt.Printf("Failed to export data: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	filename := prompt("Save as [ecom-export.zip]: ")
+	if filename == "" {
+		filename = "ecom-export.zip"
+	}
+	if err := os.WriteFile(filename, archive, 0600); err != nil {
+		fmt.Printf("Failed to save export: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	fmt.Printf("Saved your data to %s\n", filename)
+	pressEnterToContinue()
+}
+
+func handleDeleteAccount() {
+	fmt.Println("Deleting your account is permanent. Your cart is emptied and your")
+	fmt.Println("orders are kept only in anonymized form.")
+	confirm := prompt("Type DELETE to continue: ")
+	if confirm != "DELETE" {
+		fmt.Println("Cancelled.")
+		pressEnterToContinue()
+		return
+	}
+
+	password := promptPassword("Password: ")
+	var code string
+	if status, err := client.GetMFAStatus(); err == nil && status.Enabled {
+		code = prompt("Authentication code (or recovery code): ")
+	}
+
+	if err := client.DeleteAccount(password, code); err != nil {
+		fmt.Printf("Failed to delete account: %s\n", err)
+		pressEnterToContinue()
+		return
+	}
+
+	client.Token = ""
+	currentUser = nil
+	clearScreen()
+	fmt.Println("Your account has been deleted.")
+}
+
 // Admin Menu
 
 func showAdminMenu() {
 	clearScreen()
-	fmt.Println("\n--- Admin: Manage Products ---\n")
+	fmt.Print("\n--- Admin: Manage Products ---\n\n")
 	fmt.Println("1. Add Product")
 	fmt.Println("2. Delete Product")
 	fmt.Println("0. Back")
@@ -412,7 +1135,7 @@ func showAdminMenu() {
 }
 
 func handleAddProduct() {
-	fmt.Println("\n--- Add New Product ---\n")
+	fmt.Print("\n--- Add New Product ---\n\n")
 
 	name := prompt("Product Name: ")
 	if name == "" {
@@ -424,10 +1147,11 @@ func handleAddProduct() {
 	description := prompt("Description (optional): ")
 	priceCents := promptPrice("Price (e.g., 19.99): ")
 	stock := promptInt("Initial Stock: ")
+	sku := prompt("SKU (optional): ")
 
 	fmt.Println("\nCreating product...")
 
-	product, err := client.CreateProduct(name, description, priceCents, stock)
+	product, err := client.CreateProduct(name, description, sku, priceCents, stock)
 	if err != nil {
 		fmt.Printf("Failed to create product: %s\n", err)
 		pressEnterToContinue()
@@ -442,63 +1166,190 @@ func handleAddProduct() {
 	fmt.Printf("Name: %s\n", product.Name)
 	fmt.Printf("Price: %s\n", formatPrice(product.PriceCents))
 	fmt.Printf("Stock: %d\n", product.Stock)
+	if len(product.Variants) > 0 {
+		fmt.Printf("SKU: %s\n", product.Variants[0].SKU)
+	}
 	fmt.Println("========================================")
 	pressEnterToContinue()
 }
 
 func handleDeleteProduct() {
-	clearScreen()
-	fmt.Println("\n--- Delete Product ---\n")
+	product, ok := browseProducts("Delete Product", "delete")
+	if !ok {
+		return
+	}
 
-	products, err := client.GetProducts()
-	if err != nil {
-		fmt.Printf("Failed to fetch products: %s\n", err)
+	confirm := prompt(fmt.Sprintf("Delete '%s'? This cannot be undone. (y/n): ", product.Name))
+	if strings.ToLower(confirm) != "y" {
+		fmt.Println("Cancelled.")
 		pressEnterToContinue()
 		return
 	}
 
-	if len(products) == 0 {
-		fmt.Println("No products available.")
+	err := client.DeleteProduct(product.ID)
+	if err != nil {
+		fmt.Printf("Failed to delete product: %s\n", err)
 		pressEnterToContinue()
 		return
 	}
 
-	// Display products
-	fmt.Printf("%-4s %-30s %-10s %-10s\n", "#", "Name", "Price", "Stock")
-	fmt.Println(strings.Repeat("-", 58))
-	for i, p := range products {
-		fmt.Printf("%-4d %-30s %-10s %-10d\n", i+1, p.Name, formatPrice(p.PriceCents), p.Stock)
-	}
+	fmt.Printf("Product '%s' deleted successfully!\n", product.Name)
+	pressEnterToContinue()
+}
 
-	fmt.Println()
-	fmt.Println("Enter product number to delete, or 0 to go back.")
-	choice := promptInt("Choice: ")
+// Admin: Users
 
-	if choice == 0 {
-		return
+const userPageSize = 10
+
+func showManageUsers() {
+	search := ""
+	offset := 0
+	for {
+		clearScreen()
+		fmt.Print("\n--- Admin: Manage Users ---\n\n")
+
+		page, err := client.ListUsers(search, userPageSize, offset)
+		if err != nil {
+			fmt.Printf("Failed to fetch users: %s\n", err)
+			pressEnterToContinue()
+			return
+		}
+
+		if search != "" {
+			fmt.Printf("Search: %q\n", search)
+		}
+		if len(page.Users) == 0 {
+			fmt.Println("No users found.")
+		} else {
+			fmt.Printf("%-4s %-32s %-8s %-10s\n", "#", "Email", "Role", "Status")
+			fmt.Println(strings.Repeat("-", 56))
+			for i, u := range page.Users {
+				fmt.Printf("%-4d %-32s %-8s %-10s\n", i+1, u.Email, u.Role, userStatus(u))
+			}
+			fmt.Printf("\nShowing %d-%d of %d\n", offset+1, offset+len(page.Users), page.Total)
+		}
+
+		fmt.Println()
+		fmt.Println("Enter user number to manage, 's' to search, 'n'/'p' for next/previous page, or 0 to go back.")
+		choice := strings.ToLower(prompt("Choice: "))
+
+		switch choice {
+		case "0", "":
+			return
+		case "s":
+			search = prompt("Email contains (blank for all): ")
+			offset = 0
+			continue
+		case "n":
+			if offset+userPageSize < page.Total {
+				offset += userPageSize
+			}
+			continue
+		case "p":
+			if offset >= userPageSize {
+				offset -= userPageSize
+			}
+			continue
+		}
+
+		num, err := strconv.Atoi(choice)
+		if err != nil || num < 1 || num > len(page.Users) {
+			fmt.Println("Invalid user number.")
+			pressEnterToContinue()
+			continue
+		}
+
+		manageUser(page.Users[num-1].ID)
 	}
+}
 
-	if choice < 1 || choice > len(products) {
-		fmt.Println("Invalid product number.")
+func userStatus(u AdminUser) string {
+	switch {
+	case u.Disabled:
+		return "disabled"
+	case !u.EmailVerified:
+		return "unverified"
+	default:
+		return "active"
+	}
+}
+
+func manageUser(userID string) {
+	clearScreen()
+	user, err := client.GetUser(userID)
+	if err != nil {
+		fmt.Printf("Failed to fetch user: %s\n", err)
 		pressEnterToContinue()
 		return
 	}
 
-	product := products[choice-1]
-	confirm := prompt(fmt.Sprintf("Delete '%s'? This cannot be undone. (y/n): ", product.Name))
-	if strings.ToLower(confirm) != "y" {
-		fmt.Println("Cancelled.")
+	fmt.Printf("\n--- User: %s ---\n\n", user.Email)
+	fmt.Printf("ID:         %s\n", user.ID)
+	fmt.Printf("Role:       %s\n", user.Role)
+	fmt.Printf("Status:     %s\n", userStatus(*user))
+	fmt.Printf("Two-factor: %t\n", user.MFAEnabled)
+	fmt.Printf("Joined:     %s\n", user.CreatedAt.Local().Format("2006-01-02 15:04"))
+	fmt.Println()
+
+	newRole := "admin"
+	if user.Role == "admin" {
+		newRole = "user"
+	}
+	fmt.Printf("1. Change role to %s\n", newRole)
+	if user.Disabled {
+		fmt.Println("2. Enable account")
+	} else {
+		fmt.Println("2. Disable account")
+	}
+	fmt.Println("3. Force logout")
+	fmt.Println("4. Unlock login")
+	fmt.Println("5. Delete account")
+	fmt.Println("0. Back")
+	fmt.Println()
+
+	choice := prompt("Choice: ")
+
+	switch choice {
+	case "1":
+		err = client.SetUserRole(user.ID, newRole)
+	case "2":
+		if user.Disabled {
+			err = client.EnableUser(user.ID)
+		} else {
+			confirm := prompt(fmt.Sprintf("Disable %s and log them out? (y/n): ", user.Email))
+			if strings.ToLower(confirm) != "y" {
+				fmt.Println("Cancelled.")
+				pressEnterToContinue()
+				return
+			}
+			err = client.DisableUser(user.ID)
+		}
+	case "3":
+		err = client.ForceLogout(user.ID)
+	case "4":
+		err = client.UnlockUser(user.ID)
+	case "5":
+		confirm := prompt(fmt.Sprintf("Type %s to permanently delete this account: ", user.Email))
+		if confirm != user.Email {
+			fmt.Println("Cancelled.")
+			pressEnterToContinue()
+			return
+		}
+		err = client.DeleteUser(user.ID)
+	case "0":
+		return
+	default:
+		fmt.Println("Invalid choice.")
 		pressEnterToContinue()
 		return
 	}
 
-	err = client.DeleteProduct(product.ID)
 	if err != nil {
-		fmt.Printf("Failed to delete product: %s\n", err)
+		fmt.Printf("Failed: %s\n", err)
 		pressEnterToContinue()
 		return
 	}
 
-	fmt.Printf("Product '%s' deleted successfully!\n", product.Name)
+	fmt.Println("Done.")
 	pressEnterToContinue()
 }
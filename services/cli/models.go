@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 type User struct {
 	ID    string `json:"id"`
 	Email string `json:"email"`
@@ -7,20 +9,132 @@ type User struct {
 }
 
 type LoginResponse struct {
-	User  User   `json:"user"`
-	Token string `json:"token"`
+	User             User     `json:"user"`
+	Token            string   `json:"token"`
+	RecoveryCodes    []string `json:"recovery_codes"`
+	MFARequired      bool     `json:"mfa_required"`
+	MFASetupRequired bool     `json:"mfa_setup_required"`
+	MFAToken         string   `json:"mfa_token"`
+}
+
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"`
+}
+
+type AdminUser struct {
+	ID            string    `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	Disabled      bool      `json:"disabled"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+}
+
+type UserPage struct {
+	Users  []AdminUser `json:"users"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+type Address struct {
+	ID                string `json:"id,omitempty"`
+	Label             string `json:"label"`
+	RecipientName     string `json:"recipient_name"`
+	Line1             string `json:"line1"`
+	Line2             string `json:"line2"`
+	City              string `json:"city"`
+	Region            string `json:"region"`
+	PostalCode        string `json:"postal_code"`
+	Country           string `json:"country"`
+	Phone             string `json:"phone"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
 }
 
 type Product struct {
-	ID         string `json:"ID"`
-	Name       string `json:"Name"`
-	PriceCents int    `json:"PriceCents"`
-	Stock      int    `json:"Stock"`
+	ID         string    `json:"ID"`
+	Name       string    `json:"Name"`
+	PriceCents int       `json:"PriceCents"`
+	Stock      int       `json:"Stock"`
+	Variants   []Variant `json:"variants"`
+}
+
+// Variant is one purchasable version of a product, such as a size or color.
+// PriceCents is what it sells for, whether or not it overrides the product's
+// price.
+type Variant struct {
+	ID         string            `json:"id"`
+	SKU        string            `json:"sku"`
+	Options    map[string]string `json:"options"`
+	PriceCents int               `json:"price_cents"`
+	Stock      int               `json:"stock"`
+	IsDefault  bool              `json:"is_default"`
+}
+
+// ProductPage is one page of GET /api/products. NextCursor is empty on the
+// last page.
+type ProductPage struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor"`
+}
+
+// Category is a node of GET /api/categories.
+type Category struct {
+	ID       string     `json:"id"`
+	ParentID string     `json:"parent_id"`
+	Name     string     `json:"name"`
+	Slug     string     `json:"slug"`
+	Children []Category `json:"children"`
+}
+
+// SearchResult is one match from GET /api/products/search. Headline and
+// Snippet mark matched words with ** on either side.
+type SearchResult struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	PriceCents int     `json:"price_cents"`
+	Stock      int     `json:"stock"`
+	Rank       float64 `json:"rank"`
+	Headline   string  `json:"headline"`
+	Snippet    string  `json:"snippet"`
+}
+
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// ProductFilter narrows a product listing. Zero values mean no filter.
+type ProductFilter struct {
+	Sort          string
+	Category      *Category
+	MinPriceCents int
+	MaxPriceCents int
+	InStock       bool
 }
 
 type CartItem struct {
 	ID         string `json:"id"`
 	ProductID  string `json:"product_id"`
+	VariantID  string `json:"variant_id"`
 	Quantity   int    `json:"quantity"`
 	PriceCents int    `json:"price_cents"`
 }
@@ -32,8 +146,17 @@ type Cart struct {
 }
 
 type Order struct {
-	ID         string `json:"ID"`
-	Status     string `json:"Status"`
-	TotalCents int    `json:"TotalCents"`
-	CreatedAt  string `json:"CreatedAt"`
+	ID              string        `json:"ID"`
+	Status          string        `json:"Status"`
+	TotalCents      int           `json:"TotalCents"`
+	CreatedAt       string        `json:"CreatedAt"`
+	ShippingAddress *OrderAddress `json:"ShippingAddress"`
+}
+
+type OrderAddress struct {
+	RecipientName string `json:"recipient_name"`
+	Line1         string `json:"line1"`
+	City          string `json:"city"`
+	PostalCode    string `json:"postal_code"`
+	Country       string `json:"country"`
 }
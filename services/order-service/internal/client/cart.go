@@ -2,37 +2,47 @@ package client
 
 import (
 	"context"
-	"net/http"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/requestid"
 )
 
 type CartClient struct {
-    BaseURL    string
-    HTTPClient *http.Client
+	BaseURL    string
+	HTTPClient *http.Client
+	Signer     *internalauth.Signer
 }
 
 type CartItem struct {
-    ID         uuid.UUID `json:"id"`
-    ProductID  uuid.UUID `json:"product_id"`
-    Quantity   int32     `json:"quantity"`
-    PriceCents int32     `json:"price_cents"`
+	ID         uuid.UUID `json:"id"`
+	ProductID  uuid.UUID `json:"product_id"`
+	VariantID  uuid.UUID `json:"variant_id"`
+	Quantity   int32     `json:"quantity"`
+	PriceCents int32     `json:"price_cents"`
 }
 
 type Cart struct {
-    ID         uuid.UUID  `json:"id"`
-    Items      []CartItem `json:"items"`
-    TotalCents int64      `json:"total_cents"`
+	ID         uuid.UUID  `json:"id"`
+	Items      []CartItem `json:"items"`
+	TotalCents int64      `json:"total_cents"`
 }
 
-func NewCartClient(baseURL string, timeout time.Duration) *CartClient {
-    return &CartClient{
-        BaseURL:    baseURL,
-        HTTPClient: &http.Client{Timeout: timeout},
-    }
+func NewCartClient(baseURL string, timeout time.Duration, signer *internalauth.Signer) *CartClient {
+	return &CartClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout:   timeout,
+			Transport: metrics.Transport("cart-service", http.DefaultTransport),
+		},
+		Signer: signer,
+	}
 }
 
 func (c *CartClient) GetCart(ctx context.Context, userID uuid.UUID) (*Cart, bool, error) {
@@ -45,6 +55,12 @@ func (c *CartClient) GetCart(ctx context.Context, userID uuid.UUID) (*Cart, bool
 	if err != nil {
 		return nil, false, fmt.Errorf("error getting the response: %w", err)
 	}
+	if err := c.Signer.Sign(req, "", ""); err != nil {
+		return nil, false, fmt.Errorf("signing request: %w", err)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, false, fmt.Errorf("calling cart service: %w", err)
@@ -73,6 +89,12 @@ func (c *CartClient) ClearCart(ctx context.Context, userID uuid.UUID) error {
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
+	if err := c.Signer.Sign(req, "", ""); err != nil {
+		return fmt.Errorf("signing request: %w", err)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("calling cart service: %w", err)
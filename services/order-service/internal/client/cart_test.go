@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/requestid"
+)
+
+func TestCartClientForwardsRequestID(t *testing.T) {
+	signer := internalauth.NewSigner("test-key", "order-service")
+	userID := uuid.New()
+
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signer.Verify(r); err != nil {
+			t.Errorf("%s %s not signed: %v", r.Method, r.URL.Path, err)
+		}
+		if r.URL.Path != "/internal/cart/"+userID.String() {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		seen = append(seen, r.Method+" "+r.Header.Get(requestid.Header))
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(Cart{ID: uuid.New()})
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c := NewCartClient(server.URL, time.Second, signer)
+	ctx := requestid.NewContext(context.Background(), "req-123")
+	if _, found, err := c.GetCart(ctx, userID); err != nil || !found {
+		t.Fatalf("GetCart: found %v, err %v", found, err)
+	}
+	if err := c.ClearCart(ctx, userID); err != nil {
+		t.Fatalf("ClearCart: %v", err)
+	}
+	if _, _, err := c.GetCart(context.Background(), userID); err != nil {
+		t.Fatalf("GetCart: %v", err)
+	}
+
+	want := []string{"GET req-123", "DELETE req-123", "GET "}
+	if len(seen) != len(want) {
+		t.Fatalf("requests = %q, want %q", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, seen[i], want[i])
+		}
+	}
+}
@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
 	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/requestid"
 )
 
 type Product struct {
@@ -19,13 +24,16 @@ type Product struct {
 type ProductClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	Signer     *internalauth.Signer
 }
 
-func NewProductClient(baseURL string, timeout time.Duration) *ProductClient {
+func NewProductClient(baseURL string, timeout time.Duration, signer *internalauth.Signer) *ProductClient {
 	return &ProductClient{
 		BaseURL: baseURL,
+		Signer:  signer,
 		HTTPClient: &http.Client{
-			Timeout: timeout,
+			Timeout:   timeout,
+			Transport: metrics.Transport("product-service", http.DefaultTransport),
 		},
 	}
 }
@@ -39,6 +47,12 @@ func (c *ProductClient) GetProduct(ctx context.Context, productID uuid.UUID) (*P
 	if err != nil {
 		return nil, false, fmt.Errorf("creating request: %w", err)
 	}
+	if err := c.Signer.Sign(req, "", ""); err != nil {
+		return nil, false, fmt.Errorf("signing request: %w", err)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return nil, false, fmt.Errorf("calling product service: %w", err)
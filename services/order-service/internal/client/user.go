@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/requestid"
+)
+
+type UserClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Signer     *internalauth.Signer
+}
+
+type UserStatus struct {
+	UserID        uuid.UUID `json:"user_id"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+// Address is an entry in the user's address book.
+type Address struct {
+	ID                uuid.UUID `json:"id"`
+	Label             string    `json:"label"`
+	RecipientName     string    `json:"recipient_name"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2"`
+	City              string    `json:"city"`
+	Region            string    `json:"region"`
+	PostalCode        string    `json:"postal_code"`
+	Country           string    `json:"country"`
+	Phone             string    `json:"phone"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+}
+
+func NewUserClient(baseURL string, timeout time.Duration, signer *internalauth.Signer) *UserClient {
+	return &UserClient{
+		BaseURL: baseURL,
+		HTTPClient: &http.Client{
+			Timeout:   timeout,
+			Transport: metrics.Transport("user-service", http.DefaultTransport),
+		},
+		Signer: signer,
+	}
+}
+
+// GetStatus reports what the user is allowed to do, e.g. whether they have
+// verified their email address.
+func (c *UserClient) GetStatus(ctx context.Context, userID uuid.UUID) (*UserStatus, error) {
+	if userID == uuid.Nil {
+		return nil, fmt.Errorf("invalid UUID: nil")
+	}
+
+	url := fmt.Sprintf("%s/internal/users/%s/status", c.BaseURL, userID.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if err := c.Signer.Sign(req, "", ""); err != nil {
+		return nil, fmt.Errorf("signing request: %w", err)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling user service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var status UserStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &status, nil
+}
+
+func (c *UserClient) GetAddresses(ctx context.Context, userID uuid.UUID) ([]Address, error) {
+	if userID == uuid.Nil {
+		return nil, fmt.Errorf("invalid UUID: nil")
+	}
+
+	url := fmt.Sprintf("%s/internal/users/%s/addresses", c.BaseURL, userID.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if err := c.Signer.Sign(req, "", ""); err != nil {
+		return nil, fmt.Errorf("signing request: %w", err)
+	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling user service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	var addresses []Address
+	if err := json.NewDecoder(resp.Body).Decode(&addresses); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return addresses, nil
+}
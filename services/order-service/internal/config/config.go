@@ -1,14 +1,20 @@
 package config
 
 import (
-	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/database"
+	"database/sql"
+
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/client"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/database"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/rabbitmq"
 )
+
 type Config struct {
 	DB            *database.Queries
+	DBConn        *sql.DB
 	Platform      string
 	ProductClient *client.ProductClient
 	CartClient    *client.CartClient
+	UserClient    *client.UserClient
 	Publisher     *rabbitmq.Publisher
+	Consumer      *rabbitmq.Consumer
 }
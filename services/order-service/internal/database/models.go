@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -58,12 +59,14 @@ func (ns NullOrderStatus) Value() (driver.Value, error) {
 }
 
 type Order struct {
-	ID         uuid.UUID
-	UserID     uuid.UUID
-	Status     OrderStatus
-	TotalCents int32
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	Status          OrderStatus
+	TotalCents      int32
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	ShippingAddress json.RawMessage
+	BillingAddress  json.RawMessage
 }
 
 type OrderItem struct {
@@ -73,4 +76,5 @@ type OrderItem struct {
 	Quantity   int32
 	PriceCents int32
 	CreatedAt  time.Time
+	VariantID  uuid.UUID
 }
@@ -7,30 +7,61 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/google/uuid"
 )
 
+const anonymizeUserOrders = `-- name: AnonymizeUserOrders :execrows
+UPDATE orders
+SET
+  user_id = '00000000-0000-0000-0000-000000000000',
+  shipping_address = 'null',
+  billing_address = 'null',
+  updated_at = now()
+WHERE user_id = $1
+`
+
+func (q *Queries) AnonymizeUserOrders(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, anonymizeUserOrders, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createOrder = `-- name: CreateOrder :one
 INSERT INTO orders (
   user_id,
   status,
-  total_cents
+  total_cents,
+  shipping_address,
+  billing_address
 ) VALUES (
   $1,
   $2,
-  $3
-) RETURNING id, user_id, status, total_cents, created_at, updated_at
+  $3,
+  $4,
+  $5
+) RETURNING id, user_id, status, total_cents, created_at, updated_at, shipping_address, billing_address
 `
 
 type CreateOrderParams struct {
-	UserID     uuid.UUID
-	Status     OrderStatus
-	TotalCents int32
+	UserID          uuid.UUID
+	Status          OrderStatus
+	TotalCents      int32
+	ShippingAddress json.RawMessage
+	BillingAddress  json.RawMessage
 }
 
 func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) (Order, error) {
-	row := q.db.QueryRowContext(ctx, createOrder, arg.UserID, arg.Status, arg.TotalCents)
+	row := q.db.QueryRowContext(ctx, createOrder,
+		arg.UserID,
+		arg.Status,
+		arg.TotalCents,
+		arg.ShippingAddress,
+		arg.BillingAddress,
+	)
 	var i Order
 	err := row.Scan(
 		&i.ID,
@@ -39,19 +70,22 @@ func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) (Order
 		&i.TotalCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ShippingAddress,
+		&i.BillingAddress,
 	)
 	return i, err
 }
 
 const createOrderItem = `-- name: CreateOrderItem :one
-INSERT INTO order_items (order_id, product_id, quantity, price_cents)
-VALUES ($1, $2, $3, $4)
-RETURNING id, order_id, product_id, quantity, price_cents, created_at
+INSERT INTO order_items (order_id, product_id, variant_id, quantity, price_cents)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, order_id, product_id, quantity, price_cents, created_at, variant_id
 `
 
 type CreateOrderItemParams struct {
 	OrderID    uuid.UUID
 	ProductID  uuid.UUID
+	VariantID  uuid.UUID
 	Quantity   int32
 	PriceCents int32
 }
@@ -60,6 +94,7 @@ func (q *Queries) CreateOrderItem(ctx context.Context, arg CreateOrderItemParams
 	row := q.db.QueryRowContext(ctx, createOrderItem,
 		arg.OrderID,
 		arg.ProductID,
+		arg.VariantID,
 		arg.Quantity,
 		arg.PriceCents,
 	)
@@ -71,6 +106,7 @@ func (q *Queries) CreateOrderItem(ctx context.Context, arg CreateOrderItemParams
 		&i.Quantity,
 		&i.PriceCents,
 		&i.CreatedAt,
+		&i.VariantID,
 	)
 	return i, err
 }
@@ -85,7 +121,7 @@ func (q *Queries) DeleteOrder(ctx context.Context, id uuid.UUID) error {
 }
 
 const getOrderByID = `-- name: GetOrderByID :one
-SELECT id, user_id, status, total_cents, created_at, updated_at FROM orders WHERE id = $1
+SELECT id, user_id, status, total_cents, created_at, updated_at, shipping_address, billing_address FROM orders WHERE id = $1
 `
 
 func (q *Queries) GetOrderByID(ctx context.Context, id uuid.UUID) (Order, error) {
@@ -98,12 +134,14 @@ func (q *Queries) GetOrderByID(ctx context.Context, id uuid.UUID) (Order, error)
 		&i.TotalCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ShippingAddress,
+		&i.BillingAddress,
 	)
 	return i, err
 }
 
 const getOrderItems = `-- name: GetOrderItems :many
-SELECT id, order_id, product_id, quantity, price_cents, created_at FROM order_items WHERE order_id = $1
+SELECT id, order_id, product_id, quantity, price_cents, created_at, variant_id FROM order_items WHERE order_id = $1
 `
 
 func (q *Queries) GetOrderItems(ctx context.Context, orderID uuid.UUID) ([]OrderItem, error) {
@@ -122,6 +160,7 @@ func (q *Queries) GetOrderItems(ctx context.Context, orderID uuid.UUID) ([]Order
 			&i.Quantity,
 			&i.PriceCents,
 			&i.CreatedAt,
+			&i.VariantID,
 		); err != nil {
 			return nil, err
 		}
@@ -137,7 +176,7 @@ func (q *Queries) GetOrderItems(ctx context.Context, orderID uuid.UUID) ([]Order
 }
 
 const getOrdersByUserID = `-- name: GetOrdersByUserID :many
-SELECT id, user_id, status, total_cents, created_at, updated_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC
+SELECT id, user_id, status, total_cents, created_at, updated_at, shipping_address, billing_address FROM orders WHERE user_id = $1 ORDER BY created_at DESC
 `
 
 func (q *Queries) GetOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]Order, error) {
@@ -156,6 +195,8 @@ func (q *Queries) GetOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]Or
 			&i.TotalCents,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.ShippingAddress,
+			&i.BillingAddress,
 		); err != nil {
 			return nil, err
 		}
@@ -171,7 +212,7 @@ func (q *Queries) GetOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]Or
 }
 
 const updateOrderStatus = `-- name: UpdateOrderStatus :one
-UPDATE orders SET status = $2, updated_at = now() WHERE id = $1 RETURNING id, user_id, status, total_cents, created_at, updated_at
+UPDATE orders SET status = $2, updated_at = now() WHERE id = $1 RETURNING id, user_id, status, total_cents, created_at, updated_at, shipping_address, billing_address
 `
 
 type UpdateOrderStatusParams struct {
@@ -189,6 +230,8 @@ func (q *Queries) UpdateOrderStatus(ctx context.Context, arg UpdateOrderStatusPa
 		&i.TotalCents,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ShippingAddress,
+		&i.BillingAddress,
 	)
 	return i, err
 }
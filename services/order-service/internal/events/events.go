@@ -7,6 +7,7 @@ import (
 
 type OrderItem struct {
 	ProductID uuid.UUID `json:"product_id"`
+	VariantID uuid.UUID `json:"variant_id"`
 	Quantity  int32     `json:"quantity"`
 }
 
@@ -23,3 +24,9 @@ type OrderCancelledEvent struct {
 	Items     []OrderItem `json:"items"`
 	Timestamp time.Time   `json:"timestamp"`
 }
+
+// UserDeletedEvent is published by user-service when an account is deleted.
+type UserDeletedEvent struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/client"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/config"
+)
+
+// addressSnapshot is the copy of an address stored on an order.
+type addressSnapshot struct {
+	RecipientName string `json:"recipient_name"`
+	Line1         string `json:"line1"`
+	Line2         string `json:"line2,omitempty"`
+	City          string `json:"city"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code"`
+	Country       string `json:"country"`
+	Phone         string `json:"phone,omitempty"`
+}
+
+// addressNotFoundError means the order named an address the user doesn't
+// have.
+type addressNotFoundError struct {
+	kind string
+}
+
+func (e addressNotFoundError) Error() string {
+	return e.kind + " address not found"
+}
+
+// pickAddress returns the address with id, or the default chosen by
+// isDefault when id is nil. It returns nil if there is no default.
+func pickAddress(addresses []client.Address, id *uuid.UUID, kind string, isDefault func(client.Address) bool) (*client.Address, error) {
+	for i, a := range addresses {
+		if (id != nil && a.ID == *id) || (id == nil && isDefault(a)) {
+			return &addresses[i], nil
+		}
+	}
+	if id != nil {
+		return nil, addressNotFoundError{kind: kind}
+	}
+	return nil, nil
+}
+
+func snapshotJSON(a *client.Address) (json.RawMessage, error) {
+	if a == nil {
+		return json.RawMessage("null"), nil
+	}
+	return json.Marshal(addressSnapshot{
+		RecipientName: a.RecipientName,
+		Line1:         a.Line1,
+		Line2:         a.Line2,
+		City:          a.City,
+		Region:        a.Region,
+		PostalCode:    a.PostalCode,
+		Country:       a.Country,
+		Phone:         a.Phone,
+	})
+}
+
+// snapshotAddresses copies the shipping and billing addresses for a new
+// order from the user's address book. Unnamed addresses fall back to the
+// user's defaults, and billing falls back to shipping.
+func snapshotAddresses(ctx context.Context, cfg *config.Config, userID uuid.UUID, shippingID, billingID *uuid.UUID) (json.RawMessage, json.RawMessage, error) {
+	addresses, err := cfg.UserClient.GetAddresses(ctx, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting addresses: %w", err)
+	}
+
+	shipping, err := pickAddress(addresses, shippingID, "shipping", func(a client.Address) bool { return a.IsDefaultShipping })
+	if err != nil {
+		return nil, nil, err
+	}
+	billing, err := pickAddress(addresses, billingID, "billing", func(a client.Address) bool { return a.IsDefaultBilling })
+	if err != nil {
+		return nil, nil, err
+	}
+	if billing == nil {
+		billing = shipping
+	}
+
+	shippingJSON, err := snapshotJSON(shipping)
+	if err != nil {
+		return nil, nil, err
+	}
+	billingJSON, err := snapshotJSON(billing)
+	if err != nil {
+		return nil, nil, err
+	}
+	return shippingJSON, billingJSON, nil
+}
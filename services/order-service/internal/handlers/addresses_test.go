@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/client"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/internalauth"
+)
+
+func testAddress(name string, shipping, billing bool) client.Address {
+	return client.Address{
+		ID:                uuid.New(),
+		RecipientName:     name,
+		Line1:             "1 Main St",
+		City:              "Springfield",
+		PostalCode:        "12345",
+		Country:           "US",
+		IsDefaultShipping: shipping,
+		IsDefaultBilling:  billing,
+	}
+}
+
+// addressBookConfig returns a config whose user-service serves addresses.
+func addressBookConfig(t *testing.T, userID uuid.UUID, addresses []client.Address) *config.Config {
+	t.Helper()
+	signer := internalauth.NewSigner("test-key", "order-service")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := signer.Verify(r); err != nil {
+			t.Errorf("address request not signed: %v", err)
+		}
+		if r.URL.Path != "/internal/users/"+userID.String()+"/addresses" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(addresses)
+	}))
+	t.Cleanup(server.Close)
+	return &config.Config{UserClient: client.NewUserClient(server.URL, time.Second, signer)}
+}
+
+func decodeSnapshot(t *testing.T, raw json.RawMessage) *addressSnapshot {
+	t.Helper()
+	var s *addressSnapshot
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSnapshotAddresses(t *testing.T) {
+	home := testAddress("Home", true, false)
+	office := testAddress("Office", false, true)
+	other := testAddress("Other", false, false)
+	missing := uuid.New()
+
+	tests := []struct {
+		name         string
+		addresses    []client.Address
+		shippingID   *uuid.UUID
+		billingID    *uuid.UUID
+		wantShipping string
+		wantBilling  string
+		wantNotFound bool
+	}{
+		{
+			name:         "defaults",
+			addresses:    []client.Address{home, office, other},
+			wantShipping: "Home",
+			wantBilling:  "Office",
+		},
+		{
+			name:         "named addresses",
+			addresses:    []client.Address{home, office, other},
+			shippingID:   &other.ID,
+			billingID:    &home.ID,
+			wantShipping: "Other",
+			wantBilling:  "Home",
+		},
+		{
+			name:         "billing falls back to shipping",
+			addresses:    []client.Address{home, other},
+			wantShipping: "Home",
+			wantBilling:  "Home",
+		},
+		{
+			name:      "empty address book",
+			addresses: []client.Address{},
+		},
+		{
+			name:         "unknown shipping address",
+			addresses:    []client.Address{home},
+			shippingID:   &missing,
+			wantNotFound: true,
+		},
+		{
+			name:         "unknown billing address",
+			addresses:    []client.Address{home},
+			billingID:    &missing,
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			cfg := addressBookConfig(t, userID, tt.addresses)
+
+			shipping, billing, err := snapshotAddresses(context.Background(), cfg, userID, tt.shippingID, tt.billingID)
+			if tt.wantNotFound {
+				var notFound addressNotFoundError
+				if !errors.As(err, &notFound) {
+					t.Fatalf("err = %v, want addressNotFoundError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("snapshotAddresses: %v", err)
+			}
+
+			for _, c := range []struct {
+				kind string
+				raw  json.RawMessage
+				want string
+			}{
+				{"shipping", shipping, tt.wantShipping},
+				{"billing", billing, tt.wantBilling},
+			} {
+				s := decodeSnapshot(t, c.raw)
+				if c.want == "" {
+					if s != nil {
+						t.Errorf("%s = %+v, want null", c.kind, s)
+					}
+					continue
+				}
+				if s == nil || s.RecipientName != c.want {
+					t.Errorf("%s = %+v, want %s", c.kind, s, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotJSONOmitsBookFields(t *testing.T) {
+	a := testAddress("Home", true, true)
+	a.Label = "My place"
+	raw, err := snapshotJSON(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"id", "label", "is_default_shipping", "is_default_billing", "line2", "region", "phone"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("snapshot has %q: %s", key, raw)
+		}
+	}
+}
@@ -1,25 +1,32 @@
 package handlers
 
 import (
-	"net/http"
 	"database/sql"
-	"log"
-	"errors"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
 	"time"
-	
-	"github.com/google/uuid" 
+
+	"github.com/google/uuid"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/config"
-	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/response"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/database"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/events"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/response"
 )
 
 func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "order-service"})
+	mux.HandleFunc("GET /health", handlerLive)
+	mux.HandleFunc("GET /health/live", handlerLive)
+
+	mux.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		handlerReady(cfg, w, r)
 	})
 
+	mux.Handle("GET /metrics", metrics.Handler())
+
 	mux.HandleFunc("POST /api/orders", func(w http.ResponseWriter, r *http.Request) {
 		handlerCreateOrder(cfg, w, r)
 	})
@@ -39,11 +46,20 @@ func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 	mux.HandleFunc("PATCH /internal/orders/{orderID}/status", func(w http.ResponseWriter, r *http.Request) {
 		handlerUpdateStatus(cfg, w, r)
 	})
+
+	mux.HandleFunc("GET /internal/users/{userID}/orders", func(w http.ResponseWriter, r *http.Request) {
+		handlerInternalUserOrders(cfg, w, r)
+	})
 }
 
 type OrderResponse struct {
-    Order database.Order       `json:"order"`
-    Items []database.OrderItem `json:"items"`
+	Order database.Order       `json:"order"`
+	Items []database.OrderItem `json:"items"`
+}
+
+type createOrderParams struct {
+	ShippingAddressID *uuid.UUID `json:"shipping_address_id"`
+	BillingAddressID  *uuid.UUID `json:"billing_address_id"`
 }
 
 func handlerCreateOrder(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
@@ -58,6 +74,34 @@ func handlerCreateOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	status, err := cfg.UserClient.GetStatus(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "error getting user status", err)
+		return
+	}
+	if !status.EmailVerified {
+		response.RespondWithError(w, http.StatusForbidden, "verify your email address before checking out", nil)
+		return
+	}
+
+	// The body is optional; without it the user's default addresses are used
+	params := createOrderParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	shippingAddress, billingAddress, err := snapshotAddresses(r.Context(), cfg, userID, params.ShippingAddressID, params.BillingAddressID)
+	var notFound addressNotFoundError
+	if errors.As(err, &notFound) {
+		response.RespondWithError(w, http.StatusBadRequest, notFound.Error(), nil)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "error getting addresses", err)
+		return
+	}
+
 	cart, exists, err := cfg.CartClient.GetCart(r.Context(), userID)
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "error getting cart", err)
@@ -75,9 +119,11 @@ func handlerCreateOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 	}
 
 	order, err := cfg.DB.CreateOrder(r.Context(), database.CreateOrderParams{
-		UserID: userID,
-		Status: "pending",
-		TotalCents: int32(cart.TotalCents),
+		UserID:          userID,
+		Status:          "pending",
+		TotalCents:      int32(cart.TotalCents),
+		ShippingAddress: shippingAddress,
+		BillingAddress:  billingAddress,
 	})
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "could not create order", err)
@@ -86,14 +132,15 @@ func handlerCreateOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 
 	for _, item := range cart.Items {
 		_, err := cfg.DB.CreateOrderItem(r.Context(), database.CreateOrderItemParams{
-			OrderID : order.ID,
-			ProductID : item.ProductID,
-			Quantity : item.Quantity,
-			PriceCents : item.PriceCents,
+			OrderID:    order.ID,
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Quantity:   item.Quantity,
+			PriceCents: item.PriceCents,
 		})
 		if err != nil {
-    	response.RespondWithError(w, http.StatusInternalServerError, "could not create order item", err)
-    	return
+			response.RespondWithError(w, http.StatusInternalServerError, "could not create order item", err)
+			return
 		}
 	}
 
@@ -101,29 +148,30 @@ func handlerCreateOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 	for i, item := range cart.Items {
 		eventItems[i] = events.OrderItem{
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
 			Quantity:  item.Quantity,
 		}
 	}
-	
+
 	event := events.OrderCreatedEvent{
-		OrderID:  order.ID,
-		UserID:   userID,
-		Items:    eventItems,
-		Timestamp:time.Now(),
+		OrderID:   order.ID,
+		UserID:    userID,
+		Items:     eventItems,
+		Timestamp: time.Now(),
 	}
 
-	err = cfg.Publisher.Publish("order.created", event)
+	err = cfg.Publisher.Publish(r.Context(), "order.created", event)
 	if err != nil {
 		cfg.DB.DeleteOrder(r.Context(), order.ID)
-    response.RespondWithError(w, http.StatusInternalServerError, "failed to publish order event", err)
-    return
+		response.RespondWithError(w, http.StatusInternalServerError, "failed to publish order event", err)
+		return
 	}
 
 	err = cfg.CartClient.ClearCart(r.Context(), userID)
 	if err != nil {
-    log.Printf("warning: failed to clear cart: %v", err)
+		log.Printf("warning: failed to clear cart: %v", err)
 	}
-	
+
 	response.RespondWithJSON(w, http.StatusCreated, order)
 }
 
@@ -168,7 +216,7 @@ func handlerGetOrder(cfg *config.Config, w http.ResponseWriter, r *http.Request)
 	}
 
 	order, err := cfg.DB.GetOrderByID(r.Context(), orderID)
-  if err != nil {
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			response.RespondWithError(w, http.StatusNotFound, "order not found", nil)
 			return
@@ -179,14 +227,14 @@ func handlerGetOrder(cfg *config.Config, w http.ResponseWriter, r *http.Request)
 
 	if order.UserID != userID {
 		response.RespondWithError(w, http.StatusForbidden, "order does not belong to you", nil)
-    return
+		return
 	}
 
 	items, err := cfg.DB.GetOrderItems(r.Context(), orderID)
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "could not get order items", err)
 		return
-  }
+	}
 
 	response.RespondWithJSON(w, http.StatusOK, OrderResponse{
 		Order: order,
@@ -209,7 +257,7 @@ func handlerCancelOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 	}
 
 	order, err := cfg.DB.GetOrderByID(r.Context(), orderID)
-  if err != nil {
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			response.RespondWithError(w, http.StatusNotFound, "order not found", nil)
 			return
@@ -220,21 +268,21 @@ func handlerCancelOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 
 	if order.UserID != userID {
 		response.RespondWithError(w, http.StatusForbidden, "order does not belong to you", nil)
-    return
+		return
 	}
 
 	if order.Status != "pending" {
 		response.RespondWithError(w, http.StatusBadRequest, "can only cancel pending orders", nil)
-    return
+		return
 	}
-	
+
 	updatedOrder, err := cfg.DB.UpdateOrderStatus(r.Context(), database.UpdateOrderStatusParams{
-		ID: order.ID,
-		Status:  database.OrderStatusCancelled,
+		ID:     order.ID,
+		Status: database.OrderStatusCancelled,
 	})
 	if err != nil {
-    response.RespondWithError(w, http.StatusInternalServerError, "could not cancel order", err)
-    return
+		response.RespondWithError(w, http.StatusInternalServerError, "could not cancel order", err)
+		return
 	}
 
 	items, err := cfg.DB.GetOrderItems(r.Context(), orderID)
@@ -247,20 +295,21 @@ func handlerCancelOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 	for i, item := range items {
 		eventItems[i] = events.OrderItem{
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
 			Quantity:  item.Quantity,
 		}
 	}
-	
+
 	event := events.OrderCancelledEvent{
-		OrderID:  order.ID,
-		UserID:   userID,
-		Items:    eventItems,
-		Timestamp:time.Now(),
+		OrderID:   order.ID,
+		UserID:    userID,
+		Items:     eventItems,
+		Timestamp: time.Now(),
 	}
 
-	err = cfg.Publisher.Publish("order.cancelled", event)
+	err = cfg.Publisher.Publish(r.Context(), "order.cancelled", event)
 	if err != nil {
-    log.Printf("ERROR: failed to publish order.cancelled event for order %s: %v", orderID, err)
+		log.Printf("ERROR: failed to publish order.cancelled event for order %s: %v", orderID, err)
 	}
 
 	response.RespondWithJSON(w, http.StatusOK, updatedOrder)
@@ -268,7 +317,7 @@ func handlerCancelOrder(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 
 func handlerUpdateStatus(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
 	type updateStatusRequest struct {
-    Status string `json:"status"`
+		Status string `json:"status"`
 	}
 
 	orderIDStr := r.PathValue("orderID")
@@ -277,44 +326,80 @@ func handlerUpdateStatus(cfg *config.Config, w http.ResponseWriter, r *http.Requ
 		response.RespondWithError(w, http.StatusBadRequest, "invalid order ID", err)
 		return
 	}
-	
+
 	decoder := json.NewDecoder(r.Body)
 	params := updateStatusRequest{}
 	err = decoder.Decode(&params)
 	if err != nil {
-    response.RespondWithError(w, http.StatusBadRequest, "invalid request body", err)
-    return
+		response.RespondWithError(w, http.StatusBadRequest, "invalid request body", err)
+		return
 	}
 
 	var status database.OrderStatus
 	switch params.Status {
 	case "pending":
-	    status = database.OrderStatusPending
+		status = database.OrderStatusPending
 	case "paid":
-	    status = database.OrderStatusPaid
+		status = database.OrderStatusPaid
 	case "shipped":
-	    status = database.OrderStatusShipped
+		status = database.OrderStatusShipped
 	case "delivered":
-	    status = database.OrderStatusDelivered
+		status = database.OrderStatusDelivered
 	case "cancelled":
-	    status = database.OrderStatusCancelled
+		status = database.OrderStatusCancelled
 	default:
-	    response.RespondWithError(w, http.StatusBadRequest, "invalid status value", nil)
-	    return
+		response.RespondWithError(w, http.StatusBadRequest, "invalid status value", nil)
+		return
 	}
 
 	updatedOrder, err := cfg.DB.UpdateOrderStatus(r.Context(), database.UpdateOrderStatusParams{
-		ID: orderID,
+		ID:     orderID,
 		Status: status,
 	})
 	if err != nil {
-    if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, sql.ErrNoRows) {
 			response.RespondWithError(w, http.StatusNotFound, "order not found", nil)
 			return
-    }
-    response.RespondWithError(w, http.StatusInternalServerError, "could not update order status", err)
-    return
+		}
+		response.RespondWithError(w, http.StatusInternalServerError, "could not update order status", err)
+		return
 	}
 
 	response.RespondWithJSON(w, http.StatusOK, updatedOrder)
 }
+
+// handlerInternalUserOrders returns every order a user has placed, with its
+// items, for the gateway's data export.
+func handlerInternalUserOrders(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid user ID", err)
+		return
+	}
+	// Anonymized orders all belong to the nil user
+	if userID == uuid.Nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid user ID", nil)
+		return
+	}
+
+	orders, err := cfg.DB.GetOrdersByUserID(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "could not get orders", err)
+		return
+	}
+
+	out := make([]OrderResponse, 0, len(orders))
+	for _, order := range orders {
+		items, err := cfg.DB.GetOrderItems(r.Context(), order.ID)
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "could not get order items", err)
+			return
+		}
+		out = append(out, OrderResponse{
+			Order: order,
+			Items: items,
+		})
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
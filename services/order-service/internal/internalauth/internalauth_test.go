@@ -0,0 +1,195 @@
+package internalauth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedRequest returns a POST signed by s as user-1 with role admin.
+func signedRequest(t *testing.T, s *Signer) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/internal/users/42?force=true", strings.NewReader(`{"role":"admin"}`))
+	if err := s.Sign(r, "user-1", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(r *http.Request)
+		wantErr string
+	}{
+		{name: "valid", tamper: func(r *http.Request) {}},
+		{name: "missing caller", tamper: func(r *http.Request) { r.Header.Del(HeaderCaller) }, wantErr: "missing"},
+		{name: "missing timestamp", tamper: func(r *http.Request) { r.Header.Del(HeaderTimestamp) }, wantErr: "missing"},
+		{name: "missing signature", tamper: func(r *http.Request) { r.Header.Del(HeaderSignature) }, wantErr: "missing"},
+		{name: "malformed timestamp", tamper: func(r *http.Request) { r.Header.Set(HeaderTimestamp, "yesterday") }, wantErr: "invalid signature timestamp"},
+		{name: "bad signature", tamper: func(r *http.Request) { r.Header.Set(HeaderSignature, strings.Repeat("0", 64)) }, wantErr: "invalid internal signature"},
+		{name: "method", tamper: func(r *http.Request) { r.Method = http.MethodDelete }, wantErr: "invalid internal signature"},
+		{name: "path", tamper: func(r *http.Request) { r.URL.Path = "/internal/users/43" }, wantErr: "invalid internal signature"},
+		{name: "query", tamper: func(r *http.Request) { r.URL.RawQuery = "force=false" }, wantErr: "invalid internal signature"},
+		{name: "body", tamper: func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader(`{"role":"owner"}`)) }, wantErr: "invalid internal signature"},
+		{name: "user", tamper: func(r *http.Request) { r.Header.Set(HeaderUserID, "user-2") }, wantErr: "invalid internal signature"},
+		{name: "role", tamper: func(r *http.Request) { r.Header.Del(HeaderUserRole) }, wantErr: "invalid internal signature"},
+		{name: "caller", tamper: func(r *http.Request) { r.Header.Set(HeaderCaller, "someone-else") }, wantErr: "invalid internal signature"},
+		{
+			name: "timestamp",
+			tamper: func(r *http.Request) {
+				unix, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+				r.Header.Set(HeaderTimestamp, strconv.FormatInt(unix+1, 10))
+			},
+			wantErr: "invalid internal signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSigner("test-key", "test-caller")
+			r := signedRequest(t, s)
+			tt.tamper(r)
+
+			err := s.Verify(r)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				// The handler still gets the body
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"role":"admin"}` {
+					t.Errorf("body after Verify = %q", body)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	r := signedRequest(t, NewSigner("test-key", "test-caller"))
+	if err := NewSigner("other-key", "test-caller").Verify(r); err == nil {
+		t.Error("Verify accepted a signature made with another key")
+	}
+}
+
+func TestVerifyClockSkew(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		signedAt time.Time
+		wantErr  bool
+	}{
+		{name: "just now", signedAt: now},
+		{name: "within skew", signedAt: now.Add(-MaxClockSkew + time.Minute)},
+		{name: "ahead within skew", signedAt: now.Add(MaxClockSkew - time.Minute)},
+		{name: "too old", signedAt: now.Add(-MaxClockSkew - time.Minute), wantErr: true},
+		{name: "too far ahead", signedAt: now.Add(MaxClockSkew + time.Minute), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := NewSigner("test-key", "test-caller")
+			signer.now = func() time.Time { return tt.signedAt }
+			r := signedRequest(t, signer)
+
+			verifier := NewSigner("test-key", "test-caller")
+			verifier.now = func() time.Time { return now }
+			err := verifier.Verify(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignReplacesIdentityHeaders(t *testing.T) {
+	s := NewSigner("test-key", "test-caller")
+	r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+	r.Header.Set(HeaderUserID, "spoofed")
+	r.Header.Set(HeaderUserRole, "admin")
+	r.Header.Set(HeaderCaller, "spoofed")
+
+	if err := s.Sign(r, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Get(HeaderUserID) != "" || r.Header.Get(HeaderUserRole) != "" {
+		t.Errorf("kept client identity headers: %v", r.Header)
+	}
+	if r.Header.Get(HeaderCaller) != "test-caller" {
+		t.Errorf("caller = %q, want test-caller", r.Header.Get(HeaderCaller))
+	}
+	if err := s.Verify(r); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestSignBodyTooLarge(t *testing.T) {
+	s := NewSigner("test-key", "test-caller")
+	r := httptest.NewRequest(http.MethodPost, "/internal/upload", strings.NewReader(strings.Repeat("a", MaxBodySize+1)))
+	if err := s.Sign(r, "", ""); err == nil {
+		t.Error("Sign accepted a body over MaxBodySize")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s := NewSigner("test-key", "test-caller")
+	tests := []struct {
+		name       string
+		request    func() *http.Request
+		wantStatus int
+	}{
+		{
+			name:       "unsigned public route",
+			request:    func() *http.Request { return httptest.NewRequest(http.MethodGet, "/api/products", nil) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unsigned internal route",
+			request:    func() *http.Request { return httptest.NewRequest(http.MethodGet, "/internal/users/42", nil) },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unsigned identity header",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/api/me", nil)
+				r.Header.Set(HeaderUserID, "user-1")
+				return r
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "signed",
+			request:    func() *http.Request { return signedRequest(t, s) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "signed then tampered",
+			request: func() *http.Request {
+				r := signedRequest(t, s)
+				r.URL.Path = "/internal/users/43"
+				return r
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			rec := httptest.NewRecorder()
+			Middleware(s, next).ServeHTTP(rec, tt.request())
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
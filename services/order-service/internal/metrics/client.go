@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	clientRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_requests_total",
+		Help: "Outbound requests to other services, by target, method and status.",
+	}, []string{"target", "method", "status"})
+
+	clientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_request_duration_seconds",
+		Help:    "Time until response headers arrive from other services, by target and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target", "method"})
+)
+
+// Transport wraps base to record every request to the named target service.
+// Network errors are counted with status "error".
+func Transport(target string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		method := methodLabel(req.Method)
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		clientDuration.WithLabelValues(target, method).Observe(time.Since(start).Seconds())
+
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		clientRequests.WithLabelValues(target, method, status).Inc()
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,229 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/events"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	queueName = "order-user-deletions"
+	// deadLetterExchange takes messages that failed maxDeliveries times or
+	// can never be processed, and routes them to deadLetterQueue for a
+	// person to look at
+	deadLetterExchange = "order-service.dead-letter"
+	deadLetterQueue    = queueName + ".dead-letter"
+	// maxDeliveries bounds how many times a message is tried before it is
+	// dead-lettered, so one that keeps failing can't loop forever
+	maxDeliveries = 5
+)
+
+type Consumer struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	queries *database.Queries
+}
+
+func NewConsumer(url string, queries *database.Queries) (*Consumer, error) {
+	fmt.Println("order-service connecting to RabbitMQ")
+
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to RabbitMQ: %w", err)
+	}
+	fmt.Println("order-service connected to RabbitMQ")
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel: %w", err)
+	}
+
+	err = ch.ExchangeDeclare(
+		"users",
+		"topic",
+		true,  // durable
+		false, // auto-delete
+		false, // internal
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not declare exchange: %w", err)
+	}
+
+	if err := declareDeadLetter(ch); err != nil {
+		return nil, err
+	}
+
+	// A quorum queue counts redeliveries in x-delivery-count, and dead-letters
+	// a message itself if it somehow goes past the limit
+	queue, err := ch.QueueDeclare(
+		queueName,
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{
+			amqp.QueueTypeArg:        amqp.QueueTypeQuorum,
+			"x-delivery-limit":       maxDeliveries,
+			"x-dead-letter-exchange": deadLetterExchange,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not declare queue: %w", err)
+	}
+
+	err = ch.QueueBind(
+		queue.Name,
+		"user.deleted",
+		"users",
+		false,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not bind queue: %w", err)
+	}
+	return &Consumer{
+		conn:    conn,
+		channel: ch,
+		queries: queries,
+	}, nil
+}
+
+// declareDeadLetter sets up the exchange and queue failed messages end up in.
+func declareDeadLetter(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(deadLetterExchange, "fanout", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not declare dead-letter exchange: %w", err)
+	}
+	_, err = ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not declare dead-letter queue: %w", err)
+	}
+	err = ch.QueueBind(deadLetterQueue, "", deadLetterExchange, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not bind dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+const consumerTag = "order-service"
+
+// Start consumes user events until ctx is cancelled or the channel closes.
+// Cancellation stops new deliveries but lets the message in hand finish and
+// be acked or nacked; unacked prefetched messages go back to the queue when
+// the channel closes.
+func (c *Consumer) Start(ctx context.Context) error {
+	msgs, err := c.channel.Consume(
+		queueName,
+		consumerTag,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("could not start consuming: %w", err)
+	}
+
+	work := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.channel.Cancel(consumerTag, false); err != nil {
+				return fmt.Errorf("could not cancel consumer: %w", err)
+			}
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return errors.New("delivery channel closed")
+			}
+			c.handle(work, msg)
+		}
+	}
+}
+
+// handle anonymizes a deleted user's orders. They are kept for accounting,
+// but no longer point at the user or hold their addresses. Orders already
+// anonymized don't match, so redelivered messages are harmless.
+func (c *Consumer) handle(ctx context.Context, msg amqp.Delivery) {
+	requestID := messageRequestID(msg)
+	metrics.MessagesConsumed.WithLabelValues(msg.RoutingKey).Inc()
+
+	var event events.UserDeletedEvent
+	err := json.Unmarshal(msg.Body, &event)
+	if err != nil {
+		fmt.Printf("request_id=%s could not unmarshal message: %v\n", requestID, err)
+		nack(msg, false)
+		return
+	}
+
+	orders, err := c.queries.AnonymizeUserOrders(ctx, event.UserID)
+	if err != nil {
+		fmt.Printf("request_id=%s could not anonymize orders for user %s: %v\n", requestID, event.UserID, err)
+		retry(msg)
+		return
+	}
+	msg.Ack(false)
+	metrics.MessagesAcked.WithLabelValues(msg.RoutingKey).Inc()
+	fmt.Printf("request_id=%s processed %s for user %s: %d orders anonymized\n", requestID, msg.RoutingKey, event.UserID, orders)
+}
+
+// nack rejects msg. Without requeue it goes to the dead-letter exchange.
+func nack(msg amqp.Delivery, requeue bool) {
+	msg.Nack(false, requeue)
+	metrics.MessagesNacked.WithLabelValues(msg.RoutingKey).Inc()
+	if requeue {
+		metrics.MessagesRequeued.WithLabelValues(msg.RoutingKey).Inc()
+	} else {
+		metrics.MessagesDeadLettered.WithLabelValues(msg.RoutingKey).Inc()
+	}
+}
+
+// retry puts msg back on the queue after a failure that may clear up, such
+// as the database being down, unless it has used up its maxDeliveries.
+func retry(msg amqp.Delivery) {
+	nack(msg, deliveryCount(msg)+1 < maxDeliveries)
+}
+
+// deliveryCount is how many times msg was delivered before this time.
+func deliveryCount(msg amqp.Delivery) int64 {
+	switch n := msg.Headers["x-delivery-count"].(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+// messageRequestID returns the request ID the publisher stamped on msg
+func messageRequestID(msg amqp.Delivery) string {
+	if id, ok := msg.Headers["x-request-id"].(string); ok && id != "" {
+		return id
+	}
+	return msg.CorrelationId
+}
+
+// Ready reports an error if the RabbitMQ connection or channel has closed.
+func (c *Consumer) Ready() error {
+	if c.conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if c.channel.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
+	return nil
+}
+
+func (c *Consumer) Close() {
+	c.channel.Close()
+	c.conn.Close()
+}
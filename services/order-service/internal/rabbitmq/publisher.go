@@ -1,15 +1,21 @@
 package rabbitmq
 
 import (
-	"fmt"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/requestid"
 )
 
 type Publisher struct {
-	conn      *amqp.Connection
-	channel   *amqp.Channel
+	conn     *amqp.Connection
+	channel  *amqp.Channel
 	exchange string
 }
 
@@ -26,7 +32,7 @@ func NewPublisher(url string) (*Publisher, error) {
 	if err != nil {
 		return nil, fmt.Errorf("could not create channel: %w", err)
 	}
-	
+
 	exchangeName := "orders"
 	err = publishCh.ExchangeDeclare(
 		exchangeName,
@@ -47,25 +53,45 @@ func NewPublisher(url string) (*Publisher, error) {
 	}, nil
 }
 
-func (p *Publisher) Publish(routingKey string, event interface{}) error {
+// Publish sends event to the exchange, stamping the request ID from ctx so
+// consumers can correlate the message with the request that caused it.
+func (p *Publisher) Publish(ctx context.Context, routingKey string, event interface{}) error {
 	data, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("cant marshal the struct: %w", err)
 	}
 
-	err = p.channel.Publish(
+	requestID := requestid.FromContext(ctx)
+	err = p.channel.PublishWithContext(
+		ctx,
 		p.exchange,
 		routingKey,
 		false,
 		false,
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        data,
+			ContentType:   "application/json",
+			CorrelationId: requestID,
+			Headers:       amqp.Table{"x-request-id": requestID},
+			Body:          data,
 		},
 	)
 	if err != nil {
+		metrics.PublishFailures.WithLabelValues(routingKey).Inc()
 		return fmt.Errorf("cant publish order event: %w", err)
 	}
+	metrics.MessagesPublished.WithLabelValues(routingKey).Inc()
+	log.Printf("request_id=%s published %s", requestID, routingKey)
+	return nil
+}
+
+// Ready reports an error if the RabbitMQ connection or channel has closed.
+func (p *Publisher) Ready() error {
+	if p.conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if p.channel.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
 	return nil
 }
 
@@ -73,5 +99,3 @@ func (p *Publisher) Close() {
 	p.channel.Close()
 	p.conn.Close()
 }
-
-
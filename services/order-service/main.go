@@ -1,54 +1,81 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/client"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/database"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/handlers"
-	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/client"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/metrics"
 	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/rabbitmq"
+	"github.com/herodragmon/scalable-ecommerce/services/order-service/internal/requestid"
 )
 
 func main() {
 	godotenv.Load()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	platform := os.Getenv("PLATFORM")
 	dbURL := os.Getenv("DB_URL")
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8084"
 	}
+	shutdownTimeout := 15 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid duration for SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = d
+	}
+	internalAuthKey := os.Getenv("INTERNAL_AUTH_KEY")
+	if internalAuthKey == "" {
+		log.Fatal("INTERNAL_AUTH_KEY is not set")
+	}
+	signer := internalauth.NewSigner(internalAuthKey, "order-service")
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("failed to open database connection: %v", err)
 	}
-	defer db.Close()
 
 	if err := db.Ping(); err != nil {
 		log.Fatalf("failed to ping database: %v", err)
 	}
 	productServiceURL := os.Getenv("PRODUCT_SERVICE_URL")
-  if productServiceURL == "" {
+	if productServiceURL == "" {
 		log.Fatal("PRODUCT_SERVICE_URL is not set")
-  }
-	productClient := client.NewProductClient(productServiceURL, 10*time.Second)
-	
+	}
+	productClient := client.NewProductClient(productServiceURL, 10*time.Second, signer)
+
 	cartServiceURL := os.Getenv("CART_SERVICE_URL")
 	if cartServiceURL == "" {
 		log.Fatal("CART_SERVICE_URL is not set")
 	}
-	cartClient := client.NewCartClient(cartServiceURL, 10*time.Second)
+	cartClient := client.NewCartClient(cartServiceURL, 10*time.Second, signer)
+
+	userServiceURL := os.Getenv("USER_SERVICE_URL")
+	if userServiceURL == "" {
+		log.Fatal("USER_SERVICE_URL is not set")
+	}
+	userClient := client.NewUserClient(userServiceURL, 10*time.Second, signer)
 
-	dbQueries := database.New(db)
+	dbQueries := database.New(metrics.InstrumentDB(db))
 
 	rabbitmqURL := os.Getenv("RABBITMQ_URL")
 	if rabbitmqURL == "" {
@@ -59,14 +86,29 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to publish exchange: %v", err)
 	}
-	defer publisher.Close()
+
+	consumer, err := rabbitmq.NewConsumer(rabbitmqURL, dbQueries)
+	if err != nil {
+		log.Fatalf("failed to consume: %v", err)
+	}
+
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := consumer.Start(ctx); err != nil {
+			log.Printf("consumer stopped: %v", err)
+		}
+	}()
 
 	cfg := &config.Config{
-		DB:       dbQueries,
-		Platform: platform,
+		DB:            dbQueries,
+		DBConn:        db,
+		Platform:      platform,
 		ProductClient: productClient,
-		CartClient: cartClient,
-		Publisher: publisher,
+		CartClient:    cartClient,
+		UserClient:    userClient,
+		Publisher:     publisher,
+		Consumer:      consumer,
 	}
 
 	mux := http.NewServeMux()
@@ -74,9 +116,37 @@ func main() {
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: requestid.Middleware(metrics.Middleware(mux, internalauth.Middleware(signer, mux))),
 	}
 
-	log.Printf("Order service starting on port %s", port)
-	log.Fatal(server.ListenAndServe())
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Order service starting on port %s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Printf("Order service shutting down")
+
+	// Drain HTTP first so in-flight requests can still use the dependencies
+	// closed below
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to drain HTTP server: %v", err)
+	}
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		log.Printf("timed out waiting for consumer to finish")
+	}
+	consumer.Close()
+	publisher.Close()
+	db.Close()
+	log.Printf("Order service stopped")
 }
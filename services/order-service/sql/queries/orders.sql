@@ -2,11 +2,15 @@
 INSERT INTO orders (
   user_id,
   status,
-  total_cents
+  total_cents,
+  shipping_address,
+  billing_address
 ) VALUES (
   $1,
   $2,
-  $3
+  $3,
+  $4,
+  $5
 ) RETURNING *;
 
 -- name: GetOrderByID :one
@@ -19,8 +23,8 @@ SELECT * FROM orders WHERE user_id = $1 ORDER BY created_at DESC;
 UPDATE orders SET status = $2, updated_at = now() WHERE id = $1 RETURNING *;
 
 -- name: CreateOrderItem :one
-INSERT INTO order_items (order_id, product_id, quantity, price_cents)
-VALUES ($1, $2, $3, $4)
+INSERT INTO order_items (order_id, product_id, variant_id, quantity, price_cents)
+VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
 -- name: GetOrderItems :many
@@ -28,3 +32,12 @@ SELECT * FROM order_items WHERE order_id = $1;
 
 -- name: DeleteOrder :exec
 DELETE FROM orders where id = $1;
+
+-- name: AnonymizeUserOrders :execrows
+UPDATE orders
+SET
+  user_id = '00000000-0000-0000-0000-000000000000',
+  shipping_address = 'null',
+  billing_address = 'null',
+  updated_at = now()
+WHERE user_id = $1;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Copies of the user's addresses at checkout, so later edits to the address
+-- book don't change past orders. JSON null when none was available.
+ALTER TABLE orders
+    ADD COLUMN shipping_address JSONB NOT NULL DEFAULT 'null',
+    ADD COLUMN billing_address JSONB NOT NULL DEFAULT 'null';
+
+-- +goose Down
+ALTER TABLE orders
+    DROP COLUMN billing_address,
+    DROP COLUMN shipping_address;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- The product variant each line was for. Orders placed before variants
+-- existed were for the product's default variant, which has its ID.
+ALTER TABLE order_items ADD COLUMN variant_id UUID;
+UPDATE order_items SET variant_id = product_id;
+ALTER TABLE order_items ALTER COLUMN variant_id SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE order_items DROP COLUMN variant_id;
@@ -1,8 +1,15 @@
 package config
 
-import "github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+import (
+	"database/sql"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/rabbitmq"
+)
 
 type Config struct {
 	DB       *database.Queries
+	DBConn   *sql.DB
+	Consumer *rabbitmq.Consumer
 	Platform string
 }
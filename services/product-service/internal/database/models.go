@@ -6,18 +6,46 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Category struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ParentID  uuid.NullUUID
+	Name      string
+	Slug      string
+}
+
 type Product struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Name        string
-	Description sql.NullString
-	PriceCents  int32
-	Stock       int32
-	IsActive    bool
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	Description  sql.NullString
+	PriceCents   int32
+	IsActive     bool
+	SearchVector string `json:"-"`
+	Version      int32
+}
+
+type ProductCategory struct {
+	ProductID  uuid.UUID
+	CategoryID uuid.UUID
+}
+
+type ProductVariant struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ProductID  uuid.UUID
+	Sku        string
+	Options    json.RawMessage
+	PriceCents sql.NullInt32
+	Stock      int32
+	IsDefault  bool
 }
@@ -8,8 +8,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createProduct = `-- name: CreateProduct :one
@@ -20,7 +22,6 @@ INSERT INTO products (
   name,
   description,
   price_cents,
-  stock,
   is_active
 ) VALUES (
     gen_random_uuid(),
@@ -29,16 +30,14 @@ INSERT INTO products (
     $1,
     $2,
     $3,
-    $4,
-    $5
-) RETURNING id, created_at, updated_at, name, description, price_cents, stock, is_active
+    $4
+) RETURNING id, created_at, updated_at, name, description, price_cents, is_active, search_vector, version
 `
 
 type CreateProductParams struct {
 	Name        string
 	Description sql.NullString
 	PriceCents  int32
-	Stock       int32
 	IsActive    bool
 }
 
@@ -47,7 +46,6 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (P
 		arg.Name,
 		arg.Description,
 		arg.PriceCents,
-		arg.Stock,
 		arg.IsActive,
 	)
 	var i Product
@@ -58,8 +56,9 @@ func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (P
 		&i.Name,
 		&i.Description,
 		&i.PriceCents,
-		&i.Stock,
 		&i.IsActive,
+		&i.SearchVector,
+		&i.Version,
 	)
 	return i, err
 }
@@ -73,8 +72,36 @@ func (q *Queries) DeleteProduct(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const getProduct = `-- name: GetProduct :one
+SELECT id, created_at, updated_at, name, description, price_cents, is_active, search_vector, version FROM products
+WHERE id = $1
+`
+
+// Any product, active or not, for admin changes
+func (q *Queries) GetProduct(ctx context.Context, id uuid.UUID) (Product, error) {
+	row := q.db.QueryRowContext(ctx, getProduct, id)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Description,
+		&i.PriceCents,
+		&i.IsActive,
+		&i.SearchVector,
+		&i.Version,
+	)
+	return i, err
+}
+
 const getProductByID = `-- name: GetProductByID :one
-SELECT id, name, price_cents, stock
+SELECT
+  id,
+  name,
+  price_cents,
+  (SELECT coalesce(sum(v.stock), 0) FROM product_variants v WHERE v.product_id = products.id)::int AS stock,
+  version
 FROM products
 WHERE is_active = true AND id = $1
 `
@@ -84,6 +111,7 @@ type GetProductByIDRow struct {
 	Name       string
 	PriceCents int32
 	Stock      int32
+	Version    int32
 }
 
 func (q *Queries) GetProductByID(ctx context.Context, id uuid.UUID) (GetProductByIDRow, error) {
@@ -94,38 +122,406 @@ func (q *Queries) GetProductByID(ctx context.Context, id uuid.UUID) (GetProductB
 		&i.Name,
 		&i.PriceCents,
 		&i.Stock,
+		&i.Version,
 	)
 	return i, err
 }
 
-const getProducts = `-- name: GetProducts :many
-SELECT id, name, price_cents, stock
-FROM products
-WHERE is_active = true
-ORDER BY created_at DESC
+const listProductsByName = `-- name: ListProductsByName :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND ($1::int IS NULL OR listing.price_cents >= $1)
+  AND ($2::int IS NULL OR listing.price_cents <= $2)
+  AND (NOT $3::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND ($4::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY($4)
+  ))
+  AND ($5::text IS NULL
+    OR (p.name, p.id) > ($5, $6::uuid))
+ORDER BY p.name, p.id
+LIMIT $7
+`
+
+type ListProductsByNameParams struct {
+	MinPrice    sql.NullInt32
+	MaxPrice    sql.NullInt32
+	InStock     bool
+	CategoryIds []uuid.UUID
+	AfterName   sql.NullString
+	AfterID     uuid.NullUUID
+	Limit       int32
+}
+
+type ListProductsByNameRow struct {
+	ID         uuid.UUID
+	Name       string
+	PriceCents int32
+	Stock      int32
+	CreatedAt  time.Time
+}
+
+func (q *Queries) ListProductsByName(ctx context.Context, arg ListProductsByNameParams) ([]ListProductsByNameRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProductsByName,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.InStock,
+		pq.Array(arg.CategoryIds),
+		arg.AfterName,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProductsByNameRow
+	for rows.Next() {
+		var i ListProductsByNameRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PriceCents,
+			&i.Stock,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductsByPriceAsc = `-- name: ListProductsByPriceAsc :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND ($1::int IS NULL OR listing.price_cents >= $1)
+  AND ($2::int IS NULL OR listing.price_cents <= $2)
+  AND (NOT $3::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND ($4::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY($4)
+  ))
+  AND ($5::int IS NULL
+    OR (listing.price_cents, p.id) > ($5, $6::uuid))
+ORDER BY listing.price_cents, p.id
+LIMIT $7
+`
+
+type ListProductsByPriceAscParams struct {
+	MinPrice    sql.NullInt32
+	MaxPrice    sql.NullInt32
+	InStock     bool
+	CategoryIds []uuid.UUID
+	AfterPrice  sql.NullInt32
+	AfterID     uuid.NullUUID
+	Limit       int32
+}
+
+type ListProductsByPriceAscRow struct {
+	ID         uuid.UUID
+	Name       string
+	PriceCents int32
+	Stock      int32
+	CreatedAt  time.Time
+}
+
+func (q *Queries) ListProductsByPriceAsc(ctx context.Context, arg ListProductsByPriceAscParams) ([]ListProductsByPriceAscRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProductsByPriceAsc,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.InStock,
+		pq.Array(arg.CategoryIds),
+		arg.AfterPrice,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProductsByPriceAscRow
+	for rows.Next() {
+		var i ListProductsByPriceAscRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PriceCents,
+			&i.Stock,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductsByPriceDesc = `-- name: ListProductsByPriceDesc :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND ($1::int IS NULL OR listing.price_cents >= $1)
+  AND ($2::int IS NULL OR listing.price_cents <= $2)
+  AND (NOT $3::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND ($4::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY($4)
+  ))
+  AND ($5::int IS NULL
+    OR (listing.price_cents, p.id) < ($5, $6::uuid))
+ORDER BY listing.price_cents DESC, p.id DESC
+LIMIT $7
+`
+
+type ListProductsByPriceDescParams struct {
+	MinPrice    sql.NullInt32
+	MaxPrice    sql.NullInt32
+	InStock     bool
+	CategoryIds []uuid.UUID
+	AfterPrice  sql.NullInt32
+	AfterID     uuid.NullUUID
+	Limit       int32
+}
+
+type ListProductsByPriceDescRow struct {
+	ID         uuid.UUID
+	Name       string
+	PriceCents int32
+	Stock      int32
+	CreatedAt  time.Time
+}
+
+func (q *Queries) ListProductsByPriceDesc(ctx context.Context, arg ListProductsByPriceDescParams) ([]ListProductsByPriceDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProductsByPriceDesc,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.InStock,
+		pq.Array(arg.CategoryIds),
+		arg.AfterPrice,
+		arg.AfterID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProductsByPriceDescRow
+	for rows.Next() {
+		var i ListProductsByPriceDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PriceCents,
+			&i.Stock,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductsNewest = `-- name: ListProductsNewest :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND ($1::int IS NULL OR listing.price_cents >= $1)
+  AND ($2::int IS NULL OR listing.price_cents <= $2)
+  AND (NOT $3::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND ($4::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY($4)
+  ))
+  AND ($5::timestamp IS NULL
+    OR (p.created_at, p.id) < ($5, $6::uuid))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT $7
 `
 
-type GetProductsRow struct {
+type ListProductsNewestParams struct {
+	MinPrice       sql.NullInt32
+	MaxPrice       sql.NullInt32
+	InStock        bool
+	CategoryIds    []uuid.UUID
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+type ListProductsNewestRow struct {
 	ID         uuid.UUID
 	Name       string
 	PriceCents int32
 	Stock      int32
+	CreatedAt  time.Time
 }
 
-func (q *Queries) GetProducts(ctx context.Context) ([]GetProductsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getProducts)
+func (q *Queries) ListProductsNewest(ctx context.Context, arg ListProductsNewestParams) ([]ListProductsNewestRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProductsNewest,
+		arg.MinPrice,
+		arg.MaxPrice,
+		arg.InStock,
+		pq.Array(arg.CategoryIds),
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetProductsRow
+	var items []ListProductsNewestRow
 	for rows.Next() {
-		var i GetProductsRow
+		var i ListProductsNewestRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
 			&i.PriceCents,
 			&i.Stock,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchProducts = `-- name: SearchProducts :many
+SELECT
+  id,
+  name,
+  price_cents,
+  (SELECT coalesce(sum(v.stock), 0) FROM product_variants v WHERE v.product_id = products.id)::int AS stock,
+  ts_rank(search_vector, query)::real AS rank,
+  ts_headline('english', name, query, 'StartSel=**, StopSel=**, HighlightAll=true') AS headline,
+  ts_headline('english', coalesce(description, ''), query, 'StartSel=**, StopSel=**, MaxWords=20, MinWords=8, MaxFragments=2') AS snippet
+FROM products, to_tsquery('english', $1) AS query
+WHERE is_active = true AND search_vector @@ query
+ORDER BY rank DESC, id
+LIMIT $2
+`
+
+type SearchProductsParams struct {
+	Query string
+	Limit int32
+}
+
+type SearchProductsRow struct {
+	ID         uuid.UUID
+	Name       string
+	PriceCents int32
+	Stock      int32
+	Rank       float32
+	Headline   string
+	Snippet    string
+}
+
+func (q *Queries) SearchProducts(ctx context.Context, arg SearchProductsParams) ([]SearchProductsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchProducts, arg.Query, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchProductsRow
+	for rows.Next() {
+		var i SearchProductsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PriceCents,
+			&i.Stock,
+			&i.Rank,
+			&i.Headline,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -146,11 +542,11 @@ SET
   name = $2,
   description = $3,
   price_cents = $4,
-  stock = $5,
-  is_active = $6,
+  is_active = $5,
+  version = version + 1,
   updated_at = now()
-WHERE id = $1
-RETURNING id, created_at, updated_at, name, description, price_cents, stock, is_active
+WHERE id = $1 AND version = $6
+RETURNING id, created_at, updated_at, name, description, price_cents, is_active, search_vector, version
 `
 
 type UpdateProductParams struct {
@@ -158,18 +554,20 @@ type UpdateProductParams struct {
 	Name        string
 	Description sql.NullString
 	PriceCents  int32
-	Stock       int32
 	IsActive    bool
+	Version     int32
 }
 
+// Only applies if the product is still at the version the change was made
+// from
 func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (Product, error) {
 	row := q.db.QueryRowContext(ctx, updateProduct,
 		arg.ID,
 		arg.Name,
 		arg.Description,
 		arg.PriceCents,
-		arg.Stock,
 		arg.IsActive,
+		arg.Version,
 	)
 	var i Product
 	err := row.Scan(
@@ -179,36 +577,9 @@ func (q *Queries) UpdateProduct(ctx context.Context, arg UpdateProductParams) (P
 		&i.Name,
 		&i.Description,
 		&i.PriceCents,
-		&i.Stock,
-		&i.IsActive,
-	)
-	return i, err
-}
-
-const updateStock = `-- name: UpdateStock :one
-UPDATE products 
-SET stock = stock + $2, updated_at = now()
-WHERE id = $1 AND stock + $2 >= 0
-RETURNING id, created_at, updated_at, name, description, price_cents, stock, is_active
-`
-
-type UpdateStockParams struct {
-	ID    uuid.UUID
-	Stock int32
-}
-
-func (q *Queries) UpdateStock(ctx context.Context, arg UpdateStockParams) (Product, error) {
-	row := q.db.QueryRowContext(ctx, updateStock, arg.ID, arg.Stock)
-	var i Product
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Name,
-		&i.Description,
-		&i.PriceCents,
-		&i.Stock,
 		&i.IsActive,
+		&i.SearchVector,
+		&i.Version,
 	)
 	return i, err
 }
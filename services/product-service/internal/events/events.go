@@ -2,11 +2,23 @@ package events
 
 import "github.com/google/uuid"
 
+// OrderItem is one line of an order. Events published before product
+// variants existed have no variant_id.
 type OrderItem struct {
     ProductID uuid.UUID `json:"product_id"`
+    VariantID uuid.UUID `json:"variant_id"`
     Quantity  int32     `json:"quantity"`
 }
 type OrderEvent struct {
     OrderID uuid.UUID   `json:"order_id"`
     Items   []OrderItem `json:"items"`
 }
+
+// StockUpdateFailedEvent reports an order event whose stock change could not
+// be applied because VariantID is out of stock or no longer exists. None of
+// the event's stock changes were made.
+type StockUpdateFailedEvent struct {
+    OrderID   uuid.UUID `json:"order_id"`
+    Event     string    `json:"event"`
+    VariantID uuid.UUID `json:"variant_id"`
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/response"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/validation"
+)
+
+const (
+	// maxProductCategories bounds how many categories one product can be in
+	maxProductCategories = 20
+	// maxCategoryUpdateAttempts bounds retries of a category update that
+	// lost a race with a concurrent one
+	maxCategoryUpdateAttempts = 3
+)
+
+var (
+	errParentCategoryNotFound = errors.New("parent category not found")
+	errCategoryCycle          = errors.New("category would be under itself")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+type categoryResponse struct {
+	ID       uuid.UUID  `json:"id"`
+	ParentID *uuid.UUID `json:"parent_id"`
+	Name     string     `json:"name"`
+	Slug     string     `json:"slug"`
+}
+
+func newCategoryResponse(c database.Category) categoryResponse {
+	out := categoryResponse{
+		ID:   c.ID,
+		Name: c.Name,
+		Slug: c.Slug,
+	}
+	if c.ParentID.Valid {
+		out.ParentID = &c.ParentID.UUID
+	}
+	return out
+}
+
+type categoryNode struct {
+	categoryResponse
+	Children []categoryNode `json:"children"`
+}
+
+// buildCategoryTree nests categories under their parents. Siblings keep the
+// order they are given in.
+func buildCategoryTree(categories []database.Category) []categoryNode {
+	children := map[uuid.UUID][]database.Category{}
+	var roots []database.Category
+	for _, c := range categories {
+		if c.ParentID.Valid {
+			children[c.ParentID.UUID] = append(children[c.ParentID.UUID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var build func([]database.Category) []categoryNode
+	build = func(level []database.Category) []categoryNode {
+		nodes := make([]categoryNode, 0, len(level))
+		for _, c := range level {
+			nodes = append(nodes, categoryNode{
+				categoryResponse: newCategoryResponse(c),
+				Children:         build(children[c.ID]),
+			})
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+// slugify derives a slug from a category name: lowercase ASCII letters and
+// digits, with anything else collapsed into single hyphens.
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// uniqueViolation returns the unique constraint a duplicate value broke, or
+// "" if err is something else.
+func uniqueViolation(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+// foreignKeyViolation returns the constraint a foreign key violation broke,
+// or "" if err is something else.
+func foreignKeyViolation(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return pqErr.Constraint
+	}
+	return ""
+}
+
+func handlerCategoriesGet(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	categories, err := cfg.DB.ListCategories(r.Context())
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get categories", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, buildCategoryTree(categories))
+}
+
+func handlerCategoriesCreate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type createCategoryReq struct {
+		Name     string     `json:"name"`
+		Slug     string     `json:"slug"`
+		ParentID *uuid.UUID `json:"parent_id"`
+	}
+
+	var body createCategoryReq
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if !validation.Required(name) {
+		response.RespondWithError(w, http.StatusBadRequest, "name is required", nil)
+		return
+	}
+	slug := body.Slug
+	if slug == "" {
+		slug = slugify(name)
+	}
+	if !slugPattern.MatchString(slug) {
+		response.RespondWithError(w, http.StatusBadRequest, "slug must be lowercase letters and digits separated by hyphens", nil)
+		return
+	}
+
+	parentID := uuid.NullUUID{}
+	if body.ParentID != nil {
+		if _, err := cfg.DB.GetCategoryByID(r.Context(), *body.ParentID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				response.RespondWithError(w, http.StatusBadRequest, "parent category not found", err)
+				return
+			}
+			response.RespondWithError(w, http.StatusInternalServerError, "couldn't get parent category", err)
+			return
+		}
+		parentID = uuid.NullUUID{UUID: *body.ParentID, Valid: true}
+	}
+
+	category, err := cfg.DB.CreateCategory(r.Context(), database.CreateCategoryParams{
+		ParentID: parentID,
+		Name:     name,
+		Slug:     slug,
+	})
+	if uniqueViolation(err) != "" {
+		response.RespondWithError(w, http.StatusConflict, "slug is already in use", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't create category", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusCreated, newCategoryResponse(category))
+}
+
+// handlerCategoriesUpdate renames a category or moves it. Omitted fields keep
+// their value; a parent_id of null moves the category to the top level.
+func handlerCategoriesUpdate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type updateCategoryReq struct {
+		Name     *string         `json:"name"`
+		Slug     *string         `json:"slug"`
+		ParentID json.RawMessage `json:"parent_id"`
+	}
+
+	categoryID, err := uuid.Parse(r.PathValue("categoryID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid category ID", err)
+		return
+	}
+
+	var body updateCategoryReq
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	category, err := cfg.DB.GetCategoryByID(r.Context(), categoryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "category not found", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get category", err)
+		return
+	}
+
+	params := database.UpdateCategoryParams{
+		ID:       category.ID,
+		ParentID: category.ParentID,
+		Name:     category.Name,
+		Slug:     category.Slug,
+	}
+	if body.Name != nil {
+		params.Name = strings.TrimSpace(*body.Name)
+		if !validation.Required(params.Name) {
+			response.RespondWithError(w, http.StatusBadRequest, "name is required", nil)
+			return
+		}
+	}
+	if body.Slug != nil {
+		params.Slug = *body.Slug
+		if !slugPattern.MatchString(params.Slug) {
+			response.RespondWithError(w, http.StatusBadRequest, "slug must be lowercase letters and digits separated by hyphens", nil)
+			return
+		}
+	}
+
+	if len(body.ParentID) > 0 {
+		params.ParentID = uuid.NullUUID{}
+		if !bytes.Equal(body.ParentID, []byte("null")) {
+			if err := json.Unmarshal(body.ParentID, &params.ParentID.UUID); err != nil {
+				response.RespondWithError(w, http.StatusBadRequest, "invalid parent_id", err)
+				return
+			}
+			params.ParentID.Valid = true
+		}
+	}
+
+	updated, err := updateCategory(r.Context(), cfg, params)
+	if errors.Is(err, errParentCategoryNotFound) {
+		response.RespondWithError(w, http.StatusBadRequest, "parent category not found", err)
+		return
+	}
+	if errors.Is(err, errCategoryCycle) {
+		response.RespondWithError(w, http.StatusBadRequest, "a category can't be moved under itself or its subcategories", nil)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "category not found", err)
+		return
+	}
+	if uniqueViolation(err) != "" {
+		response.RespondWithError(w, http.StatusConflict, "slug is already in use", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't update category", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, newCategoryResponse(updated))
+}
+
+// updateCategory saves a change to a category, checking that a new parent
+// exists and isn't the category or below it. It runs in a serializable
+// transaction: two moves that are each fine alone but together form a loop,
+// such as A under B and B under A at once, can't both commit, and the loser
+// is retried against the tree the winner left.
+func updateCategory(ctx context.Context, cfg *config.Config, params database.UpdateCategoryParams) (database.Category, error) {
+	for attempt := 1; ; attempt++ {
+		category, err := updateCategoryOnce(ctx, cfg, params)
+		if isSerializationFailure(err) && attempt < maxCategoryUpdateAttempts {
+			continue
+		}
+		return category, err
+	}
+}
+
+func updateCategoryOnce(ctx context.Context, cfg *config.Config, params database.UpdateCategoryParams) (database.Category, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return database.Category{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if params.ParentID.Valid {
+		if _, err := qtx.GetCategoryByID(ctx, params.ParentID.UUID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return database.Category{}, errParentCategoryNotFound
+			}
+			return database.Category{}, err
+		}
+
+		// The new parent can't be the category or anything under it
+		descendants, err := qtx.ListCategoryDescendantIDs(ctx, params.ID)
+		if err != nil {
+			return database.Category{}, err
+		}
+		if slices.Contains(descendants, params.ParentID.UUID) {
+			return database.Category{}, errCategoryCycle
+		}
+	}
+
+	category, err := qtx.UpdateCategory(ctx, params)
+	if foreignKeyViolation(err) != "" {
+		return database.Category{}, errParentCategoryNotFound
+	}
+	if err != nil {
+		return database.Category{}, err
+	}
+	return category, tx.Commit()
+}
+
+// isSerializationFailure reports whether err is Postgres aborting a
+// serializable transaction that conflicted with a concurrent one.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "40001"
+}
+
+// handlerCategoriesDelete deletes a category that has no subcategories.
+// Products in it stay, just no longer in that category.
+func handlerCategoriesDelete(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	categoryID, err := uuid.Parse(r.PathValue("categoryID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid category ID", err)
+		return
+	}
+
+	rows, err := cfg.DB.DeleteCategory(r.Context(), categoryID)
+	if foreignKeyViolation(err) != "" {
+		response.RespondWithError(w, http.StatusConflict, "category has subcategories; move or delete them first", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't delete category", err)
+		return
+	}
+	if rows == 0 {
+		response.RespondWithError(w, http.StatusNotFound, "category not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerProductCategoriesSet replaces the categories a product is in and
+// returns the new set.
+func handlerProductCategoriesSet(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type setCategoriesReq struct {
+		CategoryIDs []uuid.UUID `json:"category_ids"`
+	}
+
+	productID, err := uuid.Parse(r.PathValue("productID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid product ID", err)
+		return
+	}
+
+	var body setCategoriesReq
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+	slices.SortFunc(body.CategoryIDs, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	categoryIDs := slices.Compact(body.CategoryIDs)
+	if len(categoryIDs) > maxProductCategories {
+		response.RespondWithError(w, http.StatusBadRequest, "a product can be in at most 20 categories", nil)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := qtx.ClearProductCategories(r.Context(), productID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't update product categories", err)
+		return
+	}
+	for _, categoryID := range categoryIDs {
+		err := qtx.AddProductCategory(r.Context(), database.AddProductCategoryParams{
+			ProductID:  productID,
+			CategoryID: categoryID,
+		})
+		switch foreignKeyViolation(err) {
+		case "":
+		case "product_categories_product_id_fkey":
+			response.RespondWithError(w, http.StatusNotFound, "product not found", err)
+			return
+		default:
+			response.RespondWithError(w, http.StatusBadRequest, "category not found: "+categoryID.String(), err)
+			return
+		}
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "couldn't update product categories", err)
+			return
+		}
+	}
+
+	categories, err := qtx.ListProductCategories(r.Context(), productID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get product categories", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't update product categories", err)
+		return
+	}
+
+	out := make([]categoryResponse, 0, len(categories))
+	for _, c := range categories {
+		out = append(out, newCategoryResponse(c))
+	}
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+)
+
+var categoryColumns = []string{"id", "created_at", "updated_at", "parent_id", "name", "slug"}
+
+func categoryRow(id uuid.UUID, parent uuid.NullUUID, slug string) []driver.Value {
+	now := time.Now()
+	var parentID driver.Value
+	if parent.Valid {
+		parentID = parent.UUID.String()
+	}
+	return []driver.Value{id.String(), now, now, parentID, slug, slug}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Kitchen", "kitchen"},
+		{"Pots & Pans", "pots-pans"},
+		{"  Mugs -- and Cups!  ", "mugs-and-cups"},
+		{"Café Crème", "caf-cr-me"},
+		{"100% Cotton", "100-cotton"},
+		{"!!!", ""},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.name); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	kitchen, mugs, pans, garden := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	under := func(id uuid.UUID) uuid.NullUUID { return uuid.NullUUID{UUID: id, Valid: true} }
+
+	tree := buildCategoryTree([]database.Category{
+		{ID: garden, Name: "Garden"},
+		{ID: kitchen, Name: "Kitchen"},
+		{ID: mugs, ParentID: under(kitchen), Name: "Mugs"},
+		{ID: pans, ParentID: under(kitchen), Name: "Pans"},
+	})
+
+	if len(tree) != 2 || tree[0].ID != garden || tree[1].ID != kitchen {
+		t.Fatalf("roots = %+v, want Garden then Kitchen", tree)
+	}
+	if tree[0].Children == nil || len(tree[0].Children) != 0 {
+		t.Errorf("leaf children = %v, want an empty list", tree[0].Children)
+	}
+	children := tree[1].Children
+	if len(children) != 2 || children[0].ID != mugs || children[1].ID != pans {
+		t.Errorf("Kitchen children = %+v, want Mugs then Pans", children)
+	}
+}
+
+// TestHandlerCategoriesUpdateParent moves categories around the tree
+// kitchen > mugs > travel-mugs, with garden alongside kitchen.
+func TestHandlerCategoriesUpdateParent(t *testing.T) {
+	kitchen, mugs, travel, garden := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	parents := map[uuid.UUID]uuid.NullUUID{
+		kitchen: {},
+		mugs:    {UUID: kitchen, Valid: true},
+		travel:  {UUID: mugs, Valid: true},
+		garden:  {},
+	}
+	descendants := map[uuid.UUID][]uuid.UUID{
+		kitchen: {kitchen, mugs, travel},
+		mugs:    {mugs, travel},
+		travel:  {travel},
+		garden:  {garden},
+	}
+
+	tests := []struct {
+		name       string
+		category   uuid.UUID
+		parentID   string
+		wantStatus int
+	}{
+		{name: "under a sibling", category: mugs, parentID: `"` + garden.String() + `"`, wantStatus: http.StatusOK},
+		{name: "to the top level", category: travel, parentID: "null", wantStatus: http.StatusOK},
+		{name: "under itself", category: kitchen, parentID: `"` + kitchen.String() + `"`, wantStatus: http.StatusBadRequest},
+		{name: "under its child", category: kitchen, parentID: `"` + mugs.String() + `"`, wantStatus: http.StatusBadRequest},
+		{name: "under its grandchild", category: kitchen, parentID: `"` + travel.String() + `"`, wantStatus: http.StatusBadRequest},
+		{name: "unknown parent", category: mugs, parentID: `"` + uuid.NewString() + `"`, wantStatus: http.StatusBadRequest},
+		{name: "invalid parent", category: mugs, parentID: `"kitchen"`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{respond: func(q fakeQuery) ([]string, [][]driver.Value) {
+				switch {
+				case q.is("GetCategoryByID"):
+					id := uuid.MustParse(q.args[0].(string))
+					if parent, ok := parents[id]; ok {
+						return categoryColumns, [][]driver.Value{categoryRow(id, parent, "c")}
+					}
+				case q.is("ListCategoryDescendantIDs"):
+					var rows [][]driver.Value
+					for _, id := range descendants[uuid.MustParse(q.args[0].(string))] {
+						rows = append(rows, []driver.Value{id.String()})
+					}
+					return []string{"id"}, rows
+				case q.is("UpdateCategory"):
+					id := uuid.MustParse(q.args[0].(string))
+					parent := uuid.NullUUID{}
+					if q.args[1] != nil {
+						parent = uuid.NullUUID{UUID: uuid.MustParse(q.args[1].(string)), Valid: true}
+					}
+					return categoryColumns, [][]driver.Value{categoryRow(id, parent, q.args[3].(string))}
+				}
+				return categoryColumns, nil
+			}}
+			cfg := newFakeConfig(t, d)
+
+			body := `{"parent_id":` + tt.parentID + `}`
+			r := httptest.NewRequest(http.MethodPut, "/api/categories/"+tt.category.String(), strings.NewReader(body))
+			r.SetPathValue("categoryID", tt.category.String())
+			rec := httptest.NewRecorder()
+			handlerCategoriesUpdate(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			updated := false
+			for _, q := range d.queries {
+				updated = updated || q.is("UpdateCategory")
+			}
+			if updated != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("updated = %v with status %d", updated, rec.Code)
+			}
+			// The check and the move must see the same tree
+			for _, level := range d.isolation {
+				if level != driver.IsolationLevel(sql.LevelSerializable) {
+					t.Errorf("transaction isolation = %v, want serializable", sql.IsolationLevel(level))
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerProductsGetCategory(t *testing.T) {
+	kitchen, mugs, travel := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		slug       string
+		wantStatus int
+		wantIDs    []uuid.UUID
+	}{
+		{name: "includes subcategories", slug: "kitchen", wantStatus: http.StatusOK, wantIDs: []uuid.UUID{kitchen, mugs, travel}},
+		{name: "unknown category", slug: "attic", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listed *fakeQuery
+			d := &fakeDriver{respond: func(q fakeQuery) ([]string, [][]driver.Value) {
+				switch {
+				case q.is("GetCategoryBySlug"):
+					if q.args[0] == "kitchen" {
+						return categoryColumns, [][]driver.Value{categoryRow(kitchen, uuid.NullUUID{}, "kitchen")}
+					}
+				case q.is("ListCategoryDescendantIDs"):
+					if q.args[0] != kitchen.String() {
+						t.Errorf("listed descendants of %v, want kitchen", q.args[0])
+					}
+					return []string{"id"}, [][]driver.Value{{kitchen.String()}, {mugs.String()}, {travel.String()}}
+				case q.is("ListProductsNewest"):
+					listed = &q
+				}
+				return []string{"id"}, nil
+			}}
+			cfg := newFakeConfig(t, d)
+
+			rec := httptest.NewRecorder()
+			handlerProductsGet(cfg, rec, httptest.NewRequest(http.MethodGet, "/api/products?category="+tt.slug, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if listed != nil {
+					t.Error("listed products for an unknown category")
+				}
+				return
+			}
+			if listed == nil {
+				t.Fatal("products weren't listed")
+			}
+			// The category filter is the fourth argument, as a Postgres array
+			filter := fmt.Sprint(listed.args[3])
+			for _, id := range tt.wantIDs {
+				if !strings.Contains(filter, id.String()) {
+					t.Errorf("category filter %s is missing %s", filter, id)
+				}
+			}
+		})
+	}
+}
@@ -3,31 +3,55 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/metrics"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/response"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/validation"
 )
 
 func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 	// Health check
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "product-service"})
+	mux.HandleFunc("GET /health", handlerLive)
+	mux.HandleFunc("GET /health/live", handlerLive)
+
+	mux.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		handlerReady(cfg, w, r)
 	})
 
+	mux.Handle("GET /metrics", metrics.Handler())
+
 	// Public routes
 	mux.HandleFunc("GET /api/products", func(w http.ResponseWriter, r *http.Request) {
 		handlerProductsGet(cfg, w, r)
 	})
 
+	mux.HandleFunc("GET /api/products/search", func(w http.ResponseWriter, r *http.Request) {
+		handlerProductsSearch(cfg, w, r)
+	})
+
 	mux.HandleFunc("GET /api/products/{productID}", func(w http.ResponseWriter, r *http.Request) {
 		handlerProductsGetByID(cfg, w, r)
 	})
 
+	mux.HandleFunc("GET /internal/variants/{variantID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerInternalVariantGet(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /api/categories", func(w http.ResponseWriter, r *http.Request) {
+		handlerCategoriesGet(cfg, w, r)
+	})
+
 	// Admin routes (authorization handled by API Gateway)
 	mux.HandleFunc("POST /api/products", func(w http.ResponseWriter, r *http.Request) {
 		handlerProductsCreate(cfg, w, r)
@@ -40,24 +64,61 @@ func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 	mux.HandleFunc("DELETE /api/products/{productID}", func(w http.ResponseWriter, r *http.Request) {
 		handlerProductsDelete(cfg, w, r)
 	})
+
+	mux.HandleFunc("POST /api/products/{productID}/variants", func(w http.ResponseWriter, r *http.Request) {
+		handlerVariantsCreate(cfg, w, r)
+	})
+
+	mux.HandleFunc("PATCH /api/products/{productID}/variants/{variantID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerVariantsUpdate(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /api/products/{productID}/variants/{variantID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerVariantsDelete(cfg, w, r)
+	})
+
+	mux.HandleFunc("PUT /api/products/{productID}/categories", func(w http.ResponseWriter, r *http.Request) {
+		handlerProductCategoriesSet(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/categories", func(w http.ResponseWriter, r *http.Request) {
+		handlerCategoriesCreate(cfg, w, r)
+	})
+
+	mux.HandleFunc("PATCH /api/categories/{categoryID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerCategoriesUpdate(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /api/categories/{categoryID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerCategoriesDelete(cfg, w, r)
+	})
 }
 
-func handlerProductsGet(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
-	products, err := cfg.DB.GetProducts(r.Context())
-	if err != nil {
-		response.RespondWithError(
-			w,
-			http.StatusInternalServerError,
-			"Couldn't get products",
-			err,
-		)
-		return
-	}
+// productETag is the ETag for a version of a product.
+func productETag(version int32) string {
+	return `"` + strconv.Itoa(int(version)) + `"`
+}
 
-	response.RespondWithJSON(w, http.StatusOK, products)
+// ifMatch reports whether an If-Match header accepts a product version: it is
+// "*" or lists the version's ETag. Weak ETags never match, as If-Match
+// requires a strong comparison.
+func ifMatch(header string, version int32) bool {
+	etag := productETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
 }
 
 func handlerProductsGetByID(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		database.GetProductByIDRow
+		Variants []variantResponse `json:"variants"`
+	}
+
 	productIDStr := r.PathValue("productID")
 	productID, err := uuid.Parse(productIDStr)
 	if err != nil {
@@ -71,7 +132,17 @@ func handlerProductsGetByID(cfg *config.Config, w http.ResponseWriter, r *http.R
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, product)
+	variants, err := cfg.DB.ListProductVariants(r.Context(), productID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get variants", err)
+		return
+	}
+
+	w.Header().Set("ETag", productETag(product.Version))
+	response.RespondWithJSON(w, http.StatusOK, resp{
+		GetProductByIDRow: product,
+		Variants:          newVariantResponses(variants, product.PriceCents),
+	})
 }
 
 func handlerProductsCreate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
@@ -80,6 +151,7 @@ func handlerProductsCreate(cfg *config.Config, w http.ResponseWriter, r *http.Re
 		Description string `json:"description"`
 		PriceCents  int    `json:"price_cents"`
 		Stock       int    `json:"stock"`
+		SKU         string `json:"sku"`
 		IsActive    *bool  `json:"is_active"`
 	}
 
@@ -104,12 +176,25 @@ func handlerProductsCreate(cfg *config.Config, w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if body.SKU != "" && !skuPattern.MatchString(body.SKU) {
+		response.RespondWithError(w, http.StatusBadRequest, "sku must be up to 64 letters, digits, '.', '_' or '-'", nil)
+		return
+	}
+
 	isActive := true
 	if body.IsActive != nil {
 		isActive = *body.IsActive
 	}
 
-	product, err := cfg.DB.CreateProduct(
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't create product", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	product, err := qtx.CreateProduct(
 		r.Context(),
 		database.CreateProductParams{
 			Name: body.Name,
@@ -118,7 +203,6 @@ func handlerProductsCreate(cfg *config.Config, w http.ResponseWriter, r *http.Re
 				Valid:  body.Description != "",
 			},
 			PriceCents: int32(body.PriceCents),
-			Stock:      int32(body.Stock),
 			IsActive:   isActive,
 		},
 	)
@@ -127,9 +211,44 @@ func handlerProductsCreate(cfg *config.Config, w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusCreated, product)
+	// Every product starts with a default variant that holds its stock. It
+	// shares the product's ID, so a product ID alone still names something
+	// that can be bought.
+	sku := body.SKU
+	if sku == "" {
+		sku = "SKU-" + product.ID.String()
+	}
+	variant, err := qtx.CreateVariant(r.Context(), database.CreateVariantParams{
+		ID:        product.ID,
+		ProductID: product.ID,
+		Sku:       sku,
+		Options:   json.RawMessage("{}"),
+		Stock:     int32(body.Stock),
+		IsDefault: true,
+	})
+	if uniqueViolation(err) != "" {
+		response.RespondWithError(w, http.StatusConflict, "sku is already in use", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't create product", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't create product", err)
+		return
+	}
+
+	w.Header().Set("ETag", productETag(product.Version))
+	response.RespondWithJSON(w, http.StatusCreated, newProductResponse(product, []database.ProductVariant{variant}))
 }
 
+// handlerProductsUpdate applies a JSON Merge Patch (RFC 7396) to a product:
+// only the fields sent change, each checked as it is on create, and a
+// description of null clears it. If-Match must carry the product's ETag, so
+// an edit made from a stale copy is refused instead of overwriting a change
+// someone else saved in the meantime.
 func handlerProductsUpdate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
 	productIDStr := r.PathValue("productID")
 	productID, err := uuid.Parse(productIDStr)
@@ -138,40 +257,139 @@ func handlerProductsUpdate(cfg *config.Config, w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	type updateReq struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		PriceCents  int    `json:"price_cents"`
-		Stock       int    `json:"stock"`
-		IsActive    bool   `json:"is_active"`
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		response.RespondWithError(w, http.StatusPreconditionRequired, "If-Match with the product's ETag is required", nil)
+		return
 	}
 
-	var body updateReq
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "couldn't read body", err)
+		return
+	}
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		response.RespondWithError(w, http.StatusBadRequest, "body must be a JSON object", err)
 		return
 	}
 
-	product, err := cfg.DB.UpdateProduct(
-		r.Context(),
-		database.UpdateProductParams{
-			ID:   productID,
-			Name: body.Name,
-			Description: sql.NullString{
-				String: body.Description,
-				Valid:  body.Description != "",
-			},
-			PriceCents: int32(body.PriceCents),
-			Stock:      int32(body.Stock),
-			IsActive:   body.IsActive,
-		},
-	)
+	product, err := cfg.DB.GetProduct(r.Context(), productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "product not found", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get product", err)
+		return
+	}
+	if !ifMatch(match, product.Version) {
+		w.Header().Set("ETag", productETag(product.Version))
+		response.RespondWithError(w, http.StatusPreconditionFailed, "product has changed since it was fetched", nil)
+		return
+	}
+
+	params, msg, err := applyProductPatch(product, body)
+	if msg != "" {
+		response.RespondWithError(w, http.StatusBadRequest, msg, err)
+		return
+	}
+
+	// No row means another update got in since the product was read
+	product, err = cfg.DB.UpdateProduct(r.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusPreconditionFailed, "product has changed since it was fetched", err)
+		return
+	}
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "couldn't update product", err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, product)
+	variants, err := cfg.DB.ListProductVariants(r.Context(), productID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get variants", err)
+		return
+	}
+
+	w.Header().Set("ETag", productETag(product.Version))
+	response.RespondWithJSON(w, http.StatusOK, newProductResponse(product, variants))
+}
+
+// productDocument is the JSON a product PATCH is merged into: the fields an
+// admin can change, as they are now.
+type productDocument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	PriceCents  int32  `json:"price_cents"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// applyProductPatch merges patch into product and checks the result as a
+// create would. It returns the update to make, or a message for a 400.
+func applyProductPatch(product database.Product, patch []byte) (database.UpdateProductParams, string, error) {
+	params := database.UpdateProductParams{
+		ID:      product.ID,
+		Version: product.Version,
+	}
+
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return params, "body must be a JSON object", err
+	}
+	var unknown []string
+	for field := range changes {
+		switch field {
+		case "name", "description", "price_cents", "is_active":
+		case "stock":
+			return params, "stock is set per variant", nil
+		default:
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return params, "unknown field: " + unknown[0], nil
+	}
+
+	doc, err := json.Marshal(productDocument{
+		Name:        product.Name,
+		Description: product.Description.String,
+		PriceCents:  product.PriceCents,
+		IsActive:    product.IsActive,
+	})
+	if err != nil {
+		return params, "couldn't apply patch", err
+	}
+	doc, err = mergePatch(doc, patch)
+	if err != nil {
+		return params, "body must be a JSON object", err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return params, "body must be a JSON object", err
+	}
+
+	// A field the patch set to null is gone from the document, so it decodes
+	// as missing: that clears description and is refused for the rest
+	if err := json.Unmarshal(fields["name"], &params.Name); err != nil || !validation.Required(params.Name) {
+		return params, "name is required", err
+	}
+	if value, ok := fields["description"]; ok {
+		if err := json.Unmarshal(value, &params.Description.String); err != nil {
+			return params, "description must be a string or null", err
+		}
+		params.Description.Valid = params.Description.String != ""
+	}
+	var priceCents int
+	if err := json.Unmarshal(fields["price_cents"], &priceCents); err != nil || !validation.GreaterThan(priceCents, 0) || priceCents > math.MaxInt32 {
+		return params, "price_cents must be > 0", err
+	}
+	params.PriceCents = int32(priceCents)
+	if err := json.Unmarshal(fields["is_active"], &params.IsActive); err != nil {
+		return params, "is_active must be true or false", err
+	}
+	return params, "", nil
 }
 
 func handlerProductsDelete(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+)
+
+func TestProductETag(t *testing.T) {
+	if got := productETag(7); got != `"7"` {
+		t.Errorf("productETag(7) = %s, want \"7\"", got)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int32
+		want    bool
+	}{
+		{name: "current", header: `"3"`, version: 3, want: true},
+		{name: "any", header: `*`, version: 3, want: true},
+		{name: "stale", header: `"2"`, version: 3, want: false},
+		{name: "weak never matches", header: `W/"3"`, version: 3, want: false},
+		{name: "list with current", header: `"1", "3"`, version: 3, want: true},
+		{name: "list with only a weak current", header: `"2", W/"3"`, version: 3, want: false},
+		{name: "surrounding spaces", header: `  "3"  `, version: 3, want: true},
+		{name: "unquoted", header: `3`, version: 3, want: false},
+		{name: "prefix of a longer version", header: `"1"`, version: 12, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ifMatch(tt.header, tt.version); got != tt.want {
+				t.Errorf("ifMatch(%q, %d) = %v, want %v", tt.header, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func testProduct() database.Product {
+	return database.Product{
+		ID:          uuid.MustParse("00000000-0000-0000-0000-0000000000aa"),
+		Name:        "Mug",
+		Description: sql.NullString{String: "Stoneware, 350ml", Valid: true},
+		PriceCents:  900,
+		IsActive:    true,
+		Version:     2,
+	}
+}
+
+func TestApplyProductPatch(t *testing.T) {
+	base := testProduct()
+	unchanged := database.UpdateProductParams{
+		ID:          base.ID,
+		Name:        base.Name,
+		Description: base.Description,
+		PriceCents:  base.PriceCents,
+		IsActive:    base.IsActive,
+		Version:     base.Version,
+	}
+	with := func(change func(p *database.UpdateProductParams)) database.UpdateProductParams {
+		p := unchanged
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		want    database.UpdateProductParams
+		wantMsg string
+	}{
+		{
+			name:  "empty patch changes nothing",
+			patch: `{}`,
+			want:  unchanged,
+		},
+		{
+			name:  "only sent fields change",
+			patch: `{"price_cents": 1200}`,
+			want:  with(func(p *database.UpdateProductParams) { p.PriceCents = 1200 }),
+		},
+		{
+			name:  "several fields",
+			patch: `{"name": "Big mug", "is_active": false}`,
+			want: with(func(p *database.UpdateProductParams) {
+				p.Name = "Big mug"
+				p.IsActive = false
+			}),
+		},
+		{
+			name:  "null clears description",
+			patch: `{"description": null}`,
+			want:  with(func(p *database.UpdateProductParams) { p.Description = sql.NullString{} }),
+		},
+		{
+			name:  "empty string clears description",
+			patch: `{"description": ""}`,
+			want:  with(func(p *database.UpdateProductParams) { p.Description = sql.NullString{} }),
+		},
+		{
+			name:  "description set",
+			patch: `{"description": "Porcelain"}`,
+			want: with(func(p *database.UpdateProductParams) {
+				p.Description = sql.NullString{String: "Porcelain", Valid: true}
+			}),
+		},
+		{name: "null name", patch: `{"name": null}`, wantMsg: "name is required"},
+		{name: "blank name", patch: `{"name": "  "}`, wantMsg: "name is required"},
+		{name: "null price", patch: `{"price_cents": null}`, wantMsg: "price_cents must be > 0"},
+		{name: "zero price", patch: `{"price_cents": 0}`, wantMsg: "price_cents must be > 0"},
+		{name: "price too large", patch: `{"price_cents": 3000000000}`, wantMsg: "price_cents must be > 0"},
+		{name: "null is_active", patch: `{"is_active": null}`, wantMsg: "is_active must be true or false"},
+		{name: "string is_active", patch: `{"is_active": "yes"}`, wantMsg: "is_active must be true or false"},
+		{name: "number description", patch: `{"description": 5}`, wantMsg: "description must be a string or null"},
+		// Product fields are all scalars, so an object replaces the value
+		// rather than merging into it, and is then refused
+		{name: "object replaces a scalar", patch: `{"name": {"en": "Mug"}}`, wantMsg: "name is required"},
+		{name: "object description", patch: `{"description": {"text": "Porcelain"}}`, wantMsg: "description must be a string or null"},
+		{name: "stock", patch: `{"stock": 5}`, wantMsg: "stock is set per variant"},
+		{name: "unknown field", patch: `{"name": "Mug", "weight": 1, "color": "red"}`, wantMsg: "unknown field: color"},
+		{name: "unknown field set to null", patch: `{"weight": null}`, wantMsg: "unknown field: weight"},
+		{name: "array", patch: `["name"]`, wantMsg: "body must be a JSON object"},
+		{name: "null body", patch: `null`, wantMsg: "body must be a JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, msg, _ := applyProductPatch(base, []byte(tt.patch))
+			if msg != tt.wantMsg {
+				t.Fatalf("message = %q, want %q", msg, tt.wantMsg)
+			}
+			if tt.wantMsg == "" && got != tt.want {
+				t.Errorf("params = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// productUpdateDB answers the queries a product PATCH makes. It holds one
+// product; concurrent makes UpdateProduct find it already changed.
+func productUpdateDB(product database.Product, concurrent bool) *fakeDriver {
+	productColumns := []string{"id", "created_at", "updated_at", "name", "description", "price_cents", "is_active", "search_vector", "version"}
+	productRow := func(p database.Product) []driver.Value {
+		var description driver.Value
+		if p.Description.Valid {
+			description = p.Description.String
+		}
+		return []driver.Value{p.ID.String(), p.CreatedAt, p.UpdatedAt, p.Name, description, int64(p.PriceCents), p.IsActive, "", int64(p.Version)}
+	}
+
+	return &fakeDriver{respond: func(q fakeQuery) ([]string, [][]driver.Value) {
+		switch {
+		case strings.Contains(q.sql, "-- name: GetProduct :one"):
+			return productColumns, [][]driver.Value{productRow(product)}
+		case strings.Contains(q.sql, "-- name: UpdateProduct :one"):
+			if concurrent || q.args[5] != int64(product.Version) {
+				return productColumns, nil
+			}
+			updated := product
+			updated.Name = q.args[1].(string)
+			updated.Description = sql.NullString{}
+			if s, ok := q.args[2].(string); ok {
+				updated.Description = sql.NullString{String: s, Valid: true}
+			}
+			updated.PriceCents = int32(q.args[3].(int64))
+			updated.IsActive = q.args[4].(bool)
+			updated.Version++
+			return productColumns, [][]driver.Value{productRow(updated)}
+		default:
+			// ListProductVariants
+			return []string{"id", "created_at", "updated_at", "product_id", "sku", "options", "price_cents", "stock", "is_default"}, nil
+		}
+	}}
+}
+
+func TestProductsUpdatePreconditions(t *testing.T) {
+	product := testProduct()
+	product.CreatedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	product.UpdatedAt = product.CreatedAt
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		body       string
+		concurrent bool
+		wantStatus int
+		wantETag   string
+		wantUpdate bool
+	}{
+		{name: "missing If-Match", body: `{"name": "Big mug"}`, wantStatus: http.StatusPreconditionRequired},
+		{name: "stale ETag", ifMatch: `"1"`, body: `{"name": "Big mug"}`, wantStatus: http.StatusPreconditionFailed, wantETag: `"2"`},
+		{name: "weak ETag", ifMatch: `W/"2"`, body: `{"name": "Big mug"}`, wantStatus: http.StatusPreconditionFailed, wantETag: `"2"`},
+		{name: "current ETag", ifMatch: `"2"`, body: `{"name": "Big mug"}`, wantStatus: http.StatusOK, wantETag: `"3"`, wantUpdate: true},
+		{name: "any ETag", ifMatch: `*`, body: `{"name": "Big mug"}`, wantStatus: http.StatusOK, wantETag: `"3"`, wantUpdate: true},
+		{name: "changed after it was read", ifMatch: `"2"`, body: `{"name": "Big mug"}`, concurrent: true, wantStatus: http.StatusPreconditionFailed, wantUpdate: true},
+		{name: "invalid patch", ifMatch: `"2"`, body: `{"price_cents": null}`, wantStatus: http.StatusBadRequest},
+		{name: "not an object", ifMatch: `"2"`, body: `"Big mug"`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := productUpdateDB(product, tt.concurrent)
+			cfg := newFakeConfig(t, d)
+
+			req := httptest.NewRequest(http.MethodPatch, "/api/products/"+product.ID.String(), strings.NewReader(tt.body))
+			req.SetPathValue("productID", product.ID.String())
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			handlerProductsUpdate(cfg, rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+			updated := false
+			for _, q := range d.queries {
+				if strings.Contains(q.sql, "-- name: UpdateProduct :one") {
+					updated = true
+				}
+			}
+			if updated != tt.wantUpdate {
+				t.Errorf("UpdateProduct called = %v, want %v", updated, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func TestProductsUpdateNullClearsDescription(t *testing.T) {
+	product := testProduct()
+	d := productUpdateDB(product, false)
+	cfg := newFakeConfig(t, d)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/products/"+product.ID.String(), strings.NewReader(`{"description": null}`))
+	req.SetPathValue("productID", product.ID.String())
+	req.Header.Set("If-Match", productETag(product.Version))
+	rec := httptest.NewRecorder()
+	handlerProductsUpdate(cfg, rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Name        string
+		Description sql.NullString
+		PriceCents  int32
+		Version     int32
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Description.Valid {
+		t.Errorf("description = %q, want cleared", body.Description.String)
+	}
+	if body.Name != product.Name || body.PriceCents != product.PriceCents {
+		t.Errorf("fields not in the patch changed: %+v", body)
+	}
+	if body.Version != product.Version+1 {
+		t.Errorf("version = %d, want %d", body.Version, product.Version+1)
+	}
+}
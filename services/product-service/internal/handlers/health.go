@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/response"
+)
+
+// readinessTimeout bounds each dependency check so a hung dependency reports
+// as down instead of stalling the probe.
+const readinessTimeout = 2 * time.Second
+
+type readiness struct {
+	Status  string            `json:"status"`
+	Service string            `json:"service"`
+	Checks  map[string]string `json:"checks"`
+}
+
+func handlerLive(w http.ResponseWriter, r *http.Request) {
+	response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "product-service"})
+}
+
+// handlerReady reports whether the service's dependencies are reachable.
+// It answers 503 if any check fails so orchestrators stop routing to it.
+func handlerReady(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database": cfg.DBConn.PingContext,
+		"rabbitmq": func(context.Context) error { return cfg.Consumer.Ready() },
+	}
+
+	result := readiness{
+		Status:  "ok",
+		Service: "product-service",
+		Checks:  make(map[string]string, len(checks)),
+	}
+	status := http.StatusOK
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			log.Printf("readiness check %s failed: %v", name, err)
+			result.Checks[name] = "unavailable"
+			result.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+
+	response.RespondWithJSON(w, status, result)
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/response"
+)
+
+const (
+	defaultProductPageSize = 20
+	maxProductPageSize     = 100
+)
+
+const (
+	sortNewest    = "newest"
+	sortPriceAsc  = "price_asc"
+	sortPriceDesc = "price_desc"
+	sortName      = "name"
+)
+
+// productListItem is one product in a listing, priced at its cheapest
+// variant. The untagged field names are the ones GET /api/products has always
+// returned.
+type productListItem struct {
+	ID         uuid.UUID
+	Name       string
+	PriceCents int32
+	Stock      int32
+	CreatedAt  time.Time `json:"-"`
+}
+
+// productCursor is the position after the last product of a page: its sort
+// key and ID. It is handed to clients as opaque base64 and names the sort it
+// was made for, so it can't be replayed against a different order.
+type productCursor struct {
+	Sort       string    `json:"sort"`
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	PriceCents int32     `json:"price_cents"`
+	Name       string    `json:"name"`
+}
+
+func encodeProductCursor(sort string, last productListItem) (string, error) {
+	data, err := json.Marshal(productCursor{
+		Sort:       sort,
+		ID:         last.ID,
+		CreatedAt:  last.CreatedAt,
+		PriceCents: last.PriceCents,
+		Name:       last.Name,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeProductCursor(s string) (productCursor, error) {
+	var c productCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	// Cursors are only ever made by encodeProductCursor, so anything else in
+	// one means it was edited
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil {
+		return c, err
+	}
+	if decoder.More() {
+		return c, errors.New("trailing data after cursor")
+	}
+	if c.ID == uuid.Nil {
+		return c, errors.New("cursor has no product ID")
+	}
+	return c, nil
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return n, nil
+}
+
+// queryPrice reads an optional price bound in cents.
+func queryPrice(r *http.Request, name string) (sql.NullInt32, error) {
+	if r.URL.Query().Get(name) == "" {
+		return sql.NullInt32{}, nil
+	}
+	n, err := queryInt(r, name, 0)
+	if err != nil || n > math.MaxInt32 {
+		return sql.NullInt32{}, errors.New("invalid " + name)
+	}
+	return sql.NullInt32{Int32: int32(n), Valid: true}, nil
+}
+
+// handlerProductsGet lists active products a page at a time. Pages are
+// keyset-paginated: next_cursor holds the position after the last product,
+// so products added or removed between requests don't shift later pages.
+func handlerProductsGet(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		Products   []productListItem `json:"products"`
+		NextCursor *string           `json:"next_cursor"`
+	}
+
+	limit, err := queryInt(r, "limit", defaultProductPageSize)
+	if err != nil || limit == 0 || limit > maxProductPageSize {
+		response.RespondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100", err)
+		return
+	}
+
+	minPrice, err := queryPrice(r, "min_price")
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "min_price must be a non-negative number of cents", err)
+		return
+	}
+	maxPrice, err := queryPrice(r, "max_price")
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "max_price must be a non-negative number of cents", err)
+		return
+	}
+	if minPrice.Valid && maxPrice.Valid && minPrice.Int32 > maxPrice.Int32 {
+		response.RespondWithError(w, http.StatusBadRequest, "min_price cannot be greater than max_price", nil)
+		return
+	}
+
+	inStock := false
+	if s := r.URL.Query().Get("in_stock"); s != "" {
+		inStock, err = strconv.ParseBool(s)
+		if err != nil {
+			response.RespondWithError(w, http.StatusBadRequest, "in_stock must be true or false", err)
+			return
+		}
+	}
+
+	// A category includes everything in its subcategories
+	var categoryIDs []uuid.UUID
+	if slug := r.URL.Query().Get("category"); slug != "" {
+		category, err := cfg.DB.GetCategoryBySlug(r.Context(), slug)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.RespondWithError(w, http.StatusNotFound, "Category not found", err)
+			return
+		}
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get category", err)
+			return
+		}
+		categoryIDs, err = cfg.DB.ListCategoryDescendantIDs(r.Context(), category.ID)
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get category", err)
+			return
+		}
+	}
+
+	sort := r.URL.Query().Get("sort")
+	switch sort {
+	case "":
+		sort = sortNewest
+	case sortNewest, sortPriceAsc, sortPriceDesc, sortName:
+	default:
+		response.RespondWithError(w, http.StatusBadRequest, "sort must be one of newest, price_asc, price_desc or name", nil)
+		return
+	}
+
+	var after productCursor
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		after, err = decodeProductCursor(s)
+		if err != nil {
+			response.RespondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		if after.Sort != sort {
+			response.RespondWithError(w, http.StatusBadRequest, "cursor was issued for a different sort", nil)
+			return
+		}
+	}
+	hasCursor := after.ID != uuid.Nil
+	afterID := uuid.NullUUID{UUID: after.ID, Valid: hasCursor}
+
+	// One extra row tells us whether there is another page
+	fetch := int32(limit + 1)
+	var products []productListItem
+	switch sort {
+	case sortNewest:
+		rows, err := cfg.DB.ListProductsNewest(r.Context(), database.ListProductsNewestParams{
+			MinPrice:       minPrice,
+			MaxPrice:       maxPrice,
+			InStock:        inStock,
+			CategoryIds:    categoryIDs,
+			AfterCreatedAt: sql.NullTime{Time: after.CreatedAt, Valid: hasCursor},
+			AfterID:        afterID,
+			Limit:          fetch,
+		})
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get products", err)
+			return
+		}
+		for _, row := range rows {
+			products = append(products, productListItem(row))
+		}
+	case sortPriceAsc:
+		rows, err := cfg.DB.ListProductsByPriceAsc(r.Context(), database.ListProductsByPriceAscParams{
+			MinPrice:    minPrice,
+			MaxPrice:    maxPrice,
+			InStock:     inStock,
+			CategoryIds: categoryIDs,
+			AfterPrice:  sql.NullInt32{Int32: after.PriceCents, Valid: hasCursor},
+			AfterID:     afterID,
+			Limit:       fetch,
+		})
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get products", err)
+			return
+		}
+		for _, row := range rows {
+			products = append(products, productListItem(row))
+		}
+	case sortPriceDesc:
+		rows, err := cfg.DB.ListProductsByPriceDesc(r.Context(), database.ListProductsByPriceDescParams{
+			MinPrice:    minPrice,
+			MaxPrice:    maxPrice,
+			InStock:     inStock,
+			CategoryIds: categoryIDs,
+			AfterPrice:  sql.NullInt32{Int32: after.PriceCents, Valid: hasCursor},
+			AfterID:     afterID,
+			Limit:       fetch,
+		})
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get products", err)
+			return
+		}
+		for _, row := range rows {
+			products = append(products, productListItem(row))
+		}
+	case sortName:
+		rows, err := cfg.DB.ListProductsByName(r.Context(), database.ListProductsByNameParams{
+			MinPrice:    minPrice,
+			MaxPrice:    maxPrice,
+			InStock:     inStock,
+			CategoryIds: categoryIDs,
+			AfterName:   sql.NullString{String: after.Name, Valid: hasCursor},
+			AfterID:     afterID,
+			Limit:       fetch,
+		})
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get products", err)
+			return
+		}
+		for _, row := range rows {
+			products = append(products, productListItem(row))
+		}
+	}
+
+	out := resp{Products: make([]productListItem, 0, limit)}
+	if len(products) > limit {
+		products = products[:limit]
+		next, err := encodeProductCursor(sort, products[limit-1])
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't build cursor", err)
+			return
+		}
+		out.NextCursor = &next
+	}
+	out.Products = append(out.Products, products...)
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
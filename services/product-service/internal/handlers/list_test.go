@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+)
+
+// fakeDriver answers queries with canned rows and records what it was asked,
+// so handlers can be tested without Postgres. If respond is set it answers
+// each query instead. Statements that return no rows report affected.
+type fakeDriver struct {
+	mu       sync.Mutex
+	rows     [][]driver.Value
+	columns  []string
+	respond  func(q fakeQuery) (columns []string, rows [][]driver.Value)
+	affected int64
+	queries  []fakeQuery
+	// isolation is the level of every transaction begun, in order
+	isolation []driver.IsolationLevel
+}
+
+type fakeQuery struct {
+	sql  string
+	args []driver.Value
+}
+
+// is reports whether q is the sqlc query called name.
+func (q fakeQuery) is(name string) bool {
+	return strings.HasPrefix(q.sql, "-- name: "+name+" ")
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.isolation = append(c.d.isolation, opts.Isolation)
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	q := fakeQuery{sql: query, args: values}
+	c.d.queries = append(c.d.queries, q)
+	if c.d.respond != nil {
+		columns, rows := c.d.respond(q)
+		return &fakeRows{columns: columns, rows: rows}, nil
+	}
+	return &fakeRows{columns: c.d.columns, rows: c.d.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.queries = append(c.d.queries, fakeQuery{sql: query, args: values})
+	return driver.RowsAffected(c.d.affected), nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newFakeConfig returns a config whose database is backed by d.
+func newFakeConfig(t *testing.T, d *fakeDriver) *config.Config {
+	t.Helper()
+	name := "fake-" + uuid.NewString()
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &config.Config{DB: database.New(db), DBConn: db}
+}
+
+func TestProductCursorRoundTrip(t *testing.T) {
+	last := productListItem{
+		ID:         uuid.New(),
+		Name:       "Kettle",
+		PriceCents: 2499,
+		CreatedAt:  time.Date(2024, 3, 1, 12, 30, 45, 123456000, time.UTC),
+	}
+
+	for _, sort := range []string{sortNewest, sortPriceAsc, sortPriceDesc, sortName} {
+		t.Run(sort, func(t *testing.T) {
+			s, err := encodeProductCursor(sort, last)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.ContainsAny(s, "+/=") {
+				t.Errorf("cursor %q is not URL-safe", s)
+			}
+			c, err := decodeProductCursor(s)
+			if err != nil {
+				t.Fatalf("decodeProductCursor: %v", err)
+			}
+			want := productCursor{
+				Sort:       sort,
+				ID:         last.ID,
+				CreatedAt:  last.CreatedAt,
+				PriceCents: last.PriceCents,
+				Name:       last.Name,
+			}
+			if !c.CreatedAt.Equal(want.CreatedAt) {
+				t.Errorf("CreatedAt = %v, want %v", c.CreatedAt, want.CreatedAt)
+			}
+			c.CreatedAt = want.CreatedAt
+			if c != want {
+				t.Errorf("cursor = %+v, want %+v", c, want)
+			}
+		})
+	}
+}
+
+func TestDecodeProductCursorRejects(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	valid, err := encodeProductCursor(sortName, productListItem{ID: uuid.New(), Name: "Kettle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "padded base64", cursor: base64.URLEncoding.EncodeToString([]byte(`{"sort":"name"}`))},
+		{name: "not JSON", cursor: encode("sort=name")},
+		{name: "truncated", cursor: valid[:len(valid)/2]},
+		{name: "no ID", cursor: encode(`{"sort":"name","name":"Kettle"}`)},
+		{name: "nil ID", cursor: encode(`{"sort":"name","id":"00000000-0000-0000-0000-000000000000"}`)},
+		{name: "bad ID", cursor: encode(`{"sort":"name","id":"42"}`)},
+		{name: "wrong type", cursor: encode(`{"sort":"price_asc","id":"` + uuid.NewString() + `","price_cents":"cheap"}`)},
+		{name: "unknown field", cursor: encode(`{"sort":"name","id":"` + uuid.NewString() + `","offset":100}`)},
+		{name: "trailing data", cursor: encode(`{"sort":"name","id":"` + uuid.NewString() + `"}{}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeProductCursor(tt.cursor); err == nil {
+				t.Errorf("decodeProductCursor(%q) accepted", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestProductsGetBadCursor(t *testing.T) {
+	priceCursor, err := encodeProductCursor(sortPriceAsc, productListItem{ID: uuid.New(), PriceCents: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "malformed", query: "cursor=%25%25%25", wantErr: "Invalid cursor"},
+		{name: "tampered", query: "cursor=" + priceCursor[:len(priceCursor)-4] + "AAAA", wantErr: "Invalid cursor"},
+		{name: "issued for another sort", query: "sort=price_desc&cursor=" + priceCursor, wantErr: "cursor was issued for a different sort"},
+		{name: "sort defaults to newest", query: "cursor=" + priceCursor, wantErr: "cursor was issued for a different sort"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{}
+			cfg := newFakeConfig(t, d)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/products?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handlerProductsGet(cfg, rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error != tt.wantErr {
+				t.Errorf("error = %q, want %q", body.Error, tt.wantErr)
+			}
+			if len(d.queries) != 0 {
+				t.Errorf("bad cursor reached the database")
+			}
+		})
+	}
+}
+
+// TestProductsGetTieBreak pages through products that share a sort key and
+// checks the ID breaks the tie: the cursor carries it, it is sent back as
+// after_id, and the query orders and compares on (key, id).
+func TestProductsGetTieBreak(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ids := []uuid.UUID{
+		uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+		uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+	}
+
+	tests := []struct {
+		sort     string
+		orderBy  string
+		keyset   string
+		afterKey func(c productCursor) driver.Value
+	}{
+		{
+			sort:     sortNewest,
+			orderBy:  "ORDER BY p.created_at DESC, p.id DESC",
+			keyset:   "(p.created_at, p.id) <",
+			afterKey: func(c productCursor) driver.Value { return c.CreatedAt },
+		},
+		{
+			sort:     sortPriceAsc,
+			orderBy:  "ORDER BY listing.price_cents, p.id",
+			keyset:   "(listing.price_cents, p.id) >",
+			afterKey: func(c productCursor) driver.Value { return int64(c.PriceCents) },
+		},
+		{
+			sort:     sortPriceDesc,
+			orderBy:  "ORDER BY listing.price_cents DESC, p.id DESC",
+			keyset:   "(listing.price_cents, p.id) <",
+			afterKey: func(c productCursor) driver.Value { return int64(c.PriceCents) },
+		},
+		{
+			sort:     sortName,
+			orderBy:  "ORDER BY p.name, p.id",
+			keyset:   "(p.name, p.id) >",
+			afterKey: func(c productCursor) driver.Value { return c.Name },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			// Every product has the same created_at, price and name
+			d := &fakeDriver{columns: []string{"id", "name", "price_cents", "stock", "created_at"}}
+			for _, id := range ids {
+				d.rows = append(d.rows, []driver.Value{id.String(), "Mug", int64(900), int64(5), createdAt})
+			}
+			cfg := newFakeConfig(t, d)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/products?limit=2&sort="+tt.sort, nil)
+			rec := httptest.NewRecorder()
+			handlerProductsGet(cfg, rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			var page struct {
+				Products   []productListItem `json:"products"`
+				NextCursor *string           `json:"next_cursor"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Products) != 2 || page.NextCursor == nil {
+				t.Fatalf("got %d products, next cursor %v; want 2 and a cursor", len(page.Products), page.NextCursor)
+			}
+
+			cursor, err := decodeProductCursor(*page.NextCursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cursor.ID != ids[1] || cursor.Sort != tt.sort {
+				t.Fatalf("cursor = %+v, want the second product's ID and sort %q", cursor, tt.sort)
+			}
+
+			first := d.queries[0]
+			if !strings.Contains(first.sql, tt.orderBy) {
+				t.Errorf("query does not contain %q:\n%s", tt.orderBy, first.sql)
+			}
+			if !strings.Contains(first.sql, tt.keyset) {
+				t.Errorf("query does not contain %q:\n%s", tt.keyset, first.sql)
+			}
+			// The keyset position and limit are the last three arguments
+			n := len(first.args)
+			if first.args[n-3] != nil || first.args[n-2] != nil {
+				t.Errorf("first page sent a position: %v, %v", first.args[n-3], first.args[n-2])
+			}
+			if first.args[n-1] != int64(3) {
+				t.Errorf("limit = %v, want one more than the page size", first.args[n-1])
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/api/products?limit=2&sort="+tt.sort+"&cursor="+*page.NextCursor, nil)
+			rec = httptest.NewRecorder()
+			handlerProductsGet(cfg, rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("second page status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			second := d.queries[1]
+			key := second.args[n-3]
+			if want := tt.afterKey(cursor); !equalValue(key, want) {
+				t.Errorf("after key = %v, want %v", key, want)
+			}
+			if second.args[n-2] != ids[1].String() {
+				t.Errorf("after_id = %v, want %v", second.args[n-2], ids[1])
+			}
+		})
+	}
+}
+
+func equalValue(got, want driver.Value) bool {
+	if gotTime, ok := got.(time.Time); ok {
+		wantTime, ok := want.(time.Time)
+		return ok && gotTime.Equal(wantTime)
+	}
+	return got == want
+}
+
+// TestProductsGetVariantPrice checks every sort prices a product at its
+// cheapest variant, and filters on that price rather than the product's own.
+func TestProductsGetVariantPrice(t *testing.T) {
+	for _, sort := range []string{sortNewest, sortPriceAsc, sortPriceDesc, sortName} {
+		t.Run(sort, func(t *testing.T) {
+			d := &fakeDriver{columns: []string{"id", "name", "price_cents", "stock", "created_at"}}
+			cfg := newFakeConfig(t, d)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/products?sort="+sort+"&min_price=500&max_price=1500", nil)
+			rec := httptest.NewRecorder()
+			handlerProductsGet(cfg, rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+
+			q := d.queries[0]
+			for _, want := range []string{
+				"min(coalesce(v.price_cents, p.price_cents))",
+				"listing.price_cents >= $1",
+				"listing.price_cents <= $2",
+			} {
+				if !strings.Contains(q.sql, want) {
+					t.Errorf("query does not contain %q:\n%s", want, q.sql)
+				}
+			}
+			if q.args[0] != int64(500) || q.args[1] != int64(1500) {
+				t.Errorf("price bounds = %v, %v, want 500, 1500", q.args[0], q.args[1])
+			}
+		})
+	}
+}
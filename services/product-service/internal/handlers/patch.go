@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// mergePatch applies a JSON Merge Patch (RFC 7396) to a JSON document. Objects
+// in the patch are merged into the document key by key, null removes a key,
+// and any other value replaces what was there.
+func mergePatch(doc, patch []byte) ([]byte, error) {
+	var target, changes any
+	if len(bytes.TrimSpace(doc)) > 0 {
+		if err := decodeJSONValue(doc, &target); err != nil {
+			return nil, err
+		}
+	}
+	if err := decodeJSONValue(patch, &changes); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeValue(target, changes))
+}
+
+func mergeValue(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]any)
+	if !ok {
+		merged = make(map[string]any, len(changes))
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
+
+// decodeJSONValue keeps numbers as written so large integers survive a round
+// trip through mergePatch.
+func decodeJSONValue(data []byte, v *any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
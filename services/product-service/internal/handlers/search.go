@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/response"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	maxSearchLength    = 200
+	maxSearchTerms     = 10
+)
+
+// searchTSQuery turns what a customer typed into a tsquery that matches
+// products containing every word. The last word is matched as a prefix so
+// results keep up while it is still being typed. Only letters and digits
+// survive, so tsquery operators in the input are never interpreted.
+func searchTSQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	words[len(words)-1] += ":*"
+	return strings.Join(words, " & ")
+}
+
+// handlerProductsSearch runs a full-text search over active products' names
+// and descriptions, best matches first. Matches in the name rank above
+// matches in the description. Headline and snippet mark matched words with
+// ** on either side.
+func handlerProductsSearch(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type result struct {
+		ID         uuid.UUID `json:"id"`
+		Name       string    `json:"name"`
+		PriceCents int32     `json:"price_cents"`
+		Stock      int32     `json:"stock"`
+		Rank       float32   `json:"rank"`
+		Headline   string    `json:"headline"`
+		Snippet    string    `json:"snippet"`
+	}
+
+	type resp struct {
+		Query   string   `json:"query"`
+		Results []result `json:"results"`
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if utf8.RuneCountInString(q) > maxSearchLength {
+		response.RespondWithError(w, http.StatusBadRequest, "q must be at most 200 characters", nil)
+		return
+	}
+	query := searchTSQuery(q)
+	if query == "" {
+		response.RespondWithError(w, http.StatusBadRequest, "q must contain a word to search for", nil)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultSearchLimit)
+	if err != nil || limit == 0 || limit > maxSearchLimit {
+		response.RespondWithError(w, http.StatusBadRequest, "limit must be between 1 and 50", err)
+		return
+	}
+
+	rows, err := cfg.DB.SearchProducts(r.Context(), database.SearchProductsParams{
+		Query: query,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't search products", err)
+		return
+	}
+
+	out := resp{
+		Query:   q,
+		Results: make([]result, 0, len(rows)),
+	}
+	for _, row := range rows {
+		out.Results = append(out.Results, result(row))
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
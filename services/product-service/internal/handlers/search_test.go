@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchTSQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		q    string
+		want string
+	}{
+		{name: "single word is a prefix", q: "mug", want: "mug:*"},
+		{name: "every word must match", q: "blue ceramic mug", want: "blue & ceramic & mug:*"},
+		{name: "extra whitespace", q: "  blue \t mug  ", want: "blue & mug:*"},
+		{name: "punctuation splits words", q: "t-shirt, large", want: "t & shirt & large:*"},
+		{name: "digits kept", q: "iphone 15", want: "iphone & 15:*"},
+		{name: "unicode letters kept", q: "café crème", want: "café & crème:*"},
+		{name: "operators dropped", q: "mug | !cup & (bowl)", want: "mug & cup & bowl:*"},
+		{name: "prefix and weight syntax dropped", q: "mug:* cup:A", want: "mug & cup & A:*"},
+		{name: "phrase operator dropped", q: "blue <-> mug", want: "blue & mug:*"},
+		{name: "quotes and backslashes dropped", q: `'mug' \cup`, want: "mug & cup:*"},
+		{name: "no words", q: "&|!():*", want: ""},
+		{name: "empty", q: "", want: ""},
+		{
+			name: "terms capped",
+			q:    "a b c d e f g h i j k l",
+			want: "a & b & c & d & e & f & g & h & i & j:*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchTSQuery(tt.q); got != tt.want {
+				t.Errorf("searchTSQuery(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerProductsSearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantArgs   []any
+	}{
+		{name: "search", query: "q=blue+mug", wantStatus: http.StatusOK, wantArgs: []any{"blue & mug:*", int64(defaultSearchLimit)}},
+		{name: "limit", query: "q=mug&limit=5", wantStatus: http.StatusOK, wantArgs: []any{"mug:*", int64(5)}},
+		{name: "no words", query: "q=" + url.QueryEscape("&|!"), wantStatus: http.StatusBadRequest},
+		{name: "missing", query: "", wantStatus: http.StatusBadRequest},
+		{name: "too long", query: "q=" + strings.Repeat("a", maxSearchLength+1), wantStatus: http.StatusBadRequest},
+		{name: "limit too big", query: "q=mug&limit=51", wantStatus: http.StatusBadRequest},
+		{name: "zero limit", query: "q=mug&limit=0", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{columns: []string{"id", "name", "price_cents", "stock", "rank", "headline", "snippet"}}
+			cfg := newFakeConfig(t, d)
+
+			rec := httptest.NewRecorder()
+			handlerProductsSearch(cfg, rec, httptest.NewRequest(http.MethodGet, "/api/products/search?"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(d.queries) != 0 {
+					t.Error("invalid search reached the database")
+				}
+				return
+			}
+			args := d.queries[0].args
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tt.wantArgs)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Errorf("args = %v, want %v", args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/response"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/validation"
+)
+
+const (
+	maxVariantOptions     = 5
+	maxVariantOptionKey   = 32
+	maxVariantOptionValue = 64
+)
+
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+type variantResponse struct {
+	ID         uuid.UUID       `json:"id"`
+	SKU        string          `json:"sku"`
+	Options    json.RawMessage `json:"options"`
+	PriceCents int32           `json:"price_cents"`
+	// PriceOverridden is false when the variant sells at the product's price
+	PriceOverridden bool  `json:"price_overridden"`
+	Stock           int32 `json:"stock"`
+	IsDefault       bool  `json:"is_default"`
+}
+
+func newVariantResponse(v database.ProductVariant, productPrice int32) variantResponse {
+	out := variantResponse{
+		ID:              v.ID,
+		SKU:             v.Sku,
+		Options:         v.Options,
+		PriceCents:      productPrice,
+		PriceOverridden: v.PriceCents.Valid,
+		Stock:           v.Stock,
+		IsDefault:       v.IsDefault,
+	}
+	if v.PriceCents.Valid {
+		out.PriceCents = v.PriceCents.Int32
+	}
+	return out
+}
+
+func newVariantResponses(variants []database.ProductVariant, productPrice int32) []variantResponse {
+	out := make([]variantResponse, 0, len(variants))
+	for _, v := range variants {
+		out = append(out, newVariantResponse(v, productPrice))
+	}
+	return out
+}
+
+// productResponse is a product as the admin endpoints return it. Stock is
+// the total across its variants.
+type productResponse struct {
+	database.Product
+	Stock    int32             `json:"stock"`
+	Variants []variantResponse `json:"variants"`
+}
+
+func newProductResponse(p database.Product, variants []database.ProductVariant) productResponse {
+	out := productResponse{
+		Product:  p,
+		Variants: newVariantResponses(variants, p.PriceCents),
+	}
+	for _, v := range variants {
+		out.Stock += v.Stock
+	}
+	return out
+}
+
+// normalizeVariantOptions checks option values such as {"size": "M"} and
+// returns them with keys lowercased and everything trimmed.
+func normalizeVariantOptions(options map[string]string) (json.RawMessage, error) {
+	if len(options) > maxVariantOptions {
+		return nil, errors.New("a variant can have at most 5 options")
+	}
+	out := make(map[string]string, len(options))
+	for k, v := range options {
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		if k == "" || utf8.RuneCountInString(k) > maxVariantOptionKey {
+			return nil, errors.New("option names must be 1 to 32 characters")
+		}
+		if v == "" || utf8.RuneCountInString(v) > maxVariantOptionValue {
+			return nil, errors.New("option values must be 1 to 64 characters")
+		}
+		if _, ok := out[k]; ok {
+			return nil, errors.New("duplicate option: " + k)
+		}
+		out[k] = v
+	}
+	return json.Marshal(out)
+}
+
+// respondVariantConflict reports which of a variant's unique values is
+// already taken. It returns false if err is not a unique violation.
+func respondVariantConflict(w http.ResponseWriter, err error) bool {
+	switch uniqueViolation(err) {
+	case "":
+		return false
+	case "product_variants_product_id_options_key":
+		response.RespondWithError(w, http.StatusConflict, "product already has a variant with these options", err)
+	default:
+		response.RespondWithError(w, http.StatusConflict, "sku is already in use", err)
+	}
+	return true
+}
+
+func handlerVariantsCreate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type createVariantReq struct {
+		SKU        string            `json:"sku"`
+		Options    map[string]string `json:"options"`
+		PriceCents *int              `json:"price_cents"`
+		Stock      int               `json:"stock"`
+	}
+
+	productID, err := uuid.Parse(r.PathValue("productID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid product ID", err)
+		return
+	}
+
+	var body createVariantReq
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	if !skuPattern.MatchString(body.SKU) {
+		response.RespondWithError(w, http.StatusBadRequest, "sku must be up to 64 letters, digits, '.', '_' or '-'", nil)
+		return
+	}
+	options, err := normalizeVariantOptions(body.Options)
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	price := sql.NullInt32{}
+	if body.PriceCents != nil {
+		if !validation.GreaterThan(*body.PriceCents, 0) || *body.PriceCents > math.MaxInt32 {
+			response.RespondWithError(w, http.StatusBadRequest, "price_cents must be > 0", nil)
+			return
+		}
+		price = sql.NullInt32{Int32: int32(*body.PriceCents), Valid: true}
+	}
+	if !validation.MinInt(body.Stock, 0) {
+		response.RespondWithError(w, http.StatusBadRequest, "stock cannot be negative", nil)
+		return
+	}
+	if body.Stock > math.MaxInt32 {
+		response.RespondWithError(w, http.StatusBadRequest, "stock is too large", nil)
+		return
+	}
+
+	product, err := cfg.DB.GetProduct(r.Context(), productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "product not found", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get product", err)
+		return
+	}
+
+	variant, err := cfg.DB.CreateVariant(r.Context(), database.CreateVariantParams{
+		ID:         uuid.New(),
+		ProductID:  productID,
+		Sku:        body.SKU,
+		Options:    options,
+		PriceCents: price,
+		Stock:      int32(body.Stock),
+	})
+	if respondVariantConflict(w, err) {
+		return
+	}
+	if foreignKeyViolation(err) != "" {
+		response.RespondWithError(w, http.StatusNotFound, "product not found", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't create variant", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusCreated, newVariantResponse(variant, product.PriceCents))
+}
+
+// handlerVariantsUpdate changes a variant. Omitted fields keep their value; a
+// price_cents of null makes the variant sell at the product's price again.
+// Stock is only written when given, so orders taking stock while the admin
+// edits something else aren't undone.
+func handlerVariantsUpdate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type updateVariantReq struct {
+		SKU        *string            `json:"sku"`
+		Options    *map[string]string `json:"options"`
+		PriceCents json.RawMessage    `json:"price_cents"`
+		Stock      *int               `json:"stock"`
+	}
+
+	productID, err := uuid.Parse(r.PathValue("productID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid product ID", err)
+		return
+	}
+	variantID, err := uuid.Parse(r.PathValue("variantID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid variant ID", err)
+		return
+	}
+
+	var body updateVariantReq
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid body", err)
+		return
+	}
+
+	product, err := cfg.DB.GetProduct(r.Context(), productID)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "product not found", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get product", err)
+		return
+	}
+
+	variant, err := cfg.DB.GetVariant(r.Context(), database.GetVariantParams{
+		ID:        variantID,
+		ProductID: productID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "variant not found", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't get variant", err)
+		return
+	}
+
+	params := database.UpdateVariantParams{
+		ID:         variant.ID,
+		Sku:        variant.Sku,
+		Options:    variant.Options,
+		PriceCents: variant.PriceCents,
+	}
+	if body.SKU != nil {
+		params.Sku = *body.SKU
+		if !skuPattern.MatchString(params.Sku) {
+			response.RespondWithError(w, http.StatusBadRequest, "sku must be up to 64 letters, digits, '.', '_' or '-'", nil)
+			return
+		}
+	}
+	if body.Options != nil {
+		params.Options, err = normalizeVariantOptions(*body.Options)
+		if err != nil {
+			response.RespondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+	if len(body.PriceCents) > 0 {
+		params.PriceCents = sql.NullInt32{}
+		if !bytes.Equal(body.PriceCents, []byte("null")) {
+			var price int
+			if err := json.Unmarshal(body.PriceCents, &price); err != nil {
+				response.RespondWithError(w, http.StatusBadRequest, "invalid price_cents", err)
+				return
+			}
+			if !validation.GreaterThan(price, 0) || price > math.MaxInt32 {
+				response.RespondWithError(w, http.StatusBadRequest, "price_cents must be > 0", nil)
+				return
+			}
+			params.PriceCents = sql.NullInt32{Int32: int32(price), Valid: true}
+		}
+	}
+	if body.Stock != nil {
+		if !validation.MinInt(*body.Stock, 0) {
+			response.RespondWithError(w, http.StatusBadRequest, "stock cannot be negative", nil)
+			return
+		}
+		if *body.Stock > math.MaxInt32 {
+			response.RespondWithError(w, http.StatusBadRequest, "stock is too large", nil)
+			return
+		}
+		params.Stock = sql.NullInt32{Int32: int32(*body.Stock), Valid: true}
+	}
+
+	variant, err = cfg.DB.UpdateVariant(r.Context(), params)
+	if respondVariantConflict(w, err) {
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't update variant", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, newVariantResponse(variant, product.PriceCents))
+}
+
+// handlerVariantsDelete deletes any variant but the product's default one,
+// which lives as long as the product does.
+func handlerVariantsDelete(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(r.PathValue("productID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid product ID", err)
+		return
+	}
+	variantID, err := uuid.Parse(r.PathValue("variantID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid variant ID", err)
+		return
+	}
+
+	rows, err := cfg.DB.DeleteVariant(r.Context(), database.DeleteVariantParams{
+		ID:        variantID,
+		ProductID: productID,
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "couldn't delete variant", err)
+		return
+	}
+	if rows == 0 {
+		variant, err := cfg.DB.GetVariant(r.Context(), database.GetVariantParams{
+			ID:        variantID,
+			ProductID: productID,
+		})
+		if err == nil && variant.IsDefault {
+			response.RespondWithError(w, http.StatusBadRequest, "the default variant can't be deleted", nil)
+			return
+		}
+		response.RespondWithError(w, http.StatusNotFound, "variant not found", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerInternalVariantGet is how other services look up a variant they are
+// about to sell: its product's name, the price it sells at and its stock.
+func handlerInternalVariantGet(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		ID         uuid.UUID       `json:"id"`
+		ProductID  uuid.UUID       `json:"product_id"`
+		Name       string          `json:"name"`
+		Sku        string          `json:"sku"`
+		Options    json.RawMessage `json:"options"`
+		PriceCents int32           `json:"price_cents"`
+		Stock      int32           `json:"stock"`
+	}
+
+	variantID, err := uuid.Parse(r.PathValue("variantID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid ID", err)
+		return
+	}
+
+	variant, err := cfg.DB.GetVariantForSale(r.Context(), variantID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusNotFound, "Couldn't get variant", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, resp(variant))
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+)
+
+func TestNormalizeVariantOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "none", options: nil, want: `{}`},
+		{name: "normalized", options: map[string]string{" Size ": " M ", "COLOR": "Navy Blue"}, want: `{"color":"Navy Blue","size":"M"}`},
+		{name: "values keep their case", options: map[string]string{"size": "XL"}, want: `{"size":"XL"}`},
+		{name: "multibyte at the limit", options: map[string]string{strings.Repeat("é", maxVariantOptionKey): strings.Repeat("ü", maxVariantOptionValue)}},
+		{name: "keys differing only in case", options: map[string]string{"Size": "M", "size": "L"}, wantErr: "duplicate option: size"},
+		{name: "keys differing only in spaces", options: map[string]string{"size": "M", " size": "L"}, wantErr: "duplicate option: size"},
+		{name: "blank key", options: map[string]string{"  ": "M"}, wantErr: "option names"},
+		{name: "long key", options: map[string]string{strings.Repeat("k", maxVariantOptionKey+1): "M"}, wantErr: "option names"},
+		{name: "blank value", options: map[string]string{"size": " "}, wantErr: "option values"},
+		{name: "long value", options: map[string]string{"size": strings.Repeat("v", maxVariantOptionValue+1)}, wantErr: "option values"},
+		{
+			name:    "too many",
+			options: map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6"},
+			wantErr: "at most 5 options",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeVariantOptions(tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeVariantOptions: %v", err)
+			}
+			if tt.want != "" && string(got) != tt.want {
+				t.Errorf("options = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerVariantsDelete(t *testing.T) {
+	productID, variantID := uuid.New(), uuid.New()
+	variantColumns := []string{"id", "created_at", "updated_at", "product_id", "sku", "options", "price_cents", "stock", "is_default"}
+
+	tests := []struct {
+		name       string
+		deleted    int64
+		isDefault  bool
+		exists     bool
+		wantStatus int
+	}{
+		{name: "deleted", deleted: 1, exists: true, wantStatus: http.StatusNoContent},
+		{name: "default variant", isDefault: true, exists: true, wantStatus: http.StatusBadRequest},
+		{name: "not found", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{affected: tt.deleted, respond: func(q fakeQuery) ([]string, [][]driver.Value) {
+				if q.is("GetVariant") && tt.exists {
+					now := time.Now()
+					return variantColumns, [][]driver.Value{{
+						variantID.String(), now, now, productID.String(), "MUG-1", []byte(`{}`), nil, int64(3), tt.isDefault,
+					}}
+				}
+				return variantColumns, nil
+			}}
+			cfg := newFakeConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodDelete, "/api/products/"+productID.String()+"/variants/"+variantID.String(), nil)
+			r.SetPathValue("productID", productID.String())
+			r.SetPathValue("variantID", variantID.String())
+			rec := httptest.NewRecorder()
+			handlerVariantsDelete(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			// The guard is in the statement itself, so it holds even if the
+			// variant became the default after we last looked
+			if !strings.Contains(d.queries[0].sql, "AND NOT is_default") {
+				t.Errorf("delete doesn't exclude the default variant:\n%s", d.queries[0].sql)
+			}
+		})
+	}
+}
+
+func TestHandlerVariantsCreateBounds(t *testing.T) {
+	productID := uuid.New()
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "price too large", body: `{"sku":"MUG-2","price_cents":2147483648}`, wantErr: "price_cents must be"},
+		{name: "stock too large", body: `{"sku":"MUG-2","stock":2147483648}`, wantErr: "stock is too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{}
+			cfg := newFakeConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodPost, "/admin/products/"+productID.String()+"/variants", strings.NewReader(tt.body))
+			r.SetPathValue("productID", productID.String())
+			rec := httptest.NewRecorder()
+			handlerVariantsCreate(cfg, rec, r)
+
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Fatalf("status = %d, body %s; want 400 %q", rec.Code, rec.Body, tt.wantErr)
+			}
+			if len(d.queries) != 0 {
+				t.Errorf("ran %d queries for an invalid variant", len(d.queries))
+			}
+		})
+	}
+}
+
+func TestHandlerVariantsUpdate(t *testing.T) {
+	productID, variantID := uuid.New(), uuid.New()
+	productColumns := []string{"id", "created_at", "updated_at", "name", "description", "price_cents", "is_active", "search_vector", "version"}
+	variantColumns := []string{"id", "created_at", "updated_at", "product_id", "sku", "options", "price_cents", "stock", "is_default"}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantErr    string
+		// wantStock is the stock argument sent to UpdateVariant, nil to keep
+		// the variant's current stock
+		wantStock driver.Value
+	}{
+		{name: "stock left alone", body: `{"sku":"MUG-2"}`, wantStatus: http.StatusOK},
+		{name: "stock set", body: `{"stock":7}`, wantStatus: http.StatusOK, wantStock: int64(7)},
+		{name: "price too large", body: `{"price_cents":2147483648}`, wantStatus: http.StatusBadRequest, wantErr: "price_cents must be"},
+		{name: "stock too large", body: `{"stock":2147483648}`, wantStatus: http.StatusBadRequest, wantErr: "stock is too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Orders take stock between the handler reading the variant and
+			// writing it back
+			const storedStock = 2
+			d := &fakeDriver{respond: func(q fakeQuery) ([]string, [][]driver.Value) {
+				now := time.Now()
+				switch {
+				case q.is("GetProduct"):
+					return productColumns, [][]driver.Value{{productID.String(), now, now, "Mug", nil, int64(900), true, "", int64(1)}}
+				case q.is("GetVariant"):
+					return variantColumns, [][]driver.Value{{variantID.String(), now, now, productID.String(), "MUG-1", []byte(`{}`), nil, int64(3), false}}
+				case q.is("UpdateVariant"):
+					stock := q.args[4]
+					if stock == nil {
+						stock = int64(storedStock)
+					}
+					return variantColumns, [][]driver.Value{{variantID.String(), now, now, productID.String(), q.args[1], q.args[2], q.args[3], stock, false}}
+				}
+				return nil, nil
+			}}
+			cfg := newFakeConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodPatch, "/admin/products/"+productID.String()+"/variants/"+variantID.String(), strings.NewReader(tt.body))
+			r.SetPathValue("productID", productID.String())
+			r.SetPathValue("variantID", variantID.String())
+			rec := httptest.NewRecorder()
+			handlerVariantsUpdate(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var update *fakeQuery
+			for _, q := range d.queries {
+				if q.is("UpdateVariant") {
+					update = &q
+				}
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(rec.Body.String(), tt.wantErr) {
+					t.Errorf("body = %s, want %q", rec.Body, tt.wantErr)
+				}
+				if update != nil {
+					t.Error("updated an invalid variant")
+				}
+				return
+			}
+
+			if update == nil {
+				t.Fatal("UpdateVariant wasn't run")
+			}
+			if update.args[4] != tt.wantStock {
+				t.Errorf("stock argument = %v, want %v", update.args[4], tt.wantStock)
+			}
+			if !strings.Contains(update.sql, "stock = coalesce($5, stock)") {
+				t.Errorf("update doesn't keep the stored stock when none is given:\n%s", update.sql)
+			}
+			var got variantResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.wantStock; want == nil && got.Stock != storedStock || want != nil && int64(got.Stock) != want {
+				t.Errorf("stock = %d, want the stored stock or the one given", got.Stock)
+			}
+		})
+	}
+}
+
+func TestProductResponseJSON(t *testing.T) {
+	product := database.Product{ID: uuid.New(), Name: "Mug", PriceCents: 900}
+	variants := []database.ProductVariant{{ID: uuid.New(), Stock: 3}, {ID: uuid.New(), Stock: 4}}
+
+	data, err := json.Marshal(newProductResponse(product, variants))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got["stock"]) != "7" {
+		t.Errorf("stock = %s, want the variants' total 7: %s", got["stock"], data)
+	}
+	if _, ok := got["Stock"]; ok {
+		t.Errorf("response has both Stock and stock: %s", data)
+	}
+}
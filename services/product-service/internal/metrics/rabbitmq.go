@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	MessagesPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_published_total",
+		Help: "Events published to RabbitMQ, by routing key.",
+	}, []string{"routing_key"})
+
+	PublishFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_publish_failures_total",
+		Help: "Events that could not be published, by routing key.",
+	}, []string{"routing_key"})
+)
+
+// RabbitMQ consumer counters, by routing key. A nack counts as nacked and as
+// either requeued or dead-lettered, so a rising dead-letter rate points at
+// messages that can never be processed.
+var (
+	MessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_consumed_total",
+		Help: "Messages delivered to the consumer, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesAcked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_acked_total",
+		Help: "Messages acknowledged after processing, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesNacked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_nacked_total",
+		Help: "Messages rejected, with or without requeue, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesRequeued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_requeued_total",
+		Help: "Messages rejected and put back on the queue, by routing key.",
+	}, []string{"routing_key"})
+
+	MessagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rabbitmq_messages_dead_lettered_total",
+		Help: "Messages rejected without requeue and sent to the dead-letter queue, by routing key.",
+	}, []string{"routing_key"})
+)
@@ -3,19 +3,39 @@ package rabbitmq
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"encoding/json"
+	"errors"
+	"fmt"
 
-	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/events"
+	"github.com/google/uuid"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/events"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/metrics"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	exchangeName = "orders"
+	queueName    = "product-stock-updates"
+	// stockUpdateFailedKey is published when an order event can never be
+	// applied, so the order side can compensate
+	stockUpdateFailedKey = "stock.update_failed"
+	// deadLetterExchange takes messages that failed maxDeliveries times or
+	// can never be processed, and routes them to deadLetterQueue for a
+	// person to look at
+	deadLetterExchange = "product-service.dead-letter"
+	deadLetterQueue    = queueName + ".dead-letter"
+	// maxDeliveries bounds how many times a message is tried before it is
+	// dead-lettered, so one that keeps failing can't loop forever
+	maxDeliveries = 5
+)
+
 type Consumer struct {
-	conn     *amqp.Connection
-	channel  *amqp.Channel
-	db       *sql.DB
-	queries  *database.Queries
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	db      *sql.DB
+	queries *database.Queries
+	publish func(ctx context.Context, requestID, routingKey string, event any) error
 }
 
 func NewConsumer(url string, db *sql.DB, queries *database.Queries) (*Consumer, error) {
@@ -33,27 +53,36 @@ func NewConsumer(url string, db *sql.DB, queries *database.Queries) (*Consumer,
 	}
 
 	err = ch.ExchangeDeclare(
-    "orders",
-    "topic",
-    true,   // durable
-    false,  // auto-delete
-    false,  // internal
-    false,  // no-wait
-    nil,
+		exchangeName,
+		"topic",
+		true,  // durable
+		false, // auto-delete
+		false, // internal
+		false, // no-wait
+		nil,
 	)
 	if err != nil {
-    return nil, fmt.Errorf("could not declare exchange: %w", err)
+		return nil, fmt.Errorf("could not declare exchange: %w", err)
 	}
 
+	if err := declareDeadLetter(ch); err != nil {
+		return nil, err
+	}
+
+	// A quorum queue counts redeliveries in x-delivery-count, and dead-letters
+	// a message itself if it somehow goes past the limit
 	queue, err := ch.QueueDeclare(
-		"product-stock-updates",
+		queueName,
 		true,
 		false,
 		false,
 		false,
-		nil,
+		amqp.Table{
+			amqp.QueueTypeArg:        amqp.QueueTypeQuorum,
+			"x-delivery-limit":       maxDeliveries,
+			"x-dead-letter-exchange": deadLetterExchange,
+		},
 	)
-
 	if err != nil {
 		return nil, fmt.Errorf("could not declare queue: %w", err)
 	}
@@ -61,25 +90,50 @@ func NewConsumer(url string, db *sql.DB, queries *database.Queries) (*Consumer,
 	err = ch.QueueBind(
 		queue.Name,
 		"order.*",
-		"orders",
+		exchangeName,
 		false,
 		nil,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("could not bind queue: %w", err)
 	}
-	return &Consumer{
-		conn:			conn,
-		channel:	ch,
-		db:				db,
-		queries:	queries,
-	}, nil
+	c := &Consumer{
+		conn:    conn,
+		channel: ch,
+		db:      db,
+		queries: queries,
+	}
+	c.publish = c.publishEvent
+	return c, nil
 }
 
+// declareDeadLetter sets up the exchange and queue failed messages end up in.
+func declareDeadLetter(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(deadLetterExchange, "fanout", true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not declare dead-letter exchange: %w", err)
+	}
+	_, err = ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not declare dead-letter queue: %w", err)
+	}
+	err = ch.QueueBind(deadLetterQueue, "", deadLetterExchange, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not bind dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+const consumerTag = "product-service"
+
+// Start consumes order events until ctx is cancelled or the channel closes.
+// Cancellation stops new deliveries but lets the message in hand finish and
+// be acked or nacked; unacked prefetched messages go back to the queue when
+// the channel closes.
 func (c *Consumer) Start(ctx context.Context) error {
 	msgs, err := c.channel.Consume(
-		"product-stock-updates",
-		"",
+		queueName,
+		consumerTag,
 		false,
 		false,
 		false,
@@ -89,52 +143,181 @@ func (c *Consumer) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("could not start consuming: %w", err)
 	}
-	
-	for msg := range msgs {
-		var event events.OrderEvent
-		err := json.Unmarshal(msg.Body, &event)
-		if err != nil {
-			fmt.Printf("could not unmarshal message: %v\n", err)
-			msg.Nack(false, false)
-      continue
+
+	// Work on a message is not tied to ctx so shutdown can't abort a stock
+	// update mid-transaction
+	work := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.channel.Cancel(consumerTag, false); err != nil {
+				return fmt.Errorf("could not cancel consumer: %w", err)
+			}
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return errors.New("delivery channel closed")
+			}
+			c.handle(work, msg)
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, msg amqp.Delivery) {
+	requestID := messageRequestID(msg)
+	metrics.MessagesConsumed.WithLabelValues(msg.RoutingKey).Inc()
+
+	var event events.OrderEvent
+	err := json.Unmarshal(msg.Body, &event)
+	if err != nil {
+		fmt.Printf("request_id=%s could not unmarshal message: %v\n", requestID, err)
+		nack(msg, false)
+		return
+	}
+	var multiplier int32
+	if msg.RoutingKey == "order.created" {
+		multiplier = -1
+	} else if msg.RoutingKey == "order.cancelled" {
+		multiplier = 1
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		fmt.Printf("request_id=%s could not begin tx: %v\n", requestID, err)
+		retry(msg)
+		return
+	}
+
+	qtx := c.queries.WithTx(tx)
+
+	for _, item := range event.Items {
+		// Without a variant the event predates variants, and the product's
+		// default variant has the product's ID
+		variantID := item.VariantID
+		if variantID == uuid.Nil {
+			variantID = item.ProductID
+		}
+		_, err := qtx.UpdateVariantStock(ctx, database.UpdateVariantStockParams{
+			ID:    variantID,
+			Stock: item.Quantity * multiplier,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// The variant is out of stock or gone, which redelivery won't
+			// change. Undo the whole event and report it instead.
+			tx.Rollback()
+			c.stockUpdateFailed(ctx, msg, requestID, event, variantID)
+			return
 		}
-		var multiplier int32
-		if msg.RoutingKey == "order.created" {
-			multiplier = -1
-		} else if msg.RoutingKey == "order.cancelled" {
-			multiplier = 1
-    }
-
-		tx, err := c.db.BeginTx(ctx, nil)
 		if err != nil {
-			fmt.Printf("could not begin tx: %v\n", err)
-			msg.Nack(false, true)
-      continue
-    }
-
-		qtx := c.queries.WithTx(tx)
-
-		success := true
-		for _, item := range event.Items {
-			_, err := qtx.UpdateStock(ctx, database.UpdateStockParams{
-				ID:    item.ProductID,
-				Stock: item.Quantity * multiplier,
-			})
-			if err != nil {
-				fmt.Printf("could not update stock for %s: %v\n", item.ProductID, err)
-				success = false
-				break
-			}
-    }
-		if success {
-			tx.Commit()
-			msg.Ack(false)
-			fmt.Printf("processed %s for order %s\n", msg.RoutingKey, event.OrderID)
-		} else {
+			fmt.Printf("request_id=%s could not update stock for variant %s: %v\n", requestID, variantID, err)
 			tx.Rollback()
-			msg.Nack(false, true)
+			retry(msg)
+			return
 		}
 	}
+	if err := tx.Commit(); err != nil {
+		fmt.Printf("request_id=%s could not commit stock update: %v\n", requestID, err)
+		retry(msg)
+		return
+	}
+	msg.Ack(false)
+	metrics.MessagesAcked.WithLabelValues(msg.RoutingKey).Inc()
+	fmt.Printf("request_id=%s processed %s for order %s\n", requestID, msg.RoutingKey, event.OrderID)
+}
+
+// stockUpdateFailed publishes a stock.update_failed event for an order event
+// that can never be applied, then acks it. If the event can't be published
+// the message is retried; nothing was applied, so that is safe.
+func (c *Consumer) stockUpdateFailed(ctx context.Context, msg amqp.Delivery, requestID string, event events.OrderEvent, variantID uuid.UUID) {
+	fmt.Printf("request_id=%s cannot apply %s for order %s: variant %s is out of stock or gone\n", requestID, msg.RoutingKey, event.OrderID, variantID)
+
+	err := c.publish(ctx, requestID, stockUpdateFailedKey, events.StockUpdateFailedEvent{
+		OrderID:   event.OrderID,
+		Event:     msg.RoutingKey,
+		VariantID: variantID,
+	})
+	if err != nil {
+		fmt.Printf("request_id=%s could not report failed stock update: %v\n", requestID, err)
+		retry(msg)
+		return
+	}
+	msg.Ack(false)
+	metrics.MessagesAcked.WithLabelValues(msg.RoutingKey).Inc()
+}
+
+// publishEvent sends event to the orders exchange, carrying over the request
+// ID of the message that caused it.
+func (c *Consumer) publishEvent(ctx context.Context, requestID, routingKey string, event any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cant marshal the struct: %w", err)
+	}
+
+	err = c.channel.PublishWithContext(
+		ctx,
+		exchangeName,
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: requestID,
+			Headers:       amqp.Table{"x-request-id": requestID},
+			Body:          data,
+		},
+	)
+	if err != nil {
+		metrics.PublishFailures.WithLabelValues(routingKey).Inc()
+		return fmt.Errorf("cant publish stock event: %w", err)
+	}
+	metrics.MessagesPublished.WithLabelValues(routingKey).Inc()
+	return nil
+}
+
+// nack rejects msg. Without requeue it goes to the dead-letter exchange.
+func nack(msg amqp.Delivery, requeue bool) {
+	msg.Nack(false, requeue)
+	metrics.MessagesNacked.WithLabelValues(msg.RoutingKey).Inc()
+	if requeue {
+		metrics.MessagesRequeued.WithLabelValues(msg.RoutingKey).Inc()
+	} else {
+		metrics.MessagesDeadLettered.WithLabelValues(msg.RoutingKey).Inc()
+	}
+}
+
+// retry puts msg back on the queue after a failure that may clear up, such
+// as the database being down, unless it has used up its maxDeliveries.
+func retry(msg amqp.Delivery) {
+	nack(msg, deliveryCount(msg)+1 < maxDeliveries)
+}
+
+// deliveryCount is how many times msg was delivered before this time.
+func deliveryCount(msg amqp.Delivery) int64 {
+	switch n := msg.Headers["x-delivery-count"].(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+// messageRequestID returns the request ID the publisher stamped on msg
+func messageRequestID(msg amqp.Delivery) string {
+	if id, ok := msg.Headers["x-request-id"].(string); ok && id != "" {
+		return id
+	}
+	return msg.CorrelationId
+}
+
+// Ready reports an error if the RabbitMQ connection or channel has closed.
+func (c *Consumer) Ready() error {
+	if c.conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if c.channel.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
 	return nil
 }
 
@@ -0,0 +1,281 @@
+package rabbitmq
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/events"
+)
+
+func TestMessageRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  amqp.Delivery
+		want string
+	}{
+		{
+			name: "header",
+			msg:  amqp.Delivery{Headers: amqp.Table{"x-request-id": "req-1"}, CorrelationId: "req-2"},
+			want: "req-1",
+		},
+		{
+			name: "correlation ID when the header is missing",
+			msg:  amqp.Delivery{CorrelationId: "req-2"},
+			want: "req-2",
+		},
+		{
+			name: "correlation ID when the header is empty",
+			msg:  amqp.Delivery{Headers: amqp.Table{"x-request-id": ""}, CorrelationId: "req-2"},
+			want: "req-2",
+		},
+		{
+			name: "correlation ID when the header is not a string",
+			msg:  amqp.Delivery{Headers: amqp.Table{"x-request-id": int32(7)}, CorrelationId: "req-2"},
+			want: "req-2",
+		},
+		{
+			name: "neither",
+			msg:  amqp.Delivery{},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageRequestID(tt.msg); got != tt.want {
+				t.Errorf("messageRequestID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// acknowledger records how a delivery was settled.
+type acknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked = true
+	a.requeue = requeue
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     amqp.Table
+		wantRequeue bool
+	}{
+		{name: "first delivery", headers: nil, wantRequeue: true},
+		{name: "redelivered", headers: amqp.Table{"x-delivery-count": int64(1)}, wantRequeue: true},
+		{name: "32-bit count", headers: amqp.Table{"x-delivery-count": int32(2)}, wantRequeue: true},
+		{name: "last delivery", headers: amqp.Table{"x-delivery-count": int64(maxDeliveries - 1)}, wantRequeue: false},
+		{name: "past the limit", headers: amqp.Table{"x-delivery-count": int64(maxDeliveries)}, wantRequeue: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &acknowledger{}
+			retry(amqp.Delivery{Acknowledger: ack, RoutingKey: "order.created", Headers: tt.headers})
+			if !ack.nacked || ack.acked {
+				t.Fatalf("acked = %v, nacked = %v, want a nack", ack.acked, ack.nacked)
+			}
+			if ack.requeue != tt.wantRequeue {
+				t.Errorf("requeue = %v, want %v", ack.requeue, tt.wantRequeue)
+			}
+		})
+	}
+}
+
+// stockDriver answers UpdateVariantStock for the variants in stock, as
+// Postgres would: no row when the change would take stock below zero or the
+// variant doesn't exist. It records how each transaction ended.
+type stockDriver struct {
+	mu        sync.Mutex
+	stock     map[uuid.UUID]int32
+	err       error
+	updates   []uuid.UUID
+	commits   int
+	rollbacks int
+}
+
+func (d *stockDriver) Open(string) (driver.Conn, error) { return stockConn{d}, nil }
+
+type stockConn struct{ d *stockDriver }
+
+func (c stockConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c stockConn) Close() error                        { return nil }
+func (c stockConn) Begin() (driver.Tx, error)           { return stockTx{c.d}, nil }
+
+type stockTx struct{ d *stockDriver }
+
+func (tx stockTx) Commit() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.commits++
+	return nil
+}
+
+func (tx stockTx) Rollback() error {
+	tx.d.mu.Lock()
+	defer tx.d.mu.Unlock()
+	tx.d.rollbacks++
+	return nil
+}
+
+func (c stockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.d.err != nil {
+		return nil, c.d.err
+	}
+	id := uuid.MustParse(args[0].Value.(string))
+	c.d.updates = append(c.d.updates, id)
+	stock, ok := c.d.stock[id]
+	stock += int32(args[1].Value.(int64))
+	rows := &stockRows{}
+	if ok && stock >= 0 {
+		now := time.Now()
+		rows.row = []driver.Value{id.String(), now, now, uuid.NewString(), "SKU", []byte(`{}`), nil, int64(stock), false}
+	}
+	return rows, nil
+}
+
+type stockRows struct{ row []driver.Value }
+
+func (r *stockRows) Columns() []string {
+	return []string{"id", "created_at", "updated_at", "product_id", "sku", "options", "price_cents", "stock", "is_default"}
+}
+func (r *stockRows) Close() error { return nil }
+
+func (r *stockRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+func TestHandleOrderEvent(t *testing.T) {
+	orderID, mug, kettle := uuid.New(), uuid.New(), uuid.New()
+	order := `{"order_id":"` + orderID.String() + `","items":[` +
+		`{"product_id":"` + uuid.NewString() + `","variant_id":"` + mug.String() + `","quantity":2},` +
+		`{"product_id":"` + uuid.NewString() + `","variant_id":"` + kettle.String() + `","quantity":1}]}`
+
+	tests := []struct {
+		name        string
+		routingKey  string
+		stock       map[uuid.UUID]int32
+		dbErr       error
+		publishErr  error
+		wantCommit  bool
+		wantAck     bool
+		wantRequeue bool
+		wantFailed  *events.StockUpdateFailedEvent
+	}{
+		{
+			name:       "stock taken",
+			routingKey: "order.created",
+			stock:      map[uuid.UUID]int32{mug: 5, kettle: 1},
+			wantCommit: true,
+			wantAck:    true,
+		},
+		{
+			// Redelivering won't conjure up stock, so the order side is told
+			// and the message is done with
+			name:       "out of stock",
+			routingKey: "order.created",
+			stock:      map[uuid.UUID]int32{mug: 5, kettle: 0},
+			wantAck:    true,
+			wantFailed: &events.StockUpdateFailedEvent{OrderID: orderID, Event: "order.created", VariantID: kettle},
+		},
+		{
+			name:       "variant deleted",
+			routingKey: "order.cancelled",
+			stock:      map[uuid.UUID]int32{mug: 5},
+			wantAck:    true,
+			wantFailed: &events.StockUpdateFailedEvent{OrderID: orderID, Event: "order.cancelled", VariantID: kettle},
+		},
+		{
+			// Nothing was applied, so trying again later is safe
+			name:        "out of stock and the report can't be sent",
+			routingKey:  "order.created",
+			stock:       map[uuid.UUID]int32{mug: 5, kettle: 0},
+			publishErr:  errors.New("channel closed"),
+			wantRequeue: true,
+			wantFailed:  &events.StockUpdateFailedEvent{OrderID: orderID, Event: "order.created", VariantID: kettle},
+		},
+		{
+			name:        "database down",
+			routingKey:  "order.created",
+			dbErr:       errors.New("connection refused"),
+			wantRequeue: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &stockDriver{stock: tt.stock, err: tt.dbErr}
+			name := "stock-" + uuid.NewString()
+			sql.Register(name, d)
+			db, err := sql.Open(name, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			var published []any
+			c := &Consumer{db: db, queries: database.New(db)}
+			c.publish = func(ctx context.Context, requestID, routingKey string, event any) error {
+				if routingKey != stockUpdateFailedKey {
+					t.Errorf("published %s, want %s", routingKey, stockUpdateFailedKey)
+				}
+				published = append(published, event)
+				return tt.publishErr
+			}
+			ack := &acknowledger{}
+			c.handle(context.Background(), amqp.Delivery{Acknowledger: ack, RoutingKey: tt.routingKey, Body: []byte(order)})
+
+			if ack.acked != tt.wantAck || ack.nacked == tt.wantAck {
+				t.Fatalf("acked = %v, nacked = %v, want acked %v", ack.acked, ack.nacked, tt.wantAck)
+			}
+			if ack.requeue != tt.wantRequeue {
+				t.Errorf("requeue = %v, want %v", ack.requeue, tt.wantRequeue)
+			}
+			if committed := d.commits == 1 && d.rollbacks == 0; committed != tt.wantCommit {
+				t.Errorf("commits = %d, rollbacks = %d, want committed %v", d.commits, d.rollbacks, tt.wantCommit)
+			}
+			if tt.wantFailed == nil {
+				if len(published) != 0 {
+					t.Errorf("published %v, want nothing", published)
+				}
+				return
+			}
+			if len(published) != 1 || published[0] != *tt.wantFailed {
+				t.Errorf("published %+v, want %+v", published, *tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		inbound string
+		keep    bool
+	}{
+		{name: "valid ID is kept", inbound: "checkout-42_a.b:c", keep: true},
+		{name: "UUID is kept", inbound: "6f1c2b8e-3a4d-4e5f-8a9b-0c1d2e3f4a5b", keep: true},
+		{name: "longest allowed ID is kept", inbound: strings.Repeat("a", maxLength), keep: true},
+		{name: "missing ID is generated"},
+		{name: "too long ID is replaced", inbound: strings.Repeat("a", maxLength+1)},
+		{name: "ID with spaces is replaced", inbound: "abc def"},
+		{name: "ID with a newline is replaced", inbound: "abc\r\nX-Admin: 1"},
+		{name: "non-ASCII ID is replaced", inbound: "abcé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fromContext, fromHeader string
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = FromContext(r.Context())
+				fromHeader = r.Header.Get(Header)
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.inbound != "" {
+				req.Header.Set(Header, tt.inbound)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(Header)
+			if tt.keep {
+				if id != tt.inbound {
+					t.Errorf("response ID = %q, want %q", id, tt.inbound)
+				}
+			} else if _, err := uuid.Parse(id); err != nil {
+				t.Errorf("response ID = %q, want a generated UUID", id)
+			}
+			if fromContext != id {
+				t.Errorf("context ID = %q, want %q", fromContext, id)
+			}
+			if fromHeader != id {
+				t.Errorf("forwarded header = %q, want %q", fromHeader, id)
+			}
+			if rec.Code != http.StatusTeapot {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusTeapot)
+			}
+		})
+	}
+}
+
+func TestStatusRecorder(t *testing.T) {
+	rec := &statusRecorder{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	rec.WriteHeader(http.StatusNotFound)
+	rec.WriteHeader(http.StatusInternalServerError)
+	if rec.status != http.StatusNotFound {
+		t.Errorf("status = %d, want the first one written", rec.status)
+	}
+	if rec.Unwrap() == nil {
+		t.Error("Unwrap returned nil")
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
-	"context"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -13,21 +16,40 @@ import (
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/database"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/handlers"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/metrics"
 	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/rabbitmq"
+	"github.com/herodragmon/scalable-ecommerce/services/product-service/internal/requestid"
 )
 
 func main() {
 	godotenv.Load()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	platform := os.Getenv("PLATFORM")
 	dbURL := os.Getenv("DB_URL")
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8082"
 	}
+	shutdownTimeout := 15 * time.Second
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid duration for SHUTDOWN_TIMEOUT: %v", err)
+		}
+		shutdownTimeout = d
+	}
+	internalAuthKey := os.Getenv("INTERNAL_AUTH_KEY")
+	if internalAuthKey == "" {
+		log.Fatal("INTERNAL_AUTH_KEY is not set")
+	}
+	signer := internalauth.NewSigner(internalAuthKey, "product-service")
 	rabbitmqURL := os.Getenv("RABBITMQ_URL")
 	if rabbitmqURL == "" {
-    log.Fatal("RABBITMQ_URL is not set")
+		log.Fatal("RABBITMQ_URL is not set")
 	}
 
 	db, err := sql.Open("postgres", dbURL)
@@ -39,19 +61,25 @@ func main() {
 		log.Fatalf("failed to ping database: %v", err)
 	}
 
-	dbQueries := database.New(db)
+	dbQueries := database.New(metrics.InstrumentDB(db))
 
 	consumer, err := rabbitmq.NewConsumer(rabbitmqURL, db, dbQueries)
 	if err != nil {
-		log.Fatalf("failed to consume: %v",err)
+		log.Fatalf("failed to consume: %v", err)
 	}
-	defer consumer.Close()
-
-	go consumer.Start(context.Background())
 
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := consumer.Start(ctx); err != nil {
+			log.Printf("consumer stopped: %v", err)
+		}
+	}()
 
 	cfg := &config.Config{
 		DB:       dbQueries,
+		DBConn:   db,
+		Consumer: consumer,
 		Platform: platform,
 	}
 
@@ -60,9 +88,36 @@ func main() {
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: requestid.Middleware(metrics.Middleware(mux, internalauth.Middleware(signer, mux))),
 	}
 
-	log.Printf("Product service starting on port %s", port)
-	log.Fatal(server.ListenAndServe())
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Product service starting on port %s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Printf("Product service shutting down")
+
+	// Drain HTTP first so in-flight requests can still use the dependencies
+	// closed below
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to drain HTTP server: %v", err)
+	}
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		log.Printf("timed out waiting for consumer to finish")
+	}
+	consumer.Close()
+	db.Close()
+	log.Printf("Product service stopped")
 }
@@ -0,0 +1,61 @@
+-- name: CreateCategory :one
+INSERT INTO categories (
+  id,
+  created_at,
+  updated_at,
+  parent_id,
+  name,
+  slug
+) VALUES (
+    gen_random_uuid(),
+    now(),
+    now(),
+    $1,
+    $2,
+    $3
+) RETURNING *;
+
+-- name: GetCategoryByID :one
+SELECT * FROM categories WHERE id = $1;
+
+-- name: GetCategoryBySlug :one
+SELECT * FROM categories WHERE slug = $1;
+
+-- name: ListCategories :many
+SELECT * FROM categories
+ORDER BY name, id;
+
+-- name: ListCategoryDescendantIDs :many
+-- The category itself and every category below it. UNION rather than UNION
+-- ALL stops at categories already seen, should a loop ever form.
+WITH RECURSIVE tree AS (
+  SELECT categories.id FROM categories WHERE categories.id = $1
+  UNION
+  SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+)
+SELECT id FROM tree;
+
+-- name: UpdateCategory :one
+UPDATE categories
+SET
+  parent_id = $2,
+  name = $3,
+  slug = $4,
+  updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteCategory :execrows
+DELETE FROM categories WHERE id = $1;
+
+-- name: ListProductCategories :many
+SELECT * FROM categories
+WHERE id IN (SELECT category_id FROM product_categories WHERE product_id = $1)
+ORDER BY name, id;
+
+-- name: ClearProductCategories :exec
+DELETE FROM product_categories WHERE product_id = $1;
+
+-- name: AddProductCategory :exec
+INSERT INTO product_categories (product_id, category_id)
+VALUES ($1, $2);
@@ -1,14 +1,142 @@
--- name: GetProducts :many
-SELECT id, name, price_cents, stock
-FROM products
-WHERE is_active = true
-ORDER BY created_at DESC;
+-- name: ListProductsNewest :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND (sqlc.narg('min_price')::int IS NULL OR listing.price_cents >= sqlc.narg('min_price'))
+  AND (sqlc.narg('max_price')::int IS NULL OR listing.price_cents <= sqlc.narg('max_price'))
+  AND (NOT sqlc.arg('in_stock')::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND (sqlc.narg('category_ids')::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY(sqlc.narg('category_ids'))
+  ))
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL
+    OR (p.created_at, p.id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListProductsByPriceAsc :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND (sqlc.narg('min_price')::int IS NULL OR listing.price_cents >= sqlc.narg('min_price'))
+  AND (sqlc.narg('max_price')::int IS NULL OR listing.price_cents <= sqlc.narg('max_price'))
+  AND (NOT sqlc.arg('in_stock')::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND (sqlc.narg('category_ids')::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY(sqlc.narg('category_ids'))
+  ))
+  AND (sqlc.narg('after_price')::int IS NULL
+    OR (listing.price_cents, p.id) > (sqlc.narg('after_price'), sqlc.narg('after_id')::uuid))
+ORDER BY listing.price_cents, p.id
+LIMIT sqlc.arg('limit');
+
+-- name: ListProductsByPriceDesc :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND (sqlc.narg('min_price')::int IS NULL OR listing.price_cents >= sqlc.narg('min_price'))
+  AND (sqlc.narg('max_price')::int IS NULL OR listing.price_cents <= sqlc.narg('max_price'))
+  AND (NOT sqlc.arg('in_stock')::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND (sqlc.narg('category_ids')::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY(sqlc.narg('category_ids'))
+  ))
+  AND (sqlc.narg('after_price')::int IS NULL
+    OR (listing.price_cents, p.id) < (sqlc.narg('after_price'), sqlc.narg('after_id')::uuid))
+ORDER BY listing.price_cents DESC, p.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListProductsByName :many
+SELECT
+  p.id,
+  p.name,
+  listing.price_cents,
+  listing.stock,
+  p.created_at
+FROM products p
+CROSS JOIN LATERAL (
+  SELECT
+    coalesce(min(coalesce(v.price_cents, p.price_cents)), p.price_cents)::int AS price_cents,
+    coalesce(sum(v.stock), 0)::int AS stock
+  FROM product_variants v
+  WHERE v.product_id = p.id
+) listing
+WHERE p.is_active = true
+  AND (sqlc.narg('min_price')::int IS NULL OR listing.price_cents >= sqlc.narg('min_price'))
+  AND (sqlc.narg('max_price')::int IS NULL OR listing.price_cents <= sqlc.narg('max_price'))
+  AND (NOT sqlc.arg('in_stock')::bool OR EXISTS (
+    SELECT 1 FROM product_variants v
+    WHERE v.product_id = p.id AND v.stock > 0
+  ))
+  AND (sqlc.narg('category_ids')::uuid[] IS NULL OR EXISTS (
+    SELECT 1 FROM product_categories pc
+    WHERE pc.product_id = p.id AND pc.category_id = ANY(sqlc.narg('category_ids'))
+  ))
+  AND (sqlc.narg('after_name')::text IS NULL
+    OR (p.name, p.id) > (sqlc.narg('after_name'), sqlc.narg('after_id')::uuid))
+ORDER BY p.name, p.id
+LIMIT sqlc.arg('limit');
 
 -- name: GetProductByID :one
-SELECT id, name, price_cents, stock
+SELECT
+  id,
+  name,
+  price_cents,
+  (SELECT coalesce(sum(v.stock), 0) FROM product_variants v WHERE v.product_id = products.id)::int AS stock,
+  version
 FROM products
 WHERE is_active = true AND id = $1;
 
+-- name: GetProduct :one
+-- Any product, active or not, for admin changes
+SELECT * FROM products
+WHERE id = $1;
+
 -- name: CreateProduct :one
 INSERT INTO products (
   id,
@@ -17,7 +145,6 @@ INSERT INTO products (
   name,
   description,
   price_cents,
-  stock,
   is_active
 ) VALUES (
     gen_random_uuid(),
@@ -26,27 +153,36 @@ INSERT INTO products (
     $1,
     $2,
     $3,
-    $4,
-    $5
+    $4
 ) RETURNING *;
 
 -- name: UpdateProduct :one
+-- Only applies if the product is still at the version the change was made
+-- from
 UPDATE products
 SET
   name = $2,
   description = $3,
   price_cents = $4,
-  stock = $5,
-  is_active = $6,
+  is_active = $5,
+  version = version + 1,
   updated_at = now()
-WHERE id = $1
-RETURNING *;
-
--- name: UpdateStock :one
-UPDATE products 
-SET stock = stock + $2, updated_at = now()
-WHERE id = $1 AND stock + $2 >= 0
+WHERE id = $1 AND version = $6
 RETURNING *;
 
 -- name: DeleteProduct :exec
 DELETE FROM products WHERE id = $1;
+
+-- name: SearchProducts :many
+SELECT
+  id,
+  name,
+  price_cents,
+  (SELECT coalesce(sum(v.stock), 0) FROM product_variants v WHERE v.product_id = products.id)::int AS stock,
+  ts_rank(search_vector, query)::real AS rank,
+  ts_headline('english', name, query, 'StartSel=**, StopSel=**, HighlightAll=true') AS headline,
+  ts_headline('english', coalesce(description, ''), query, 'StartSel=**, StopSel=**, MaxWords=20, MinWords=8, MaxFragments=2') AS snippet
+FROM products, to_tsquery('english', sqlc.arg('query')) AS query
+WHERE is_active = true AND search_vector @@ query
+ORDER BY rank DESC, id
+LIMIT sqlc.arg('limit');
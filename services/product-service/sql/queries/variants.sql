@@ -0,0 +1,67 @@
+-- name: CreateVariant :one
+INSERT INTO product_variants (
+  id,
+  created_at,
+  updated_at,
+  product_id,
+  sku,
+  options,
+  price_cents,
+  stock,
+  is_default
+) VALUES (
+    $1,
+    now(),
+    now(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+) RETURNING *;
+
+-- name: ListProductVariants :many
+SELECT * FROM product_variants
+WHERE product_id = $1
+ORDER BY is_default DESC, created_at, id;
+
+-- name: GetVariant :one
+SELECT * FROM product_variants
+WHERE id = $1 AND product_id = $2;
+
+-- name: GetVariantForSale :one
+-- A variant of an active product, priced with the product's price unless it
+-- overrides it
+SELECT
+  v.id,
+  v.product_id,
+  p.name,
+  v.sku,
+  v.options,
+  coalesce(v.price_cents, p.price_cents)::int AS price_cents,
+  v.stock
+FROM product_variants v
+JOIN products p ON p.id = v.product_id
+WHERE v.id = $1 AND p.is_active = true;
+
+-- name: UpdateVariant :one
+UPDATE product_variants
+SET
+  sku = $2,
+  options = $3,
+  price_cents = $4,
+  stock = coalesce(sqlc.narg('stock'), stock),
+  updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteVariant :execrows
+DELETE FROM product_variants
+WHERE id = $1 AND product_id = $2 AND NOT is_default;
+
+-- name: UpdateVariantStock :one
+UPDATE product_variants
+SET stock = stock + $2, updated_at = now()
+WHERE id = $1 AND stock + $2 >= 0
+RETURNING *;
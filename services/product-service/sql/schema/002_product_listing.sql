@@ -0,0 +1,11 @@
+-- +goose Up
+-- One index per listing sort. The id column breaks ties so cursors are stable,
+-- and price_desc walks the price index backwards.
+CREATE INDEX idx_products_active_created_at ON products(created_at DESC, id DESC) WHERE is_active;
+CREATE INDEX idx_products_active_price ON products(price_cents, id) WHERE is_active;
+CREATE INDEX idx_products_active_name ON products(name, id) WHERE is_active;
+
+-- +goose Down
+DROP INDEX idx_products_active_name;
+DROP INDEX idx_products_active_price;
+DROP INDEX idx_products_active_created_at;
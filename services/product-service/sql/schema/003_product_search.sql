@@ -0,0 +1,12 @@
+-- +goose Up
+-- Names outrank descriptions when search results are ranked
+ALTER TABLE products ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX idx_products_search_vector;
+ALTER TABLE products DROP COLUMN search_vector;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE categories (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    -- A category with subcategories can't be deleted until they are moved
+    -- or deleted first
+    parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+
+CREATE TABLE product_categories (
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX idx_product_categories_category_id ON product_categories(category_id);
+
+-- +goose Down
+DROP TABLE product_categories;
+DROP TABLE categories;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- What is actually sold and stocked. A product's price is the default that a
+-- variant can override.
+CREATE TABLE product_variants (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku TEXT NOT NULL UNIQUE,
+    -- Option values such as {"size": "M", "color": "red"}
+    options JSONB NOT NULL DEFAULT '{}',
+    price_cents INT,
+    stock INT NOT NULL DEFAULT 0,
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    UNIQUE (product_id, options)
+);
+
+CREATE INDEX idx_product_variants_product_id ON product_variants(product_id);
+CREATE UNIQUE INDEX idx_product_variants_default ON product_variants(product_id) WHERE is_default;
+
+-- Every existing product becomes its own default variant. Reusing the
+-- product's ID for it means cart items, order items and in-flight events
+-- that hold a product ID already name the right variant.
+INSERT INTO product_variants (id, created_at, updated_at, product_id, sku, options, price_cents, stock, is_default)
+SELECT id, created_at, updated_at, id, 'SKU-' || id, '{}', NULL, stock, true
+FROM products;
+
+ALTER TABLE products DROP COLUMN stock;
+
+-- +goose Down
+ALTER TABLE products ADD COLUMN stock INT NOT NULL DEFAULT 0;
+
+UPDATE products p
+SET stock = (SELECT coalesce(sum(v.stock), 0) FROM product_variants v WHERE v.product_id = p.id);
+
+DROP TABLE product_variants;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Bumped on every update and sent as the product's ETag, so an update made
+-- from a stale copy can be refused
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE products DROP COLUMN version;
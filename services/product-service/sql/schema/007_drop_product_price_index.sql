@@ -0,0 +1,7 @@
+-- +goose Up
+-- Listings sort and filter on the cheapest variant's price, which this index
+-- on the product's own price can't serve
+DROP INDEX idx_products_active_price;
+
+-- +goose Down
+CREATE INDEX idx_products_active_price ON products(price_cents, id) WHERE is_active;
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alexedwards/argon2id"
@@ -15,6 +16,9 @@ import (
 
 type Claims struct {
 	Role string `json:"role"`
+	// SessionID ties the token to the login it was issued under, so ending
+	// that session revokes it.
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -34,54 +38,105 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return match, nil
 }
 
+// dummyHash stands in for the stored hash when a login names an account
+// that doesn't exist, so the response takes as long as a wrong password.
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword(MakeRefreshToken())
+	return hash
+})
+
+// CheckDummyPasswordHash does the work of CheckPasswordHash without a real
+// hash. The result is always a mismatch.
+func CheckDummyPasswordHash(password string) {
+	argon2id.ComparePasswordAndHash(password, dummyHash())
+}
+
+// WarmDummyHash computes the dummy hash ahead of the first login.
+func WarmDummyHash() {
+	dummyHash()
+}
+
+// Issuer and Audience are stamped on every access token; the gateway
+// rejects tokens that don't carry both.
+const (
+	Issuer   = "user-service"
+	Audience = "api-gateway"
+)
+
 func MakeJWT(
 	userID uuid.UUID,
 	role string,
-	tokenSecret string,
+	sessionID uuid.UUID,
+	keys *KeySet,
 	expiresIn time.Duration,
 ) (string, error) {
 	claims := Claims{
-		Role: role,
+		Role:      role,
+		SessionID: sessionID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-			Issuer:    "user-service",
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
 			Subject:   userID.String(),
+			ID:        uuid.NewString(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString([]byte(tokenSecret))
+	kid, key := keys.signingKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	ss, err := token.SignedString(key)
 	if err != nil {
 		return "", err
 	}
 	return ss, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, string, error) {
+// ParseJWT verifies an access token and returns its claims. It does not
+// check revocation.
+func ParseJWT(tokenString string, keys *KeySet) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.publicKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
-		return uuid.Nil, "", err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return uuid.Nil, "", fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
 	}
-
-	userID, err := uuid.Parse(claims.Subject)
-	if err != nil {
-		return uuid.Nil, "", err
+	if _, err := uuid.Parse(claims.Subject); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(claims.ID); err != nil {
+		return nil, fmt.Errorf("invalid jti in token: %w", err)
+	}
+	if claims.SessionID != "" {
+		if _, err := uuid.Parse(claims.SessionID); err != nil {
+			return nil, fmt.Errorf("invalid sid in token: %w", err)
+		}
+	}
+	if claims.IssuedAt == nil {
+		return nil, fmt.Errorf("missing iat in token")
 	}
-
 	if claims.Role == "" {
-		return uuid.Nil, "", fmt.Errorf("missing role in token")
+		return nil, fmt.Errorf("missing role in token")
 	}
-
-	return userID, claims.Role, nil
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeySet holds the Ed25519 keys used to sign access tokens. Every key is
+// published in the JWKS so tokens signed before a rotation keep verifying,
+// but only the active key signs new tokens.
+type KeySet struct {
+	activeKID string
+	keys      map[string]ed25519.PrivateKey
+}
+
+// JWK is the public half of a signing key, as published in the JWKS.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// LoadKeySet reads PKCS#8 PEM Ed25519 private keys from dir. Each file is
+// named <kid>.pem. activeKID may be empty when dir holds a single key.
+func LoadKeySet(dir, activeKID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no signing keys in %s", dir)
+	}
+
+	ks := &KeySet{keys: make(map[string]ed25519.PrivateKey, len(paths))}
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := readPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key %s: %w", kid, err)
+		}
+		ks.keys[kid] = key
+	}
+
+	if activeKID == "" {
+		if len(ks.keys) > 1 {
+			return nil, errors.New("several signing keys found, set the active key ID")
+		}
+		for kid := range ks.keys {
+			activeKID = kid
+		}
+	}
+	if _, ok := ks.keys[activeKID]; !ok {
+		return nil, fmt.Errorf("active key %q not found in %s", activeKID, dir)
+	}
+	ks.activeKID = activeKID
+	return ks, nil
+}
+
+// GenerateKeySet creates a single random key. Tokens signed with it become
+// unverifiable on restart, so it is only meant for development.
+func GenerateKeySet() (*KeySet, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	kid := "dev-" + hex.EncodeToString(id)
+
+	return &KeySet{
+		activeKID: kid,
+		keys:      map[string]ed25519.PrivateKey{kid: key},
+	}, nil
+}
+
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("expected a PKCS#8 PRIVATE KEY PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an Ed25519 key, got %T", parsed)
+	}
+	return key, nil
+}
+
+// ActiveKID is the ID of the key that signs new tokens.
+func (ks *KeySet) ActiveKID() string {
+	return ks.activeKID
+}
+
+func (ks *KeySet) signingKey() (string, ed25519.PrivateKey) {
+	return ks.activeKID, ks.keys[ks.activeKID]
+}
+
+func (ks *KeySet) publicKey(kid string) (ed25519.PublicKey, bool) {
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	return key.Public().(ed25519.PublicKey), true
+}
+
+// JWKS returns the public keys, sorted by key ID.
+func (ks *KeySet) JWKS() JWKS {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		public, _ := ks.publicKey(kid)
+		jwks.Keys = append(jwks.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(public),
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+		})
+	}
+	return jwks
+}
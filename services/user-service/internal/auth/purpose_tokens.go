@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Token purposes. Each is used as the token's audience so a token
+// minted for one purpose, or an access token, can't stand in for another.
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+	PurposeMFALogin      = "mfa-login"
+	PurposeMFASetup      = "mfa-setup"
+)
+
+// PurposeClaims are carried by short-lived tokens that authorize one step,
+// such as verifying an email or finishing a two-step login. Binding ties the
+// token to account state, so it stops working once that state changes: the
+// email address for verification, the password hash for everything else.
+type PurposeClaims struct {
+	Binding string `json:"bnd"`
+	jwt.RegisteredClaims
+}
+
+// Fingerprint is a short digest of value suitable for PurposeClaims.Binding.
+func Fingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(sum[:16])
+}
+
+func MakePurposeToken(
+	userID uuid.UUID,
+	purpose string,
+	binding string,
+	keys *KeySet,
+	expiresIn time.Duration,
+) (string, error) {
+	claims := PurposeClaims{
+		Binding: binding,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{purpose},
+			Subject:   userID.String(),
+			ID:        uuid.NewString(),
+		},
+	}
+
+	kid, key := keys.signingKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// ParsePurposeToken verifies a token minted for purpose and returns the user
+// it was issued to. The caller must still compare the binding.
+func ParsePurposeToken(tokenString, purpose string, keys *KeySet) (uuid.UUID, *PurposeClaims, error) {
+	claims := &PurposeClaims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys.publicKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(purpose),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+	return userID, claims, nil
+}
+
+// Bound reports whether the token's binding matches value.
+func (c *PurposeClaims) Bound(value string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.Binding), []byte(Fingerprint(value))) == 1
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestKeySet(t *testing.T) *KeySet {
+	t.Helper()
+	keys, err := GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestPurposeToken(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+	hash := "$argon2id$v=19$m=65536,t=1,p=4$c2FsdA$aGFzaA"
+
+	token, err := MakePurposeToken(userID, PurposeResetPassword, Fingerprint(hash), keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotID, claims, err := ParsePurposeToken(token, PurposeResetPassword, keys)
+	if err != nil {
+		t.Fatalf("ParsePurposeToken: %v", err)
+	}
+	if gotID != userID {
+		t.Errorf("user = %s, want %s", gotID, userID)
+	}
+	if !claims.Bound(hash) {
+		t.Error("token isn't bound to the hash it was made for")
+	}
+	// A new password means a new hash, which retires the token
+	if claims.Bound(hash + "x") {
+		t.Error("token is bound to a different hash")
+	}
+	if claims.Bound("") {
+		t.Error("token is bound to an empty value")
+	}
+}
+
+func TestParsePurposeTokenRejects(t *testing.T) {
+	keys := newTestKeySet(t)
+	userID := uuid.New()
+	mint := func(purpose string, expiresIn time.Duration) string {
+		token, err := MakePurposeToken(userID, purpose, Fingerprint("a@example.com"), keys, expiresIn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	access, err := MakeJWT(userID, "customer", uuid.New(), keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := mint(PurposeVerifyEmail, time.Hour)
+	// The valid token's claims under another token's signature
+	other := mint(PurposeVerifyEmail, 2*time.Hour)
+	tampered := valid[:strings.LastIndex(valid, ".")] + other[strings.LastIndex(other, "."):]
+
+	tests := []struct {
+		name  string
+		token string
+		keys  *KeySet
+	}{
+		{name: "expired", token: mint(PurposeVerifyEmail, -time.Minute), keys: keys},
+		{name: "other purpose", token: mint(PurposeResetPassword, time.Hour), keys: keys},
+		{name: "access token", token: access, keys: keys},
+		{name: "signed by another key", token: valid, keys: newTestKeySet(t)},
+		{name: "bad signature", token: tampered, keys: keys},
+		{name: "garbage", token: "not.a.token", keys: keys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParsePurposeToken(tt.token, PurposeVerifyEmail, tt.keys); err == nil {
+				t.Error("ParsePurposeToken accepted the token")
+			}
+		})
+	}
+}
+
+func TestPurposeTokenExpiry(t *testing.T) {
+	keys := newTestKeySet(t)
+	before := time.Now()
+	token, err := MakePurposeToken(uuid.New(), PurposeVerifyEmail, Fingerprint("a@example.com"), keys, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, claims, err := ParsePurposeToken(token, PurposeVerifyEmail, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := before.Add(24 * time.Hour).Truncate(time.Second)
+	if got := claims.ExpiresAt.Time; got.Before(want) || got.After(want.Add(2*time.Second)) {
+		t.Errorf("expires at %v, want about %v", got, want)
+	}
+	if claims.ID == "" {
+		t.Error("token has no jti")
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != PurposeVerifyEmail {
+		t.Errorf("audience = %v, want [%s]", claims.Audience, PurposeVerifyEmail)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint("a@example.com") != Fingerprint("a@example.com") {
+		t.Error("Fingerprint isn't deterministic")
+	}
+	if Fingerprint("a@example.com") == Fingerprint("b@example.com") {
+		t.Error("different values have the same fingerprint")
+	}
+	// The binding ends up in the token, so it mustn't carry the value itself
+	if strings.Contains(Fingerprint("a@example.com"), "example") {
+		t.Error("Fingerprint leaks the value")
+	}
+}
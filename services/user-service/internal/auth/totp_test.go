@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"encoding/base32"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed from RFC 6238 appendix B, "12345678901234567890"
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// RFC 6238 appendix B, SHA1. The RFC lists eight digits; six-digit
+	// codes are the last six of those.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			now := time.Unix(tt.unix, 0)
+			step, ok := ValidateTOTP(rfc6238Secret, tt.code, now)
+			if !ok {
+				t.Fatalf("ValidateTOTP(%q) at %d rejected", tt.code, tt.unix)
+			}
+			if want := tt.unix / totpPeriod; step != want {
+				t.Errorf("step = %d, want %d", step, want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// 1111111109 is step 37037036, code 081804
+	const code = "081804"
+	const step = int64(37037036)
+	at := func(s int64) time.Time { return time.Unix(s*totpPeriod, 0) }
+
+	tests := []struct {
+		name string
+		now  time.Time
+		ok   bool
+	}{
+		{name: "same step", now: at(step), ok: true},
+		{name: "one step behind", now: at(step - 1), ok: true},
+		{name: "one step ahead", now: at(step + 1), ok: true},
+		{name: "last second of the next step", now: at(step + 2).Add(-time.Second), ok: true},
+		{name: "two steps behind", now: at(step - 2), ok: false},
+		{name: "two steps ahead", now: at(step + 2), ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateTOTP(rfc6238Secret, code, tt.now)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != step {
+				t.Errorf("step = %d, want %d: the code's own step must be returned", got, step)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejects(t *testing.T) {
+	now := time.Unix(59, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+	}{
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: "287082", ok: true},
+		{name: "wrong code", secret: rfc6238Secret, code: "287083"},
+		{name: "eight digits", secret: rfc6238Secret, code: "94287082"},
+		{name: "too short", secret: rfc6238Secret, code: "28708"},
+		{name: "empty", secret: rfc6238Secret, code: ""},
+		{name: "bad secret", secret: "not base32!", code: "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, now); ok != tt.ok {
+				t.Errorf("ValidateTOTP ok = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	codes := GenerateRecoveryCodes(10)
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not formatted xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghij")
+	for _, code := range []string{"abcdefghij", "ABCDE-FGHIJ", " abcde fghij "} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the canonical form", code)
+		}
+	}
+	if HashRecoveryCode("abcde-fghik") == want {
+		t.Error("different codes hash the same")
+	}
+}
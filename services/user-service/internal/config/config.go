@@ -1,9 +1,36 @@
 package config
 
-import "github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+import (
+	"database/sql"
+	"time"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/mailer"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/rabbitmq"
+)
 
 type Config struct {
-	DB        *database.Queries
-	Platform  string
-	JWTSecret string
+	DB       *database.Queries
+	DBConn   *sql.DB
+	Platform string
+	JWTKeys  *auth.KeySet
+	Login    LoginPolicy
+	Mailer   mailer.Mailer
+	// Publisher sends user events to the other services
+	Publisher *rabbitmq.Publisher
+	// MFARequiredRoles lists roles that can't log in without two-factor
+	// authentication
+	MFARequiredRoles map[string]bool
+}
+
+// LoginPolicy limits password guessing.
+type LoginPolicy struct {
+	// MaxFailures is how many failed logins lock an account
+	MaxFailures int
+	// IPMaxFailures is how many failed logins, across any accounts, lock out
+	// a client address
+	IPMaxFailures int
+	// Lockout is how long a lock lasts, and how long failures are remembered
+	Lockout time.Duration
 }
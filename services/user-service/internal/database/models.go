@@ -6,25 +6,107 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type RefreshToken struct {
-	Token     string
+type Address struct {
+	ID                uuid.UUID
+	UserID            uuid.UUID
+	Label             string
+	RecipientName     string
+	Line1             string
+	Line2             string
+	City              string
+	Region            string
+	PostalCode        string
+	Country           string
+	Phone             string
+	IsDefaultShipping bool
+	IsDefaultBilling  bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type AuditLog struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Action    string
+	UserID    uuid.NullUUID
+	ActorID   uuid.NullUUID
+	Details   json.RawMessage
+}
+
+type LoginFailure struct {
+	Scope         string
+	Subject       string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type RefreshToken struct {
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ReplacedBy sql.NullString
+}
+
+type RevokedToken struct {
+	Jti       uuid.UUID
 	UserID    uuid.UUID
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	RevokedAt time.Time
+}
+
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	UserAgent  string
+	IpAddress  string
+	RevokedAt  sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	Role           string
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	Role            string
+	EmailVerifiedAt sql.NullTime
+	DisabledAt      sql.NullTime
+	DisplayName     string
+	Phone           string
+}
+
+type UserMfa struct {
+	UserID       uuid.UUID
+	TotpSecret   string
+	EnabledAt    sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type UserTokenRevocation struct {
+	UserID        uuid.UUID
+	RevokedBefore time.Time
+	UpdatedAt     time.Time
 }
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,25 +19,81 @@ INSERT INTO refresh_tokens (
     created_at,
     updated_at,
     user_id,
-    expires_at
+    expires_at,
+    family_id
 ) VALUES (
     $1,
     now(),
     now(),
     $2,
-    $3
+    $3,
+    $4
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by
 `
 
 type CreateRefreshTokenParams struct {
 	Token     string
 	UserID    uuid.UUID
 	ExpiresAt time.Time
+	FamilyID  uuid.UUID
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.Token,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.FamilyID,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
+	)
+	return i, err
+}
+
+const getRecentRefreshTokenSuccessor = `-- name: GetRecentRefreshTokenSuccessor :one
+SELECT next.token
+FROM refresh_tokens prev
+JOIN refresh_tokens next ON next.token = prev.replaced_by
+WHERE prev.token = $1
+  AND prev.revoked_at > now() - $2::int * interval '1 second'
+  AND next.revoked_at IS NULL
+  AND next.expires_at > now()
+FOR UPDATE OF next
+`
+
+type GetRecentRefreshTokenSuccessorParams struct {
+	Token        string
+	GraceSeconds int32
+}
+
+// The token that replaced $1, if $1 was rotated in the last grace_seconds
+// and its replacement is still the family's live token
+func (q *Queries) GetRecentRefreshTokenSuccessor(ctx context.Context, arg GetRecentRefreshTokenSuccessorParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getRecentRefreshTokenSuccessor, arg.Token, arg.GraceSeconds)
+	var token string
+	err := row.Scan(&token)
+	return token, err
+}
+
+const getRefreshTokenForUpdate = `-- name: GetRefreshTokenForUpdate :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by
+FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetRefreshTokenForUpdate(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshTokenForUpdate, token)
 	var i RefreshToken
 	err := row.Scan(
 		&i.Token,
@@ -45,6 +102,8 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
@@ -56,7 +115,11 @@ SELECT
     u.updated_at,
     u.email,
     u.hashed_password,
-    u.role
+    u.role,
+    u.email_verified_at,
+    u.disabled_at,
+    u.display_name,
+    u.phone
 FROM refresh_tokens rt
 JOIN users u ON u.id = rt.user_id
 WHERE rt.token = $1
@@ -74,20 +137,58 @@ func (q *Queries) GetUserByRefreshToken(ctx context.Context, token string) (User
 		&i.Email,
 		&i.HashedPassword,
 		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.DisplayName,
+		&i.Phone,
 	)
 	return i, err
 }
 
-const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :exec
 UPDATE refresh_tokens
 SET
     revoked_at = now(),
     updated_at = now()
+WHERE family_id = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	return err
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = now(),
+    updated_at = now()
+WHERE user_id = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = now(),
+    updated_at = now(),
+    replaced_by = $2
 WHERE token = $1
   AND revoked_at IS NULL
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
+type RotateRefreshTokenParams struct {
+	Token      string
+	ReplacedBy sql.NullString
+}
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, arg RotateRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, rotateRefreshToken, arg.Token, arg.ReplacedBy)
 	return err
 }
@@ -7,11 +7,23 @@ package database
 
 import (
 	"context"
-	"time"
 
 	"github.com/google/uuid"
 )
 
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+WHERE email ILIKE $1
+`
+
+func (q *Queries) CountUsers(ctx context.Context, pattern string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers, pattern)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     id,
@@ -30,7 +42,13 @@ RETURNING
     id,
     created_at,
     updated_at,
-    email
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
 `
 
 type CreateUserParams struct {
@@ -38,25 +56,71 @@ type CreateUserParams struct {
 	HashedPassword string
 }
 
-type CreateUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Email     string
-}
-
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
 	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
-	var i CreateUserRow
+	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.DisplayName,
+		&i.Phone,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableUser = `-- name: DisableUser :execrows
+UPDATE users
+SET
+    disabled_at = now(),
+    updated_at = now()
+WHERE id = $1
+  AND disabled_at IS NULL
+`
+
+func (q *Queries) DisableUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, disableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableUser = `-- name: EnableUser :execrows
+UPDATE users
+SET
+    disabled_at = NULL,
+    updated_at = now()
+WHERE id = $1
+  AND disabled_at IS NOT NULL
+`
+
+func (q *Queries) EnableUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
 SELECT
     id,
@@ -64,7 +128,11 @@ SELECT
     updated_at,
     email,
     hashed_password,
-    role
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
 FROM users
 WHERE email = $1
 `
@@ -79,31 +147,201 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.DisplayName,
+		&i.Phone,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email
+SELECT
+    id,
+    created_at,
+    updated_at,
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
 FROM users
 WHERE id = $1
 `
 
-type GetUserByIDRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Email     string
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.DisplayName,
+		&i.Phone,
+	)
+	return i, err
 }
 
-func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
-	row := q.db.QueryRowContext(ctx, getUserByID, id)
-	var i GetUserByIDRow
+const listUsers = `-- name: ListUsers :many
+SELECT
+    id,
+    created_at,
+    updated_at,
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
+FROM users
+WHERE email ILIKE $1
+ORDER BY created_at DESC, id
+LIMIT $2 OFFSET $3
+`
+
+type ListUsersParams struct {
+	Pattern string
+	Limit   int32
+	Offset  int32
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.Pattern, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.DisabledAt,
+			&i.DisplayName,
+			&i.Phone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :exec
+UPDATE users
+SET
+    email_verified_at = now(),
+    updated_at = now()
+WHERE id = $1
+  AND email_verified_at IS NULL
+`
+
+func (q *Queries) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markEmailVerified, id)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :execrows
+UPDATE users
+SET
+    hashed_password = $1,
+    updated_at = now()
+WHERE id = $2
+  AND hashed_password = $3
+`
+
+type UpdateUserPasswordParams struct {
+	NewHashedPassword string
+	ID                uuid.UUID
+	OldHashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUserPassword, arg.NewHashedPassword, arg.ID, arg.OldHashedPassword)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET
+    display_name = $2,
+    phone = $3,
+    updated_at = now()
+WHERE id = $1
+RETURNING
+    id,
+    created_at,
+    updated_at,
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID
+	DisplayName string
+	Phone       string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.ID, arg.DisplayName, arg.Phone)
+	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Email,
+		&i.HashedPassword,
+		&i.Role,
+		&i.EmailVerifiedAt,
+		&i.DisabledAt,
+		&i.DisplayName,
+		&i.Phone,
 	)
 	return i, err
 }
+
+const updateUserRole = `-- name: UpdateUserRole :exec
+UPDATE users
+SET
+    role = $2,
+    updated_at = now()
+WHERE id = $1
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserRole, arg.ID, arg.Role)
+	return err
+}
@@ -0,0 +1,14 @@
+package events
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserDeletedEvent is published when an account is deleted, so other
+// services can remove or anonymize what they hold about the user.
+type UserDeletedEvent struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/events"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+// deleteAccount removes the user and everything this service holds about
+// them, and publishes user.deleted so cart-service and order-service do the
+// same with theirs.
+//
+// The event goes out before the commit. If the commit then fails, the
+// other services have only done early what the user asked for, and a retry
+// finishes the job; publishing afterwards could lose the event and leave
+// their data behind for good.
+func deleteAccount(ctx context.Context, cfg *config.Config, user database.User) error {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// Revocations outlive the user, so the gateway stops accepting their
+	// access tokens. Sessions and refresh tokens go with the user row, so
+	// the revocation covers this whole second too; a deleted account has no
+	// later logins to let through.
+	err = qtx.RevokeUserTokensBefore(ctx, database.RevokeUserTokensBeforeParams{
+		UserID:        user.ID,
+		RevokedBefore: revocationCutoff().Add(time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.ClearLoginFailures(ctx, database.ClearLoginFailuresParams{
+		Scope:   loginScopeEmail,
+		Subject: loginSubject(user.Email),
+	})
+	if err != nil {
+		return err
+	}
+	rows, err := qtx.DeleteUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// Deleted concurrently, which published the event already
+		return nil
+	}
+
+	err = cfg.Publisher.Publish(ctx, "user.deleted", events.UserDeletedEvent{
+		UserID:    user.ID,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordDeletion audits a deletion. The user row is gone by now, so the ID
+// goes in the details rather than the user_id column.
+func recordDeletion(ctx context.Context, cfg *config.Config, userID, actorID uuid.UUID) error {
+	return recordAudit(ctx, cfg, auditUserDeleted, uuid.Nil, actorID, map[string]any{
+		"user_id": userID,
+	})
+}
+
+// handlerAccountDelete deletes the caller's account. It takes the password,
+// and a two-factor code when that is on, so a stolen access token alone
+// can't do it. Wrong answers count as failed logins.
+func handlerAccountDelete(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	ip := clientIP(r)
+	wait, err := loginWait(r.Context(), cfg, user.Email, ip)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
+		return
+	}
+	if wait > 0 {
+		respondLoginThrottled(w, wait)
+		return
+	}
+
+	match, _ := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if !match {
+		if err := recordLoginFailure(r.Context(), cfg, user.Email, ip, user.ID); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", err)
+			return
+		}
+		response.RespondWithError(w, http.StatusForbidden, "Incorrect password", nil)
+		return
+	}
+
+	mfa, err := cfg.DB.GetUserMFA(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+	if err == nil && mfa.EnabledAt.Valid {
+		ok, err := verifySecondFactor(r.Context(), cfg, mfa, params.Code)
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor code", err)
+			return
+		}
+		if !ok {
+			if err := recordLoginFailure(r.Context(), cfg, user.Email, ip, user.ID); err != nil {
+				response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", err)
+				return
+			}
+			response.RespondWithError(w, http.StatusForbidden, "Invalid two-factor code", nil)
+			return
+		}
+	}
+
+	if err := deleteAccount(r.Context(), cfg, user); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	if err := recordDeletion(r.Context(), cfg, user.ID, uuid.Nil); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handlerAdminDeleteUser(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	user, actorID, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	if user.ID == actorID {
+		response.RespondWithError(w, http.StatusBadRequest, "Delete your own account with DELETE /api/me", nil)
+		return
+	}
+
+	if err := deleteAccount(r.Context(), cfg, user); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+
+	if err := recordDeletion(r.Context(), cfg, user.ID, actorID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerInternalUserExport returns what this service holds about a user
+// for the gateway's data export: the profile, address book and every
+// session, ended ones included.
+func handlerInternalUserExport(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type exportSession struct {
+		ID         uuid.UUID  `json:"id"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastUsedAt time.Time  `json:"last_used_at"`
+		UserAgent  string     `json:"user_agent"`
+		IPAddress  string     `json:"ip_address"`
+		RevokedAt  *time.Time `json:"revoked_at"`
+	}
+
+	type resp struct {
+		Profile    userResponse      `json:"profile"`
+		MFAEnabled bool              `json:"mfa_enabled"`
+		Addresses  []addressResponse `json:"addresses"`
+		Sessions   []exportSession   `json:"sessions"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	enabled, err := mfaEnabled(r.Context(), cfg, userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+	addresses, err := cfg.DB.ListAddresses(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list addresses", err)
+		return
+	}
+	sessions, err := cfg.DB.ListSessions(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list sessions", err)
+		return
+	}
+
+	out := resp{
+		Profile:    newUserResponse(user),
+		MFAEnabled: enabled,
+		Addresses:  make([]addressResponse, 0, len(addresses)),
+		Sessions:   make([]exportSession, 0, len(sessions)),
+	}
+	for _, a := range addresses {
+		out.Addresses = append(out.Addresses, newAddressResponse(a))
+	}
+	for _, s := range sessions {
+		session := exportSession{
+			ID:         s.ID,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IpAddress,
+		}
+		if s.RevokedAt.Valid {
+			session.RevokedAt = &s.RevokedAt.Time
+		}
+		out.Sessions = append(out.Sessions, session)
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
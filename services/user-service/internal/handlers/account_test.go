@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+func TestHandlerAccountDelete(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		password   string
+		deleted    int64
+		wantStatus int
+		wantRan    []string
+	}{
+		{
+			name:       "wrong password",
+			password:   "wrong",
+			wantStatus: http.StatusForbidden,
+			wantRan:    []string{"GetUserByID", "GetLoginFailures", "RecordLoginFailure", "RecordLoginFailure"},
+		},
+		{
+			// Someone else got there first and published user.deleted, so
+			// there's nothing to publish or commit
+			name:       "deleted concurrently",
+			password:   "correct horse",
+			deleted:    0,
+			wantStatus: http.StatusNoContent,
+			wantRan: []string{
+				"GetUserByID", "GetLoginFailures", "GetUserMFA",
+				"BEGIN", "RevokeUserTokensBefore", "ClearLoginFailures", "DeleteUser", "ROLLBACK",
+				"CreateAuditLog",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				switch q.name {
+				case "GetUserByID":
+					return userRow(database.User{ID: userID, Email: "a@example.com", HashedPassword: hash, Role: "user"})
+				case "RecordLoginFailure":
+					return rowsOf(int64(1))
+				case "DeleteUser":
+					return fakeResult{affected: tt.deleted}
+				}
+				return fakeResult{}
+			}}
+			cfg := newLoginConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodDelete, "/api/me", strings.NewReader(`{"password":"`+tt.password+`"}`))
+			r.Header.Set("X-User-ID", userID.String())
+			r.RemoteAddr = "203.0.113.7:5000"
+			rec := httptest.NewRecorder()
+			handlerAccountDelete(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := d.ran(); !slices.Equal(got, tt.wantRan) {
+				t.Errorf("ran %v, want %v", got, tt.wantRan)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+const maxAddresses = 20
+
+var (
+	countryCodeRe = regexp.MustCompile(`^[A-Z]{2}$`)
+	phoneRe       = regexp.MustCompile(`^\+?[0-9 ()-]+$`)
+)
+
+type addressResponse struct {
+	ID                uuid.UUID `json:"id"`
+	Label             string    `json:"label"`
+	RecipientName     string    `json:"recipient_name"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2"`
+	City              string    `json:"city"`
+	Region            string    `json:"region"`
+	PostalCode        string    `json:"postal_code"`
+	Country           string    `json:"country"`
+	Phone             string    `json:"phone"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func newAddressResponse(a database.Address) addressResponse {
+	return addressResponse{
+		ID:                a.ID,
+		Label:             a.Label,
+		RecipientName:     a.RecipientName,
+		Line1:             a.Line1,
+		Line2:             a.Line2,
+		City:              a.City,
+		Region:            a.Region,
+		PostalCode:        a.PostalCode,
+		Country:           a.Country,
+		Phone:             a.Phone,
+		IsDefaultShipping: a.IsDefaultShipping,
+		IsDefaultBilling:  a.IsDefaultBilling,
+		CreatedAt:         a.CreatedAt,
+		UpdatedAt:         a.UpdatedAt,
+	}
+}
+
+// addressInput is the body of address create and update requests.
+type addressInput struct {
+	Label             string `json:"label"`
+	RecipientName     string `json:"recipient_name"`
+	Line1             string `json:"line1"`
+	Line2             string `json:"line2"`
+	City              string `json:"city"`
+	Region            string `json:"region"`
+	PostalCode        string `json:"postal_code"`
+	Country           string `json:"country"`
+	Phone             string `json:"phone"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+// normalize trims every field and upper-cases the country code.
+func (in *addressInput) normalize() {
+	for _, f := range []*string{&in.Label, &in.RecipientName, &in.Line1, &in.Line2, &in.City, &in.Region, &in.PostalCode, &in.Phone} {
+		*f = strings.TrimSpace(*f)
+	}
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+}
+
+func (in addressInput) validate() error {
+	fields := []struct {
+		name     string
+		value    string
+		required bool
+		max      int
+	}{
+		{"label", in.Label, false, 50},
+		{"recipient_name", in.RecipientName, true, 100},
+		{"line1", in.Line1, true, 200},
+		{"line2", in.Line2, false, 200},
+		{"city", in.City, true, 100},
+		{"region", in.Region, false, 100},
+		{"postal_code", in.PostalCode, true, 20},
+	}
+	for _, f := range fields {
+		if f.required && f.value == "" {
+			return fmt.Errorf("%s is required", f.name)
+		}
+		if utf8.RuneCountInString(f.value) > f.max {
+			return fmt.Errorf("%s must be at most %d characters", f.name, f.max)
+		}
+	}
+	if !countryCodeRe.MatchString(in.Country) {
+		return errors.New("country must be a two-letter ISO 3166 code")
+	}
+	return validatePhone(in.Phone)
+}
+
+// validatePhone accepts an empty number or 7 to 15 digits (the E.164
+// maximum) formatted with an optional leading +, spaces, dashes or brackets.
+func validatePhone(phone string) error {
+	if phone == "" {
+		return nil
+	}
+	digits := 0
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	if !phoneRe.MatchString(phone) || digits < 7 || digits > 15 {
+		return errors.New("phone must be 7 to 15 digits, optionally with a leading +, spaces, dashes or brackets")
+	}
+	return nil
+}
+
+func decodeAddressInput(w http.ResponseWriter, r *http.Request) (addressInput, bool) {
+	in := addressInput{}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return in, false
+	}
+	in.normalize()
+	if err := in.validate(); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return in, false
+	}
+	return in, true
+}
+
+// clearDefaults unsets the user's current default addresses of the kinds
+// in is about to become, so the new default can take their place.
+func clearDefaults(ctx context.Context, qtx *database.Queries, userID uuid.UUID, in addressInput) error {
+	if in.IsDefaultShipping {
+		if err := qtx.ClearDefaultShippingAddress(ctx, userID); err != nil {
+			return err
+		}
+	}
+	if in.IsDefaultBilling {
+		if err := qtx.ClearDefaultBillingAddress(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addressID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("addressID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid address ID", err)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondAddresses(cfg *config.Config, w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	addresses, err := cfg.DB.ListAddresses(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list addresses", err)
+		return
+	}
+
+	out := make([]addressResponse, 0, len(addresses))
+	for _, a := range addresses {
+		out = append(out, newAddressResponse(a))
+	}
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func handlerAddressesList(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	respondAddresses(cfg, w, r, userID)
+}
+
+func handlerAddressGet(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := addressID(w, r)
+	if !ok {
+		return
+	}
+
+	address, err := cfg.DB.GetAddress(r.Context(), database.GetAddressParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "Address not found", nil)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get address", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, newAddressResponse(address))
+}
+
+// handlerAddressCreate adds an address to the caller's address book. The
+// first address becomes the default for both shipping and billing.
+func handlerAddressCreate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	in, ok := decodeAddressInput(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	count, err := qtx.CountAddresses(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't count addresses", err)
+		return
+	}
+	if count >= maxAddresses {
+		response.RespondWithError(w, http.StatusConflict, fmt.Sprintf("You can save at most %d addresses", maxAddresses), nil)
+		return
+	}
+	if count == 0 {
+		in.IsDefaultShipping = true
+		in.IsDefaultBilling = true
+	}
+
+	if err := clearDefaults(r.Context(), qtx, userID, in); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update default address", err)
+		return
+	}
+	address, err := qtx.CreateAddress(r.Context(), database.CreateAddressParams{
+		UserID:            userID,
+		Label:             in.Label,
+		RecipientName:     in.RecipientName,
+		Line1:             in.Line1,
+		Line2:             in.Line2,
+		City:              in.City,
+		Region:            in.Region,
+		PostalCode:        in.PostalCode,
+		Country:           in.Country,
+		Phone:             in.Phone,
+		IsDefaultShipping: in.IsDefaultShipping,
+		IsDefaultBilling:  in.IsDefaultBilling,
+	})
+	if isUniqueViolation(err) {
+		response.RespondWithError(w, http.StatusConflict, "Default address changed concurrently, try again", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't create address", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't create address", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusCreated, newAddressResponse(address))
+}
+
+// handlerAddressUpdate replaces an address. Clearing a default flag leaves
+// the user without a default of that kind.
+func handlerAddressUpdate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := addressID(w, r)
+	if !ok {
+		return
+	}
+	in, ok := decodeAddressInput(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := clearDefaults(r.Context(), qtx, userID, in); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update default address", err)
+		return
+	}
+	address, err := qtx.UpdateAddress(r.Context(), database.UpdateAddressParams{
+		ID:                id,
+		UserID:            userID,
+		Label:             in.Label,
+		RecipientName:     in.RecipientName,
+		Line1:             in.Line1,
+		Line2:             in.Line2,
+		City:              in.City,
+		Region:            in.Region,
+		PostalCode:        in.PostalCode,
+		Country:           in.Country,
+		Phone:             in.Phone,
+		IsDefaultShipping: in.IsDefaultShipping,
+		IsDefaultBilling:  in.IsDefaultBilling,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "Address not found", nil)
+		return
+	}
+	if isUniqueViolation(err) {
+		response.RespondWithError(w, http.StatusConflict, "Default address changed concurrently, try again", err)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update address", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update address", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, newAddressResponse(address))
+}
+
+func handlerAddressDelete(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := addressID(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.DB.DeleteAddress(r.Context(), database.DeleteAddressParams{
+		ID:     id,
+		UserID: userID,
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't delete address", err)
+		return
+	}
+	if rows == 0 {
+		response.RespondWithError(w, http.StatusNotFound, "Address not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerInternalAddressesList lets order-service pick the addresses to
+// snapshot onto an order at checkout.
+func handlerInternalAddressesList(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	respondAddresses(cfg, w, r, userID)
+}
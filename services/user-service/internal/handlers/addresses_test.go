@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+func TestValidatePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{"", true},
+		{"+44 20 7946 0958", true},
+		{"(555) 123-4567", true},
+		{"5551234", true},
+		{"123456", false},
+		{"+1234567890123456", false},
+		{"555-CALL-NOW", false},
+		{"++44 20 7946 0958", false},
+		{"555 123 4567 ext 2", false},
+	}
+
+	for _, tt := range tests {
+		err := validatePhone(tt.phone)
+		if (err == nil) != tt.valid {
+			t.Errorf("validatePhone(%q) = %v, want valid %v", tt.phone, err, tt.valid)
+		}
+	}
+}
+
+func TestAddressInputValidate(t *testing.T) {
+	valid := func() addressInput {
+		return addressInput{
+			Label:         " Home ",
+			RecipientName: " Ada Lovelace ",
+			Line1:         "12 St James's Square",
+			City:          "London",
+			PostalCode:    "SW1Y 4LB",
+			Country:       " gb ",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(in *addressInput)
+		wantErr string
+	}{
+		{name: "valid", modify: func(in *addressInput) {}},
+		{name: "missing recipient", modify: func(in *addressInput) { in.RecipientName = "   " }, wantErr: "recipient_name is required"},
+		{name: "missing line1", modify: func(in *addressInput) { in.Line1 = "" }, wantErr: "line1 is required"},
+		{name: "missing city", modify: func(in *addressInput) { in.City = "" }, wantErr: "city is required"},
+		{name: "missing postal code", modify: func(in *addressInput) { in.PostalCode = "" }, wantErr: "postal_code is required"},
+		{name: "long label", modify: func(in *addressInput) { in.Label = strings.Repeat("a", 51) }, wantErr: "label must be at most 50 characters"},
+		{name: "multibyte label at limit", modify: func(in *addressInput) { in.Label = strings.Repeat("é", 50) }},
+		{name: "country name", modify: func(in *addressInput) { in.Country = "United Kingdom" }, wantErr: "country must be"},
+		{name: "three-letter country", modify: func(in *addressInput) { in.Country = "GBR" }, wantErr: "country must be"},
+		{name: "bad phone", modify: func(in *addressInput) { in.Phone = "call me" }, wantErr: "phone must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := valid()
+			tt.modify(&in)
+			in.normalize()
+			err := in.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate: %v", err)
+				}
+				if in.Country != "GB" || in.RecipientName != "Ada Lovelace" {
+					t.Errorf("normalized to %+v", in)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("validate = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// addressRow echoes a CreateAddress call back as the inserted row.
+func addressRow(id uuid.UUID, args []driver.Value) fakeResult {
+	now := time.Now()
+	row := append([]driver.Value{id.String()}, args...)
+	return rowsOf(append(row, now, now)...)
+}
+
+const validAddressBody = `{"recipient_name":"Ada Lovelace","line1":"12 St James's Square","city":"London","postal_code":"SW1Y 4LB","country":"gb","is_default_shipping":true}`
+
+func TestHandlerAddressCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		existing     int64
+		createErr    error
+		wantStatus   int
+		wantCleared  []string
+		wantShipping bool
+		wantBilling  bool
+	}{
+		{
+			name:         "first address becomes both defaults",
+			body:         `{"recipient_name":"Ada Lovelace","line1":"12 St James's Square","city":"London","postal_code":"SW1Y 4LB","country":"gb"}`,
+			wantStatus:   http.StatusCreated,
+			wantCleared:  []string{"ClearDefaultShippingAddress", "ClearDefaultBillingAddress"},
+			wantShipping: true,
+			wantBilling:  true,
+		},
+		{
+			name:         "new default shipping address",
+			body:         validAddressBody,
+			existing:     3,
+			wantStatus:   http.StatusCreated,
+			wantCleared:  []string{"ClearDefaultShippingAddress"},
+			wantShipping: true,
+		},
+		{
+			name:       "address book full",
+			body:       validAddressBody,
+			existing:   maxAddresses,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "concurrent default change",
+			body:       validAddressBody,
+			existing:   3,
+			createErr:  &pq.Error{Code: "23505"},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "invalid",
+			body:       `{"recipient_name":"Ada Lovelace"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				switch q.name {
+				case "CountAddresses":
+					return rowsOf(tt.existing)
+				case "CreateAddress":
+					if tt.createErr != nil {
+						return fakeResult{err: tt.createErr}
+					}
+					return addressRow(uuid.New(), q.args)
+				}
+				return fakeResult{}
+			}}
+			cfg := newFakeConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/addresses", strings.NewReader(tt.body))
+			r.Header.Set("X-User-ID", userID.String())
+			rec := httptest.NewRecorder()
+			handlerAddressCreate(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var cleared []string
+			for _, name := range d.ran() {
+				if strings.HasPrefix(name, "ClearDefault") {
+					cleared = append(cleared, name)
+				}
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if slices.Contains(d.ran(), "COMMIT") {
+					t.Error("failed create was committed")
+				}
+				return
+			}
+			if !slices.Equal(cleared, tt.wantCleared) {
+				t.Errorf("cleared %v, want %v", cleared, tt.wantCleared)
+			}
+
+			var got addressResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Country != "GB" {
+				t.Errorf("country = %q, want GB", got.Country)
+			}
+			if got.IsDefaultShipping != tt.wantShipping || got.IsDefaultBilling != tt.wantBilling {
+				t.Errorf("defaults = (%v, %v), want (%v, %v)", got.IsDefaultShipping, got.IsDefaultBilling, tt.wantShipping, tt.wantBilling)
+			}
+		})
+	}
+}
+
+func TestHandlerAddressUpdateNotFound(t *testing.T) {
+	userID, addressID := uuid.New(), uuid.New()
+	d := &fakeDriver{}
+	cfg := newFakeConfig(t, d)
+
+	r := httptest.NewRequest(http.MethodPut, "/api/addresses/"+addressID.String(), strings.NewReader(validAddressBody))
+	r.SetPathValue("addressID", addressID.String())
+	r.Header.Set("X-User-ID", userID.String())
+	rec := httptest.NewRecorder()
+	handlerAddressUpdate(cfg, rec, r)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	// Clearing the old default must not stick when the update fails
+	if slices.Contains(d.ran(), "COMMIT") {
+		t.Error("failed update was committed")
+	}
+	q, _ := d.find("UpdateAddress")
+	if q.args[0] != addressID.String() || q.args[1] != userID.String() {
+		t.Errorf("UpdateAddress scoped to %v, want address %s of user %s", q.args[:2], addressID, userID)
+	}
+}
+
+func TestHandlerAddressDelete(t *testing.T) {
+	for _, tt := range []struct {
+		deleted    int64
+		wantStatus int
+	}{
+		{1, http.StatusNoContent},
+		{0, http.StatusNotFound},
+	} {
+		d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+			return fakeResult{affected: tt.deleted}
+		}}
+		cfg := newFakeConfig(t, d)
+		addressID := uuid.NewString()
+
+		r := httptest.NewRequest(http.MethodDelete, "/api/addresses/"+addressID, nil)
+		r.SetPathValue("addressID", addressID)
+		r.Header.Set("X-User-ID", uuid.NewString())
+		rec := httptest.NewRecorder()
+		handlerAddressDelete(cfg, rec, r)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("deleted %d: status = %d, want %d", tt.deleted, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestHandlerProfileUpdate(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantName   string
+		wantPhone  string
+	}{
+		{name: "name only", body: `{"display_name":"  Ada  "}`, wantStatus: http.StatusOK, wantName: "Ada", wantPhone: "+44 20 7946 0958"},
+		{name: "clear phone", body: `{"phone":""}`, wantStatus: http.StatusOK, wantName: "Ada L", wantPhone: ""},
+		{name: "long name", body: `{"display_name":"` + strings.Repeat("a", 101) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "bad phone", body: `{"phone":"12"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := database.User{ID: userID, Email: "a@example.com", Role: "user", DisplayName: "Ada L", Phone: "+44 20 7946 0958"}
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				switch q.name {
+				case "GetUserByID":
+					return userRow(user)
+				case "UpdateUserProfile":
+					updated := user
+					updated.DisplayName = q.args[1].(string)
+					updated.Phone = q.args[2].(string)
+					return userRow(updated)
+				}
+				return fakeResult{}
+			}}
+			cfg := newFakeConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodPatch, "/api/me", strings.NewReader(tt.body))
+			r.Header.Set("X-User-ID", userID.String())
+			rec := httptest.NewRecorder()
+			handlerProfileUpdate(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if _, ok := d.find("UpdateUserProfile"); ok {
+					t.Error("invalid profile was saved")
+				}
+				return
+			}
+			var got userResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.DisplayName != tt.wantName || got.Phone != tt.wantPhone {
+				t.Errorf("profile = (%q, %q), want (%q, %q)", got.DisplayName, got.Phone, tt.wantName, tt.wantPhone)
+			}
+		})
+	}
+}
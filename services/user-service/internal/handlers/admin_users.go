@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// validRoles are the values users.role may take
+var validRoles = map[string]bool{
+	"user":  true,
+	"admin": true,
+}
+
+// adminUser adds account state only admins see to userResponse.
+type adminUser struct {
+	userResponse
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+}
+
+func newAdminUser(u database.User) adminUser {
+	au := adminUser{
+		userResponse: newUserResponse(u),
+		Disabled:     u.DisabledAt.Valid,
+	}
+	if u.DisabledAt.Valid {
+		au.DisabledAt = &u.DisabledAt.Time
+	}
+	return au
+}
+
+// emailPattern turns a search term into an ILIKE pattern matching emails
+// that contain it.
+func emailPattern(search string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search)
+	return "%" + escaped + "%"
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return n, nil
+}
+
+// adminTarget checks the caller is an admin and loads the user named in the
+// path. It writes the error response and returns false if either fails.
+func adminTarget(cfg *config.Config, w http.ResponseWriter, r *http.Request) (database.User, uuid.UUID, bool) {
+	if r.Header.Get("X-User-Role") != "admin" {
+		response.RespondWithError(w, http.StatusForbidden, "admin access required", nil)
+		return database.User{}, uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return database.User{}, uuid.Nil, false
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusNotFound, "User not found", err)
+		return database.User{}, uuid.Nil, false
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return database.User{}, uuid.Nil, false
+	}
+
+	actorID, _ := uuid.Parse(r.Header.Get("X-User-ID"))
+	return user, actorID, true
+}
+
+func handlerAdminListUsers(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		Users  []adminUser `json:"users"`
+		Total  int64       `json:"total"`
+		Limit  int         `json:"limit"`
+		Offset int         `json:"offset"`
+	}
+
+	if r.Header.Get("X-User-Role") != "admin" {
+		response.RespondWithError(w, http.StatusForbidden, "admin access required", nil)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultUserPageSize)
+	if err != nil || limit == 0 || limit > maxUserPageSize {
+		response.RespondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100", err)
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid offset", err)
+		return
+	}
+
+	pattern := emailPattern(strings.TrimSpace(r.URL.Query().Get("search")))
+	users, err := cfg.DB.ListUsers(r.Context(), database.ListUsersParams{
+		Pattern: pattern,
+		Limit:   int32(limit),
+		Offset:  int32(offset),
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list users", err)
+		return
+	}
+	total, err := cfg.DB.CountUsers(r.Context(), pattern)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't count users", err)
+		return
+	}
+
+	out := resp{
+		Users:  make([]adminUser, 0, len(users)),
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+	for _, u := range users {
+		out.Users = append(out.Users, newAdminUser(u))
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func handlerAdminGetUser(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		adminUser
+		MFAEnabled bool `json:"mfa_enabled"`
+	}
+
+	user, _, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	enabled, err := mfaEnabled(r.Context(), cfg, user.ID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, resp{
+		adminUser:  newAdminUser(user),
+		MFAEnabled: enabled,
+	})
+}
+
+// handlerAdminSetUserRole changes a user's role. Their existing access tokens
+// carry the old role, so they are all revoked.
+func handlerAdminSetUserRole(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	user, actorID, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !validRoles[params.Role] {
+		response.RespondWithError(w, http.StatusBadRequest, "role must be user or admin", nil)
+		return
+	}
+	// Stops the last admin from locking everyone out of the admin API
+	if user.ID == actorID {
+		response.RespondWithError(w, http.StatusBadRequest, "You can't change your own role", nil)
+		return
+	}
+	if user.Role == params.Role {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err := cfg.DB.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: params.Role,
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update role", err)
+		return
+	}
+	if err := revokeAllUserTokens(r.Context(), cfg, user.ID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke tokens", err)
+		return
+	}
+
+	details := map[string]any{"from": user.Role, "to": params.Role}
+	if err := recordAudit(r.Context(), cfg, auditUserRoleChanged, user.ID, actorID, details); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerAdminDisableUser blocks a user from logging in or refreshing and
+// logs them out everywhere.
+func handlerAdminDisableUser(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	user, actorID, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	if user.ID == actorID {
+		response.RespondWithError(w, http.StatusBadRequest, "You can't disable your own account", nil)
+		return
+	}
+
+	rows, err := cfg.DB.DisableUser(r.Context(), user.ID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't disable user", err)
+		return
+	}
+	if err := revokeAllUserTokens(r.Context(), cfg, user.ID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke tokens", err)
+		return
+	}
+
+	if rows > 0 {
+		if err := recordAudit(r.Context(), cfg, auditUserDisabled, user.ID, actorID, nil); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handlerAdminEnableUser(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	user, actorID, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.DB.EnableUser(r.Context(), user.ID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't enable user", err)
+		return
+	}
+
+	if rows > 0 {
+		if err := recordAudit(r.Context(), cfg, auditUserEnabled, user.ID, actorID, nil); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+func TestEmailPattern(t *testing.T) {
+	tests := []struct {
+		search string
+		want   string
+	}{
+		{"", "%%"},
+		{"alice", "%alice%"},
+		{"100%", `%100\%%`},
+		{"a_b", `%a\_b%`},
+		{`back\slash`, `%back\\slash%`},
+	}
+
+	for _, tt := range tests {
+		if got := emailPattern(tt.search); got != tt.want {
+			t.Errorf("emailPattern(%q) = %q, want %q", tt.search, got, tt.want)
+		}
+	}
+}
+
+// adminDB fakes the queries the admin user handlers make for a single
+// target user.
+type adminDB struct {
+	user     uuid.UUID
+	role     string
+	disabled bool
+	// changed is the rows affected by DisableUser/EnableUser
+	changed int64
+}
+
+func (a adminDB) driver() *fakeDriver {
+	return &fakeDriver{respond: func(q fakeQuery) fakeResult {
+		switch q.name {
+		case "GetUserByID":
+			if q.args[0] != a.user.String() {
+				return fakeResult{}
+			}
+			return userRow(database.User{
+				ID:         a.user,
+				Email:      "target@example.com",
+				Role:       a.role,
+				DisabledAt: sql.NullTime{Time: time.Now(), Valid: a.disabled},
+			})
+		case "DisableUser", "EnableUser":
+			return fakeResult{affected: a.changed}
+		}
+		return fakeResult{}
+	}}
+}
+
+func adminRequest(method, target string, userID string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.SetPathValue("userID", userID)
+	r.Header.Set("X-User-Role", "admin")
+	r.Header.Set("X-User-ID", uuid.NewString())
+	return r
+}
+
+func TestAdminTarget(t *testing.T) {
+	target := uuid.New()
+	tests := []struct {
+		name       string
+		role       string
+		userID     string
+		wantStatus int
+	}{
+		{name: "not an admin", role: "user", userID: target.String(), wantStatus: http.StatusForbidden},
+		{name: "invalid ID", role: "admin", userID: "42", wantStatus: http.StatusBadRequest},
+		{name: "unknown user", role: "admin", userID: uuid.NewString(), wantStatus: http.StatusNotFound},
+		{name: "found", role: "admin", userID: target.String(), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newFakeConfig(t, adminDB{user: target, role: "user"}.driver())
+			r := adminRequest(http.MethodGet, "/admin/users/"+tt.userID, tt.userID, "")
+			r.Header.Set("X-User-Role", tt.role)
+			rec := httptest.NewRecorder()
+			handlerAdminGetUser(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerAdminListUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantArgs   []driver.Value
+	}{
+		{name: "defaults", query: "", wantStatus: http.StatusOK, wantArgs: []driver.Value{"%%", int64(20), int64(0)}},
+		{name: "search and page", query: "?search=+ali_ce+&limit=5&offset=10", wantStatus: http.StatusOK, wantArgs: []driver.Value{`%ali\_ce%`, int64(5), int64(10)}},
+		{name: "zero limit", query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{name: "limit too big", query: "?limit=101", wantStatus: http.StatusBadRequest},
+		{name: "negative offset", query: "?offset=-1", wantStatus: http.StatusBadRequest},
+		{name: "non-numeric limit", query: "?limit=ten", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now().UTC().Truncate(time.Second)
+			userID := uuid.New()
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				switch q.name {
+				case "ListUsers":
+					return userRow(database.User{
+						ID:         userID,
+						CreatedAt:  now,
+						UpdatedAt:  now,
+						Email:      "alice@example.com",
+						Role:       "user",
+						DisabledAt: sql.NullTime{Time: now, Valid: true},
+					})
+				case "CountUsers":
+					return rowsOf(int64(41))
+				}
+				return fakeResult{}
+			}}
+			cfg := newFakeConfig(t, d)
+			r := httptest.NewRequest(http.MethodGet, "/admin/users"+tt.query, nil)
+			r.Header.Set("X-User-Role", "admin")
+			rec := httptest.NewRecorder()
+			handlerAdminListUsers(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			q, _ := d.find("ListUsers")
+			if !slices.Equal(q.args, tt.wantArgs) {
+				t.Errorf("ListUsers args = %v, want %v", q.args, tt.wantArgs)
+			}
+
+			var body struct {
+				Users []adminUser `json:"users"`
+				Total int64       `json:"total"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Total != 41 || len(body.Users) != 1 {
+				t.Fatalf("got total %d and %d users, want 41 and 1", body.Total, len(body.Users))
+			}
+			u := body.Users[0]
+			if u.ID != userID || u.EmailVerified || !u.Disabled || u.DisabledAt == nil {
+				t.Errorf("user = %+v, want unverified and disabled", u)
+			}
+		})
+	}
+}
+
+func TestHandlerAdminSetUserRole(t *testing.T) {
+	target := uuid.New()
+	tests := []struct {
+		name       string
+		body       string
+		self       bool
+		wantStatus int
+		wantRan    []string
+	}{
+		{
+			name:       "promote",
+			body:       `{"role":"admin"}`,
+			wantStatus: http.StatusNoContent,
+			wantRan: []string{
+				"GetUserByID", "UpdateUserRole",
+				"RevokeUserTokensBefore", "RevokeUserSessions", "RevokeUserRefreshTokens",
+				"CreateAuditLog",
+			},
+		},
+		{
+			name:       "unchanged",
+			body:       `{"role":"user"}`,
+			wantStatus: http.StatusNoContent,
+			wantRan:    []string{"GetUserByID"},
+		},
+		{
+			name:       "unknown role",
+			body:       `{"role":"superuser"}`,
+			wantStatus: http.StatusBadRequest,
+			wantRan:    []string{"GetUserByID"},
+		},
+		{
+			name:       "own role",
+			body:       `{"role":"admin"}`,
+			self:       true,
+			wantStatus: http.StatusBadRequest,
+			wantRan:    []string{"GetUserByID"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := adminDB{user: target, role: "user"}.driver()
+			cfg := newFakeConfig(t, d)
+			r := adminRequest(http.MethodPut, "/admin/users/"+target.String()+"/role", target.String(), tt.body)
+			if tt.self {
+				r.Header.Set("X-User-ID", target.String())
+			}
+			rec := httptest.NewRecorder()
+			handlerAdminSetUserRole(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := d.ran(); !slices.Equal(got, tt.wantRan) {
+				t.Errorf("ran %v, want %v", got, tt.wantRan)
+			}
+		})
+	}
+}
+
+func TestHandlerAdminDisableUser(t *testing.T) {
+	target := uuid.New()
+	revoke := []string{"RevokeUserTokensBefore", "RevokeUserSessions", "RevokeUserRefreshTokens"}
+	tests := []struct {
+		name       string
+		changed    int64
+		self       bool
+		wantStatus int
+		wantRan    []string
+	}{
+		{
+			name:       "active user",
+			changed:    1,
+			wantStatus: http.StatusNoContent,
+			wantRan:    slices.Concat([]string{"GetUserByID", "DisableUser"}, revoke, []string{"CreateAuditLog"}),
+		},
+		{
+			// Tokens are still revoked in case an earlier attempt failed
+			// part way, but there's nothing new to audit
+			name:       "already disabled",
+			changed:    0,
+			wantStatus: http.StatusNoContent,
+			wantRan:    slices.Concat([]string{"GetUserByID", "DisableUser"}, revoke),
+		},
+		{
+			name:       "self",
+			self:       true,
+			wantStatus: http.StatusBadRequest,
+			wantRan:    []string{"GetUserByID"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := adminDB{user: target, role: "user", changed: tt.changed}.driver()
+			cfg := newFakeConfig(t, d)
+			r := adminRequest(http.MethodPost, "/admin/users/"+target.String()+"/disable", target.String(), "")
+			if tt.self {
+				r.Header.Set("X-User-ID", target.String())
+			}
+			rec := httptest.NewRecorder()
+			handlerAdminDisableUser(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := d.ran(); !slices.Equal(got, tt.wantRan) {
+				t.Errorf("ran %v, want %v", got, tt.wantRan)
+			}
+		})
+	}
+}
+
+func TestHandlerAdminEnableUser(t *testing.T) {
+	target := uuid.New()
+	for _, changed := range []int64{0, 1} {
+		d := adminDB{user: target, role: "user", disabled: true, changed: changed}.driver()
+		cfg := newFakeConfig(t, d)
+		r := adminRequest(http.MethodPost, "/admin/users/"+target.String()+"/enable", target.String(), "")
+		rec := httptest.NewRecorder()
+		handlerAdminEnableUser(cfg, rec, r)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204", rec.Code)
+		}
+		_, audited := d.find("CreateAuditLog")
+		if audited != (changed == 1) {
+			t.Errorf("changed=%d: audited = %v", changed, audited)
+		}
+	}
+}
+
+func TestHandlerLoginDisabledUser(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := uuid.New()
+	d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+		if q.name == "GetUserByEmail" {
+			return userRow(database.User{
+				ID:             userID,
+				Email:          "a@example.com",
+				HashedPassword: hash,
+				Role:           "user",
+				DisabledAt:     sql.NullTime{Time: time.Now(), Valid: true},
+			})
+		}
+		return fakeResult{}
+	}}
+	rec := postLogin(newLoginConfig(t, d), "a@example.com", "correct horse")
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	if _, ok := d.find("CreateSession"); ok {
+		t.Error("disabled user got a session")
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+// Audit log actions
+const (
+	auditUserLocked        = "user.locked"
+	auditUserUnlocked      = "user.unlocked"
+	auditPasswordReset     = "user.password_reset"
+	auditUserRoleChanged   = "user.role_changed"
+	auditUserDisabled      = "user.disabled"
+	auditUserEnabled       = "user.enabled"
+	auditUserTokensRevoked = "user.tokens_revoked"
+	auditUserDeleted       = "user.deleted"
+)
+
+// recordAudit writes an audit log entry about userID. actorID is the admin
+// who acted, or uuid.Nil when the system did.
+func recordAudit(ctx context.Context, cfg *config.Config, action string, userID, actorID uuid.UUID, details map[string]any) error {
+	if details == nil {
+		details = map[string]any{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	return cfg.DB.CreateAuditLog(ctx, database.CreateAuditLogParams{
+		Action:  action,
+		UserID:  uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil},
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		Details: data,
+	})
+}
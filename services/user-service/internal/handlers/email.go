@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/mailer"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+)
+
+// sendVerificationEmail mails the user a token proving they own email.
+func sendVerificationEmail(ctx context.Context, cfg *config.Config, userID uuid.UUID, email string) error {
+	msg, err := verificationEmail(cfg, userID, email)
+	if err != nil {
+		return err
+	}
+	return cfg.Mailer.Send(ctx, msg)
+}
+
+// verificationEmail carries a token proving the user owns email. The token
+// stops working if the address changes.
+func verificationEmail(cfg *config.Config, userID uuid.UUID, email string) (mailer.Message, error) {
+	token, err := auth.MakePurposeToken(userID, auth.PurposeVerifyEmail, auth.Fingerprint(email), cfg.JWTKeys, verifyEmailTokenTTL)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Welcome! Confirm this is your email address by submitting the token below to POST /api/verify-email.\n\n%s\n\nIt expires in %s.",
+			token, verifyEmailTokenTTL,
+		),
+	}, nil
+}
+
+// passwordResetEmail carries a single-use reset token. It is bound to the
+// current password hash, so it stops working once the password changes.
+func passwordResetEmail(cfg *config.Config, user database.User) (mailer.Message, error) {
+	token, err := auth.MakePurposeToken(user.ID, auth.PurposeResetPassword, auth.Fingerprint(user.HashedPassword), cfg.JWTKeys, resetPasswordTokenTTL)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for this account. If it was you, submit the token below with your new password to POST /api/password-reset/confirm.\n\n%s\n\nIt expires in %s. If it wasn't you, ignore this email.",
+			token, resetPasswordTokenTTL,
+		),
+	}, nil
+}
+
+// mailAccount looks up the account for email and mails it what build
+// returns, after the request has been answered. Whether there is an account
+// or mail to send never changes what the request does or how long it takes.
+func mailAccount(r *http.Request, cfg *config.Config, email string, build func(user database.User) (mailer.Message, bool, error)) {
+	err := mailer.SendDeferred(r.Context(), cfg.Mailer, func(ctx context.Context) (mailer.Message, bool, error) {
+		user, err := cfg.DB.GetUserByEmail(ctx, email)
+		if errors.Is(err, sql.ErrNoRows) {
+			return mailer.Message{}, false, nil
+		}
+		if err != nil {
+			return mailer.Message{}, false, fmt.Errorf("getting user: %w", err)
+		}
+		return build(user)
+	})
+	if err != nil {
+		log.Printf("failed to send mail: %v", err)
+	}
+}
+
+func handlerVerifyEmail(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	userID, claims, err := auth.ParsePurposeToken(params.Token, auth.PurposeVerifyEmail, cfg.JWTKeys)
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil || !claims.Bound(user.Email) {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	if err := cfg.DB.MarkEmailVerified(r.Context(), user.ID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerResendVerification always answers 202 so it can't be used to find
+// out which emails have accounts.
+func handlerResendVerification(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	mailAccount(r, cfg, params.Email, func(user database.User) (mailer.Message, bool, error) {
+		if user.EmailVerifiedAt.Valid {
+			return mailer.Message{}, false, nil
+		}
+		msg, err := verificationEmail(cfg, user.ID, user.Email)
+		return msg, err == nil, err
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handlerPasswordReset always answers 202 so it can't be used to find out
+// which emails have accounts.
+func handlerPasswordReset(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	mailAccount(r, cfg, params.Email, func(user database.User) (mailer.Message, bool, error) {
+		msg, err := passwordResetEmail(cfg, user)
+		return msg, err == nil, err
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handlerPasswordResetConfirm sets a new password and signs the user out
+// everywhere, in case the reset was needed because someone else got in.
+func handlerPasswordResetConfirm(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Password == "" {
+		response.RespondWithError(w, http.StatusBadRequest, "Password is required", nil)
+		return
+	}
+
+	userID, claims, err := auth.ParsePurposeToken(params.Token, auth.PurposeResetPassword, cfg.JWTKeys)
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil || !claims.Bound(user.HashedPassword) {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
+		return
+	}
+
+	// Conditional on the old hash so a token can't be used twice concurrently
+	rows, err := cfg.DB.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		NewHashedPassword: hashedPassword,
+		ID:                user.ID,
+		OldHashedPassword: user.HashedPassword,
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update password", err)
+		return
+	}
+	if rows == 0 {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid or expired token", nil)
+		return
+	}
+
+	if err := revokeAllUserTokens(r.Context(), cfg, user.ID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke tokens", err)
+		return
+	}
+	// Receiving the token proves the address, and a reset is a fresh start
+	// for lockouts
+	if err := cfg.DB.MarkEmailVerified(r.Context(), user.ID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+	if err := clearLoginFailures(r.Context(), cfg, user.Email); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't clear login attempts", err)
+		return
+	}
+	if err := recordAudit(r.Context(), cfg, auditPasswordReset, user.ID, uuid.Nil, nil); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerUserStatus tells other services what a user may do.
+func handlerUserStatus(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type statusResponse struct {
+		UserID        uuid.UUID `json:"user_id"`
+		EmailVerified bool      `json:"email_verified"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, statusResponse{
+		UserID:        user.ID,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/mailer"
+)
+
+var mailedToken = regexp.MustCompile(`\n\n(\S+)\n\n`)
+
+// TestHandlerMailAccount covers the two handlers that mail an account found
+// by email. Neither may reveal whether the account exists, so the lookup
+// and the mail happen after the 202.
+func TestHandlerMailAccount(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unverified := database.User{ID: uuid.New(), Email: "a@example.com", HashedPassword: hash, Role: "customer"}
+	verified := unverified
+	verified.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name        string
+		handler     func(cfg *config.Config, w http.ResponseWriter, r *http.Request)
+		user        *database.User
+		lookupErr   error
+		wantPurpose string
+		wantBinding string
+	}{
+		{
+			name:        "reset",
+			handler:     handlerPasswordReset,
+			user:        &verified,
+			wantPurpose: auth.PurposeResetPassword,
+			wantBinding: hash,
+		},
+		{name: "reset, no account", handler: handlerPasswordReset},
+		{name: "reset, database down", handler: handlerPasswordReset, lookupErr: errors.New("connection refused")},
+		{
+			name:        "resend",
+			handler:     handlerResendVerification,
+			user:        &unverified,
+			wantPurpose: auth.PurposeVerifyEmail,
+			wantBinding: unverified.Email,
+		},
+		{name: "resend, already verified", handler: handlerResendVerification, user: &verified},
+		{name: "resend, no account", handler: handlerResendVerification},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The lookup waits for the handler to answer, and finds nothing
+			// if it runs inside the request
+			answered := make(chan struct{})
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				if q.name != "GetUserByEmail" {
+					return fakeResult{}
+				}
+				select {
+				case <-answered:
+				case <-time.After(time.Second):
+					t.Error("looked the account up before answering")
+					return fakeResult{}
+				}
+				if tt.lookupErr != nil {
+					return fakeResult{err: tt.lookupErr}
+				}
+				if tt.user == nil || q.args[0] != tt.user.Email {
+					return fakeResult{}
+				}
+				return userRow(*tt.user)
+			}}
+			sent := &recordingMailer{}
+			mail := mailer.NewAsync(sent, time.Second)
+			cfg := newLoginConfig(t, d)
+			cfg.Mailer = mail
+
+			r := httptest.NewRequest(http.MethodPost, "/api/password-reset", strings.NewReader(`{"email":"a@example.com"}`))
+			rec := httptest.NewRecorder()
+			tt.handler(cfg, rec, r)
+			close(answered)
+
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := mail.Wait(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			messages := sent.messages()
+			if tt.wantPurpose == "" {
+				if len(messages) != 0 {
+					t.Errorf("sent %v, want no mail", messages)
+				}
+				return
+			}
+			if len(messages) != 1 || messages[0].To != tt.user.Email {
+				t.Fatalf("sent %v, want one mail to %s", messages, tt.user.Email)
+			}
+			match := mailedToken.FindStringSubmatch(messages[0].Body)
+			if match == nil {
+				t.Fatalf("no token in mail:\n%s", messages[0].Body)
+			}
+			userID, claims, err := auth.ParsePurposeToken(match[1], tt.wantPurpose, cfg.JWTKeys)
+			if err != nil {
+				t.Fatalf("mailed token: %v", err)
+			}
+			if userID != tt.user.ID || !claims.Bound(tt.wantBinding) {
+				t.Errorf("mailed token is for %s bound to %q, want %s bound to %q", userID, claims.Binding, tt.user.ID, tt.wantBinding)
+			}
+		})
+	}
+}
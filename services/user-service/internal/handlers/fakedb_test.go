@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+// fakeDriver answers statements by sqlc query name and records everything it
+// runs, including transaction boundaries as "BEGIN", "COMMIT" and
+// "ROLLBACK", so handlers can be tested without Postgres.
+type fakeDriver struct {
+	mu      sync.Mutex
+	respond func(q fakeQuery) fakeResult
+	queries []fakeQuery
+}
+
+type fakeQuery struct {
+	name string
+	args []driver.Value
+}
+
+// fakeResult is what a statement returns: rows for queries, rows affected
+// for execs, or err.
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// rowsOf returns a single row with one placeholder column per value.
+func rowsOf(values ...driver.Value) fakeResult {
+	columns := make([]string, len(values))
+	for i := range columns {
+		columns[i] = "c"
+	}
+	return fakeResult{columns: columns, rows: [][]driver.Value{values}}
+}
+
+// queryName extracts the sqlc query name from its "-- name: X :kind" header.
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return query
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+func (d *fakeDriver) run(query string, args []driver.NamedValue) fakeResult {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	q := fakeQuery{name: queryName(query), args: values}
+	d.queries = append(d.queries, q)
+	if d.respond == nil {
+		return fakeResult{}
+	}
+	return d.respond(q)
+}
+
+// ran returns the names of the statements run so far, in order.
+func (d *fakeDriver) ran() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, len(d.queries))
+	for i, q := range d.queries {
+		names[i] = q.name
+	}
+	return names
+}
+
+// find returns the first recorded statement called name.
+func (d *fakeDriver) find(name string) (fakeQuery, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, q := range d.queries {
+		if q.name == name {
+			return q, true
+		}
+	}
+	return fakeQuery{}, false
+}
+
+func (d *fakeDriver) record(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, fakeQuery{name: name})
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return fakeTx{d: c.d}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res := c.d.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{columns: res.columns, rows: res.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res := c.d.run(query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (tx fakeTx) Commit() error {
+	tx.d.record("COMMIT")
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.d.record("ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newFakeConfig returns a config whose database is backed by d.
+func newFakeConfig(t *testing.T, d *fakeDriver) *config.Config {
+	t.Helper()
+	name := "fake-" + uuid.NewString()
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &config.Config{DB: database.New(db), DBConn: db}
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -10,13 +13,24 @@ import (
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/metrics"
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
 )
 
 func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 	// Health check
-	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-		response.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": "user-service"})
+	mux.HandleFunc("GET /health", handlerLive)
+	mux.HandleFunc("GET /health/live", handlerLive)
+
+	mux.HandleFunc("GET /health/ready", func(w http.ResponseWriter, r *http.Request) {
+		handlerReady(cfg, w, r)
+	})
+
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	// Public keys for verifying access tokens
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		handlerJWKS(cfg, w, r)
 	})
 
 	// User routes
@@ -28,6 +42,18 @@ func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 		handlerLogin(cfg, w, r)
 	})
 
+	mux.HandleFunc("POST /api/login/mfa", func(w http.ResponseWriter, r *http.Request) {
+		handlerLoginMFA(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/login/mfa/setup", func(w http.ResponseWriter, r *http.Request) {
+		handlerLoginMFASetup(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/login/mfa/setup/confirm", func(w http.ResponseWriter, r *http.Request) {
+		handlerLoginMFASetupConfirm(cfg, w, r)
+	})
+
 	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
 		handlerRefresh(cfg, w, r)
 	})
@@ -36,103 +62,280 @@ func RegisterRoutes(mux *http.ServeMux, cfg *config.Config) {
 		handlerRevoke(cfg, w, r)
 	})
 
+	mux.HandleFunc("POST /api/verify-email", func(w http.ResponseWriter, r *http.Request) {
+		handlerVerifyEmail(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/verify-email/resend", func(w http.ResponseWriter, r *http.Request) {
+		handlerResendVerification(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/password-reset", func(w http.ResponseWriter, r *http.Request) {
+		handlerPasswordReset(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/password-reset/confirm", func(w http.ResponseWriter, r *http.Request) {
+		handlerPasswordResetConfirm(cfg, w, r)
+	})
+
+	// Session routes (user identity injected by API Gateway)
+	mux.HandleFunc("GET /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		handlerSessionsList(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		handlerSessionsDeleteAll(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerSessionDelete(cfg, w, r)
+	})
+
+	// Profile and address book routes (user identity injected by API Gateway)
+	mux.HandleFunc("PATCH /api/me", func(w http.ResponseWriter, r *http.Request) {
+		handlerProfileUpdate(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /api/me", func(w http.ResponseWriter, r *http.Request) {
+		handlerAccountDelete(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /api/me/addresses", func(w http.ResponseWriter, r *http.Request) {
+		handlerAddressesList(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/me/addresses", func(w http.ResponseWriter, r *http.Request) {
+		handlerAddressCreate(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /api/me/addresses/{addressID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerAddressGet(cfg, w, r)
+	})
+
+	mux.HandleFunc("PUT /api/me/addresses/{addressID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerAddressUpdate(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /api/me/addresses/{addressID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerAddressDelete(cfg, w, r)
+	})
+
+	// Two-factor authentication routes (user identity injected by API Gateway)
+	mux.HandleFunc("GET /api/mfa", func(w http.ResponseWriter, r *http.Request) {
+		handlerMFAStatus(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/mfa/totp/enroll", func(w http.ResponseWriter, r *http.Request) {
+		handlerTOTPEnroll(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/mfa/totp/confirm", func(w http.ResponseWriter, r *http.Request) {
+		handlerTOTPConfirm(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /api/mfa/totp/disable", func(w http.ResponseWriter, r *http.Request) {
+		handlerTOTPDisable(cfg, w, r)
+	})
+
 	// Internal routes (called by API Gateway)
 	mux.HandleFunc("GET /internal/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
 		handlerGetUserByID(cfg, w, r)
 	})
 
+	mux.HandleFunc("GET /internal/users/{userID}/status", func(w http.ResponseWriter, r *http.Request) {
+		handlerUserStatus(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /internal/users/{userID}/addresses", func(w http.ResponseWriter, r *http.Request) {
+		handlerInternalAddressesList(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /internal/users/{userID}/export", func(w http.ResponseWriter, r *http.Request) {
+		handlerInternalUserExport(cfg, w, r)
+	})
+
 	mux.HandleFunc("POST /internal/validate-token", func(w http.ResponseWriter, r *http.Request) {
 		handlerValidateToken(cfg, w, r)
 	})
 
+	mux.HandleFunc("GET /internal/revocations", func(w http.ResponseWriter, r *http.Request) {
+		handlerRevocations(cfg, w, r)
+	})
+
+	// Admin routes
+	mux.HandleFunc("POST /admin/users/{userID}/revoke-tokens", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminRevokeUserTokens(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /admin/users", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminListUsers(cfg, w, r)
+	})
+
+	mux.HandleFunc("GET /admin/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminGetUser(cfg, w, r)
+	})
+
+	mux.HandleFunc("PUT /admin/users/{userID}/role", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminSetUserRole(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /admin/users/{userID}/disable", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminDisableUser(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /admin/users/{userID}/enable", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminEnableUser(cfg, w, r)
+	})
+
+	mux.HandleFunc("DELETE /admin/users/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminDeleteUser(cfg, w, r)
+	})
+
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", func(w http.ResponseWriter, r *http.Request) {
+		handlerAdminUnlockUser(cfg, w, r)
+	})
+
 	// Admin routes (dev only)
 	mux.HandleFunc("POST /admin/reset", func(w http.ResponseWriter, r *http.Request) {
 		handlerReset(cfg, w, r)
 	})
 }
 
+// loginResponse is returned by every endpoint that completes a login.
+type loginResponse struct {
+	User          userResponse `json:"user"`
+	Token         string       `json:"token"`
+	RecoveryCodes []string     `json:"recovery_codes,omitempty"`
+}
+
 func handlerLogin(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Password string `json:"password"`
 		Email    string `json:"email"`
 	}
 
-	type userResponse struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Email     string    `json:"email"`
-		Role      string    `json:"role"`
-	}
-
-	type resp struct {
-		User  userResponse `json:"user"`
-		Token string       `json:"token"`
-	}
-
 	params := parameters{}
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
 		return
 	}
 
-	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
+	ip := clientIP(r)
+	wait, err := loginWait(r.Context(), cfg, params.Email, ip)
 	if err != nil {
-		response.RespondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
+		return
+	}
+	if wait > 0 {
+		respondLoginThrottled(w, wait)
 		return
 	}
 
-	match, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil || !match {
+	// Unknown emails go through the same hashing and bookkeeping as wrong
+	// passwords so the two can't be told apart
+	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	match := false
+	if err == nil {
+		match, _ = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	} else {
+		auth.CheckDummyPasswordHash(params.Password)
+	}
+	if !match {
+		if err := recordLoginFailure(r.Context(), cfg, params.Email, ip, user.ID); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", err)
+			return
+		}
 		response.RespondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
 		return
 	}
 
+	if user.DisabledAt.Valid {
+		response.RespondWithError(w, http.StatusForbidden, "Account is disabled", nil)
+		return
+	}
+
+	// With two-factor authentication on, or required but not set up yet, the
+	// password only earns a challenge token for the second step
+	enabled, err := mfaEnabled(r.Context(), cfg, user.ID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+	if enabled {
+		respondMFAChallenge(cfg, w, user, auth.PurposeMFALogin)
+		return
+	}
+	if cfg.MFARequiredRoles[user.Role] {
+		respondMFAChallenge(cfg, w, user, auth.PurposeMFASetup)
+		return
+	}
+
+	completeLogin(cfg, w, r, user, nil)
+}
+
+// completeLogin starts a session for a user who has passed every login step.
+func completeLogin(cfg *config.Config, w http.ResponseWriter, r *http.Request, user database.User, recoveryCodes []string) {
+	// Checked again in case the account was disabled during a two-factor step
+	if user.DisabledAt.Valid {
+		response.RespondWithError(w, http.StatusForbidden, "Account is disabled", nil)
+		return
+	}
+
+	if err := clearLoginFailures(r.Context(), cfg, user.Email); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", err)
+		return
+	}
+
+	// Each login starts a new session and refresh token family
+	sessionID, refreshToken, err := startSession(r.Context(), cfg, user.ID, r)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		return
+	}
+
 	accessToken, err := auth.MakeJWT(
 		user.ID,
 		user.Role,
-		cfg.JWTSecret,
-		time.Hour,
+		sessionID,
+		cfg.JWTKeys,
+		accessTokenTTL,
 	)
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
 	}
 
-	refreshToken := auth.MakeRefreshToken()
+	setRefreshCookie(w, cfg, refreshToken)
 
-	_, err = cfg.DB.CreateRefreshToken(
-		r.Context(),
-		database.CreateRefreshTokenParams{
-			UserID:    user.ID,
-			Token:     refreshToken,
-			ExpiresAt: time.Now().UTC().Add(24 * time.Hour * 60),
-		},
-	)
-	if err != nil {
-		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
-		return
-	}
+	response.RespondWithJSON(w, http.StatusOK, loginResponse{
+		User:          newUserResponse(user),
+		Token:         accessToken,
+		RecoveryCodes: recoveryCodes,
+	})
+}
 
+const (
+	accessTokenTTL  = time.Hour
+	refreshTokenTTL = 60 * 24 * time.Hour
+	// refreshReuseGrace is how long a rotated refresh token still works, so
+	// two tabs refreshing at once, or a client that lost the response,
+	// don't look like token theft
+	refreshReuseGrace = 10 * time.Second
+)
+
+func setRefreshCookie(w http.ResponseWriter, cfg *config.Config, token string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "refresh_token",
-		Value:    refreshToken,
+		Value:    token,
 		HttpOnly: true,
 		Secure:   cfg.Platform != "dev",
 		Path:     "/api",
 		SameSite: http.SameSiteStrictMode,
-		Expires:  time.Now().Add(24 * time.Hour * 60),
-	})
-
-	response.RespondWithJSON(w, http.StatusOK, resp{
-		User: userResponse{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-			Role:      user.Role,
-		},
-		Token: accessToken,
+		Expires:  time.Now().Add(refreshTokenTTL),
 	})
 }
 
@@ -163,14 +366,28 @@ func handlerUsersCreate(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
 	})
+	if isUniqueViolation(err) {
+		response.RespondWithError(w, http.StatusConflict, "An account with that email already exists", nil)
+		return
+	}
 	if err != nil {
 		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusCreated, user)
+	// New accounts start unverified; failing to mail them can be fixed with
+	// a resend, so it doesn't fail the signup
+	if err := sendVerificationEmail(r.Context(), cfg, user.ID, user.Email); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	response.RespondWithJSON(w, http.StatusCreated, newUserResponse(user))
 }
 
+// handlerRefresh swaps a refresh token for a new one in the same family plus
+// a new access token. Presenting a token that was already swapped means it
+// leaked, so the family and its session are revoked, unless it was swapped
+// within refreshReuseGrace.
 func handlerRefresh(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
 	type resp struct {
 		Token string `json:"token"`
@@ -182,40 +399,153 @@ func handlerRefresh(cfg *config.Config, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	refreshToken := cookie.Value
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	current, err := qtx.GetRefreshTokenForUpdate(r.Context(), cookie.Value)
+	if err != nil {
+		response.RespondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
+		return
+	}
+
+	// refreshToken is the token the client holds after this request
+	refreshToken := current.Token
+	rotate := true
+	if current.ReplacedBy.Valid {
+		successor, err := qtx.GetRecentRefreshTokenSuccessor(r.Context(), database.GetRecentRefreshTokenSuccessorParams{
+			Token:        current.Token,
+			GraceSeconds: int32(refreshReuseGrace / time.Second),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Only this login's tokens are suspect; the user's other
+			// sessions carry on
+			log.Printf("refresh token reuse detected for user %s, revoking family %s", current.UserID, current.FamilyID)
+			if err := qtx.RevokeRefreshTokenFamily(r.Context(), current.FamilyID); err != nil {
+				response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke token family", err)
+				return
+			}
+			_, err := qtx.RevokeSession(r.Context(), database.RevokeSessionParams{
+				ID:     current.FamilyID,
+				UserID: current.UserID,
+			})
+			if err != nil {
+				response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke token family", err)
+				return
+			}
+			response.RespondWithError(w, http.StatusUnauthorized, "Refresh token has already been used", nil)
+			return
+		}
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check refresh token", err)
+			return
+		}
+		// Rotated moments ago: hand back the token that replaced it
+		// rather than forking the family
+		refreshToken, rotate = successor, false
+	}
 
-	user, err := cfg.DB.GetUserByRefreshToken(r.Context(), refreshToken)
+	user, err := qtx.GetUserByRefreshToken(r.Context(), refreshToken)
 	if err != nil {
 		response.RespondWithError(w, http.StatusUnauthorized, "Couldn't get user for refresh token", err)
 		return
 	}
+	if user.DisabledAt.Valid {
+		response.RespondWithError(w, http.StatusForbidden, "Account is disabled", nil)
+		return
+	}
+
+	if rotate {
+		newRefreshToken := auth.MakeRefreshToken()
+		_, err = qtx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+			Token:     newRefreshToken,
+			UserID:    user.ID,
+			ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
+			FamilyID:  current.FamilyID,
+		})
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't save refresh token", err)
+			return
+		}
+
+		err = qtx.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+			Token:      current.Token,
+			ReplacedBy: sql.NullString{String: newRefreshToken, Valid: true},
+		})
+		if err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't rotate refresh token", err)
+			return
+		}
+		refreshToken = newRefreshToken
+	}
+
+	if err := qtx.TouchSession(r.Context(), current.FamilyID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update session", err)
+		return
+	}
 
 	accessToken, err := auth.MakeJWT(
 		user.ID,
 		user.Role,
-		cfg.JWTSecret,
-		time.Hour,
+		current.FamilyID,
+		cfg.JWTKeys,
+		accessTokenTTL,
 	)
 	if err != nil {
-		response.RespondWithError(w, http.StatusUnauthorized, "Couldn't validate token", err)
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT", err)
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't rotate refresh token", err)
+		return
+	}
+
+	setRefreshCookie(w, cfg, refreshToken)
 	response.RespondWithJSON(w, http.StatusOK, resp{
 		Token: accessToken,
 	})
 }
 
 func handlerRevoke(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	// Revoke the access token too so logging out takes effect immediately
+	revokedAccess := false
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		if claims, err := auth.ParseJWT(token, cfg.JWTKeys); err == nil {
+			if err := revokeAccessToken(r.Context(), cfg, claims); err != nil {
+				response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke access token", err)
+				return
+			}
+			revokedAccess = true
+		}
+	}
+
 	cookie, err := r.Cookie("refresh_token")
 	if err != nil {
+		if revokedAccess {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
 		response.RespondWithError(w, http.StatusUnauthorized, "Missing refresh token", err)
 		return
 	}
 
-	refreshToken := cookie.Value
-
-	if err := cfg.DB.RevokeRefreshToken(r.Context(), refreshToken); err != nil {
+	// Logging out ends the session, so every token in the family goes
+	session, err := cfg.DB.GetRefreshTokenSession(r.Context(), cookie.Value)
+	if err == nil {
+		if _, err := endSession(r.Context(), cfg, session.FamilyID, session.UserID); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+			return
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
 		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
 		return
 	}
@@ -247,7 +577,7 @@ func handlerGetUserByID(cfg *config.Config, w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, user)
+	response.RespondWithJSON(w, http.StatusOK, newUserResponse(user))
 }
 
 func handlerValidateToken(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
@@ -267,19 +597,34 @@ func handlerValidateToken(cfg *config.Config, w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	userID, role, err := auth.ValidateJWT(req.Token, cfg.JWTSecret)
+	claims, err := auth.ParseJWT(req.Token, cfg.JWTKeys)
+	if err != nil {
+		response.RespondWithJSON(w, http.StatusOK, validateResponse{Valid: false})
+		return
+	}
+
+	revoked, err := isAccessTokenRevoked(r.Context(), cfg, claims)
 	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check token revocation", err)
+		return
+	}
+	if revoked {
 		response.RespondWithJSON(w, http.StatusOK, validateResponse{Valid: false})
 		return
 	}
 
 	response.RespondWithJSON(w, http.StatusOK, validateResponse{
 		Valid:  true,
-		UserID: userID,
-		Role:   role,
+		UserID: uuid.MustParse(claims.Subject),
+		Role:   claims.Role,
 	})
 }
 
+func handlerJWKS(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.RespondWithJSON(w, http.StatusOK, cfg.JWTKeys.JWKS())
+}
+
 func handlerReset(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
 	if cfg.Platform != "dev" {
 		w.WriteHeader(http.StatusForbidden)
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+const (
+	loginScopeEmail = "email"
+	loginScopeIP    = "ip"
+
+	// loginFreeFailures is how many failures an account gets before each
+	// further attempt has to wait, so the odd typo costs nothing
+	loginFreeFailures = 2
+	loginMaxDelay     = 30 * time.Second
+)
+
+// loginDelay is how long an account must wait after its last failure
+// before trying again: nothing at first, then doubling from a second.
+func loginDelay(failures int32) time.Duration {
+	if failures <= loginFreeFailures {
+		return 0
+	}
+	shift := min(failures-loginFreeFailures-1, 10)
+	return min(time.Second<<shift, loginMaxDelay)
+}
+
+// loginSubject is the key failures for an email are counted under. It
+// doesn't depend on whether the account exists.
+func loginSubject(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginWait reports how long the caller must wait before attempting to log
+// in as email from ip, or zero if it may try now.
+func loginWait(ctx context.Context, cfg *config.Config, email, ip string) (time.Duration, error) {
+	failures, err := cfg.DB.GetLoginFailures(ctx, database.GetLoginFailuresParams{
+		Email: loginSubject(email),
+		Ip:    ip,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var wait time.Duration
+	for _, f := range failures {
+		if f.LockedUntil.Valid {
+			wait = max(wait, f.LockedUntil.Time.Sub(now))
+		}
+		// Only accounts slow down; a shared address just has a higher
+		// lockout threshold
+		if f.Scope == loginScopeEmail {
+			wait = max(wait, f.LastFailureAt.Add(loginDelay(f.Failures)).Sub(now))
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed login against the email and the client
+// address, locking either once it reaches its limit. userID is uuid.Nil for
+// unknown emails.
+func recordLoginFailure(ctx context.Context, cfg *config.Config, email, ip string, userID uuid.UUID) error {
+	now := time.Now().UTC()
+	lockedUntil := now.Add(cfg.Login.Lockout)
+
+	failures, err := cfg.DB.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Scope:       loginScopeEmail,
+		Subject:     loginSubject(email),
+		FailedAt:    now,
+		ResetBefore: now.Add(-cfg.Login.Lockout),
+	})
+	if err != nil {
+		return err
+	}
+	if int(failures) >= cfg.Login.MaxFailures {
+		err := cfg.DB.LockLogin(ctx, database.LockLoginParams{
+			Scope:       loginScopeEmail,
+			Subject:     loginSubject(email),
+			LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		if userID != uuid.Nil {
+			log.Printf("locking user %s after %d failed logins", userID, failures)
+			err := recordAudit(ctx, cfg, auditUserLocked, userID, uuid.Nil, map[string]any{
+				"failures":     failures,
+				"ip":           ip,
+				"locked_until": lockedUntil,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	failures, err = cfg.DB.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Scope:       loginScopeIP,
+		Subject:     ip,
+		FailedAt:    now,
+		ResetBefore: now.Add(-cfg.Login.Lockout),
+	})
+	if err != nil {
+		return err
+	}
+	if int(failures) >= cfg.Login.IPMaxFailures {
+		log.Printf("locking out %s after %d failed logins", ip, failures)
+		return cfg.DB.LockLogin(ctx, database.LockLoginParams{
+			Scope:       loginScopeIP,
+			Subject:     ip,
+			LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+		})
+	}
+	return nil
+}
+
+// clearLoginFailures forgets an account's failures after a successful
+// login. The address's count is left to expire so logging into an account
+// you own doesn't reset it.
+func clearLoginFailures(ctx context.Context, cfg *config.Config, email string) error {
+	return cfg.DB.ClearLoginFailures(ctx, database.ClearLoginFailuresParams{
+		Scope:   loginScopeEmail,
+		Subject: loginSubject(email),
+	})
+}
+
+func respondLoginThrottled(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	response.RespondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later", nil)
+}
+
+func handlerAdminUnlockUser(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	user, actorID, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	if err := clearLoginFailures(r.Context(), cfg, user.Email); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't unlock user", err)
+		return
+	}
+
+	if err := recordAudit(r.Context(), cfg, auditUserUnlocked, user.ID, actorID, nil); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
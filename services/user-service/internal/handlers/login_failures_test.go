@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+var testLoginPolicy = config.LoginPolicy{
+	MaxFailures:   5,
+	IPMaxFailures: 20,
+	Lockout:       15 * time.Minute,
+}
+
+// userRow is u as a row of the user queries that return database.User.
+func userRow(u database.User) fakeResult {
+	verifiedAt, _ := u.EmailVerifiedAt.Value()
+	disabledAt, _ := u.DisabledAt.Value()
+	return rowsOf(
+		u.ID.String(), u.CreatedAt, u.UpdatedAt, u.Email, u.HashedPassword, u.Role,
+		verifiedAt, disabledAt, u.DisplayName, u.Phone,
+	)
+}
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{loginFreeFailures, 0},
+		{loginFreeFailures + 1, time.Second},
+		{loginFreeFailures + 2, 2 * time.Second},
+		{loginFreeFailures + 3, 4 * time.Second},
+		{loginFreeFailures + 5, 16 * time.Second},
+		{loginFreeFailures + 6, loginMaxDelay},
+		{1000, loginMaxDelay},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.want {
+			t.Errorf("loginDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginWait(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name: "no failures",
+		},
+		{
+			name: "free failures",
+			rows: [][]driver.Value{
+				{loginScopeEmail, "a@example.com", int64(loginFreeFailures), now, nil},
+			},
+		},
+		{
+			name: "backing off",
+			rows: [][]driver.Value{
+				{loginScopeEmail, "a@example.com", int64(loginFreeFailures + 3), now, nil},
+			},
+			wantMin: 3 * time.Second,
+			wantMax: 4 * time.Second,
+		},
+		{
+			name: "backoff elapsed",
+			rows: [][]driver.Value{
+				{loginScopeEmail, "a@example.com", int64(loginFreeFailures + 3), now.Add(-5 * time.Second), nil},
+			},
+		},
+		{
+			name: "account locked",
+			rows: [][]driver.Value{
+				{loginScopeEmail, "a@example.com", int64(5), now.Add(-time.Hour), now.Add(10 * time.Minute)},
+			},
+			wantMin: 9 * time.Minute,
+			wantMax: 10 * time.Minute,
+		},
+		{
+			// Failures from an address only matter once it is locked
+			name: "busy address",
+			rows: [][]driver.Value{
+				{loginScopeIP, "203.0.113.7", int64(15), now, nil},
+			},
+		},
+		{
+			name: "address locked",
+			rows: [][]driver.Value{
+				{loginScopeIP, "203.0.113.7", int64(20), now, now.Add(time.Minute)},
+			},
+			wantMin: 50 * time.Second,
+			wantMax: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				return fakeResult{columns: make([]string, 5), rows: tt.rows}
+			}}
+			cfg := newFakeConfig(t, d)
+
+			wait, err := loginWait(context.Background(), cfg, " A@Example.com ", "203.0.113.7")
+			if err != nil {
+				t.Fatalf("loginWait: %v", err)
+			}
+			if wait < tt.wantMin || wait > tt.wantMax {
+				t.Errorf("wait = %v, want between %v and %v", wait, tt.wantMin, tt.wantMax)
+			}
+			q, _ := d.find("GetLoginFailures")
+			if q.args[0] != "a@example.com" {
+				t.Errorf("looked up email %q, want it normalized", q.args[0])
+			}
+		})
+	}
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	knownUser := uuid.New()
+	tests := []struct {
+		name        string
+		userID      uuid.UUID
+		emailCount  int64
+		ipCount     int64
+		wantLocks   []string
+		wantAudited bool
+	}{
+		{
+			name:       "below threshold",
+			userID:     knownUser,
+			emailCount: 4,
+			ipCount:    4,
+		},
+		{
+			name:        "account reaches threshold",
+			userID:      knownUser,
+			emailCount:  5,
+			ipCount:     5,
+			wantLocks:   []string{loginScopeEmail},
+			wantAudited: true,
+		},
+		{
+			// Nonexistent accounts lock the same way, there's just no one to audit
+			name:       "unknown email reaches threshold",
+			userID:     uuid.Nil,
+			emailCount: 5,
+			ipCount:    5,
+			wantLocks:  []string{loginScopeEmail},
+		},
+		{
+			name:       "address reaches threshold",
+			userID:     knownUser,
+			emailCount: 1,
+			ipCount:    20,
+			wantLocks:  []string{loginScopeIP},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				if q.name == "RecordLoginFailure" {
+					if q.args[0] == loginScopeEmail {
+						return rowsOf(tt.emailCount)
+					}
+					return rowsOf(tt.ipCount)
+				}
+				return fakeResult{}
+			}}
+			cfg := newFakeConfig(t, d)
+			cfg.Login = testLoginPolicy
+
+			if err := recordLoginFailure(context.Background(), cfg, "A@example.com", "203.0.113.7", tt.userID); err != nil {
+				t.Fatalf("recordLoginFailure: %v", err)
+			}
+
+			var locks []string
+			audited := false
+			for _, q := range d.queries {
+				switch q.name {
+				case "LockLogin":
+					locks = append(locks, q.args[0].(string))
+				case "CreateAuditLog":
+					audited = true
+				}
+			}
+			if !slices.Equal(locks, tt.wantLocks) {
+				t.Errorf("locked %v, want %v", locks, tt.wantLocks)
+			}
+			if audited != tt.wantAudited {
+				t.Errorf("audited = %v, want %v", audited, tt.wantAudited)
+			}
+		})
+	}
+}
+
+// loginDB fakes the queries handlerLogin makes. failures is what
+// GetLoginFailures returns for the email.
+type loginDB struct {
+	userID   uuid.UUID
+	email    string
+	hash     string
+	failures int64
+	lastFail time.Time
+}
+
+func (l loginDB) driver() *fakeDriver {
+	return &fakeDriver{respond: func(q fakeQuery) fakeResult {
+		switch q.name {
+		case "GetLoginFailures":
+			if l.failures == 0 {
+				return fakeResult{}
+			}
+			return rowsOf(loginScopeEmail, q.args[0], l.failures, l.lastFail, nil)
+		case "GetUserByEmail":
+			if q.args[0] != l.email {
+				return fakeResult{}
+			}
+			return userRow(database.User{
+				ID:              l.userID,
+				Email:           l.email,
+				HashedPassword:  l.hash,
+				Role:            "customer",
+				EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			})
+		case "RecordLoginFailure":
+			return rowsOf(l.failures + 1)
+		case "CreateSession":
+			now := time.Now()
+			return rowsOf(q.args[0], q.args[1], now, now, q.args[2], q.args[3], nil)
+		case "CreateRefreshToken":
+			now := time.Now()
+			return rowsOf(q.args[0], now, now, q.args[1], q.args[2], nil, q.args[3], nil)
+		}
+		return fakeResult{}
+	}}
+}
+
+func newLoginConfig(t *testing.T, d *fakeDriver) *config.Config {
+	t.Helper()
+	keys, err := auth.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newFakeConfig(t, d)
+	cfg.JWTKeys = keys
+	cfg.Login = testLoginPolicy
+	return cfg
+}
+
+func postLogin(cfg *config.Config, email, password string) *httptest.ResponseRecorder {
+	body := `{"email":"` + email + `","password":"` + password + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+	r.RemoteAddr = "203.0.113.7:5000"
+	rec := httptest.NewRecorder()
+	handlerLogin(cfg, rec, r)
+	return rec
+}
+
+func TestHandlerLoginFailures(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := loginDB{userID: uuid.New(), email: "a@example.com", hash: hash}
+
+	t.Run("success clears failures", func(t *testing.T) {
+		l := account
+		l.failures = loginFreeFailures
+		d := l.driver()
+		rec := postLogin(newLoginConfig(t, d), "a@example.com", "correct horse")
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		q, ok := d.find("ClearLoginFailures")
+		if !ok {
+			t.Fatal("successful login didn't clear failures")
+		}
+		if q.args[0] != loginScopeEmail || q.args[1] != "a@example.com" {
+			t.Errorf("cleared %v, want the email's failures", q.args)
+		}
+		if _, ok := d.find("RecordLoginFailure"); ok {
+			t.Error("successful login recorded a failure")
+		}
+	})
+
+	t.Run("wrong password is recorded", func(t *testing.T) {
+		d := account.driver()
+		rec := postLogin(newLoginConfig(t, d), "a@example.com", "wrong")
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", rec.Code)
+		}
+		if _, ok := d.find("ClearLoginFailures"); ok {
+			t.Error("failed login cleared failures")
+		}
+	})
+
+	t.Run("throttled even with the right password", func(t *testing.T) {
+		l := account
+		l.failures = loginFreeFailures + 2
+		l.lastFail = time.Now()
+		d := l.driver()
+		rec := postLogin(newLoginConfig(t, d), "a@example.com", "correct horse")
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", rec.Code)
+		}
+		if rec.Header().Get("Retry-After") != "2" {
+			t.Errorf("Retry-After = %q, want 2", rec.Header().Get("Retry-After"))
+		}
+		if _, ok := d.find("GetUserByEmail"); ok {
+			t.Error("throttled login still looked up the user")
+		}
+	})
+}
+
+// An unknown email must be indistinguishable from a wrong password: same
+// response, same bookkeeping, same throttling.
+func TestHandlerLoginUnknownEmail(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, failures := range []int64{0, loginFreeFailures + 2} {
+		known := loginDB{userID: uuid.New(), email: "a@example.com", hash: hash, failures: failures, lastFail: time.Now()}
+		knownDriver := known.driver()
+		knownRec := postLogin(newLoginConfig(t, knownDriver), "a@example.com", "wrong")
+
+		unknown := known
+		unknown.email = "someone-else@example.com"
+		unknownDriver := unknown.driver()
+		unknownRec := postLogin(newLoginConfig(t, unknownDriver), "nobody@example.com", "wrong")
+
+		if knownRec.Code != unknownRec.Code {
+			t.Errorf("failures=%d: status %d for a known email, %d for an unknown one", failures, knownRec.Code, unknownRec.Code)
+		}
+		if knownRec.Body.String() != unknownRec.Body.String() {
+			t.Errorf("failures=%d: bodies differ: %q vs %q", failures, knownRec.Body, unknownRec.Body)
+		}
+		if knownRec.Header().Get("Retry-After") != unknownRec.Header().Get("Retry-After") {
+			t.Errorf("failures=%d: Retry-After differs", failures)
+		}
+		if got, want := unknownDriver.ran(), knownDriver.ran(); !slices.Equal(got, want) {
+			t.Errorf("failures=%d: unknown email ran %v, known ran %v", failures, got, want)
+		}
+	}
+}
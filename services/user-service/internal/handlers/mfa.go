@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+const (
+	// mfaIssuer labels the account in authenticator apps
+	mfaIssuer = "Ecom"
+
+	mfaLoginTokenTTL  = 5 * time.Minute
+	mfaSetupTokenTTL  = 10 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var (
+	errMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	errInvalidMFACode    = errors.New("invalid two-factor code")
+)
+
+func mfaEnabled(ctx context.Context, cfg *config.Config, userID uuid.UUID) (bool, error) {
+	mfa, err := cfg.DB.GetUserMFA(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.EnabledAt.Valid, nil
+}
+
+// respondMFAChallenge answers a correct password with a token for the second
+// login step instead of a session. The token is bound to the password hash,
+// so changing the password invalidates outstanding challenges.
+func respondMFAChallenge(cfg *config.Config, w http.ResponseWriter, user database.User, purpose string) {
+	type resp struct {
+		MFARequired      bool   `json:"mfa_required,omitempty"`
+		MFASetupRequired bool   `json:"mfa_setup_required,omitempty"`
+		MFAToken         string `json:"mfa_token"`
+	}
+
+	ttl := mfaLoginTokenTTL
+	if purpose == auth.PurposeMFASetup {
+		ttl = mfaSetupTokenTTL
+	}
+	token, err := auth.MakePurposeToken(user.ID, purpose, auth.Fingerprint(user.HashedPassword), cfg.JWTKeys, ttl)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't create challenge token", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, resp{
+		MFARequired:      purpose == auth.PurposeMFALogin,
+		MFASetupRequired: purpose == auth.PurposeMFASetup,
+		MFAToken:         token,
+	})
+}
+
+// parseMFAChallenge returns the user a challenge token from
+// respondMFAChallenge was issued to.
+func parseMFAChallenge(ctx context.Context, cfg *config.Config, token, purpose string) (database.User, error) {
+	userID, claims, err := auth.ParsePurposeToken(token, purpose, cfg.JWTKeys)
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := cfg.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if !claims.Bound(user.HashedPassword) {
+		return database.User{}, errors.New("challenge token no longer matches the account")
+	}
+	return user, nil
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused recovery code,
+// and uses it up.
+func verifySecondFactor(ctx context.Context, cfg *config.Config, mfa database.UserMfa, code string) (bool, error) {
+	if step, ok := auth.ValidateTOTP(mfa.TotpSecret, code, time.Now()); ok {
+		rows, err := cfg.DB.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			UserID:       mfa.UserID,
+			LastUsedStep: step,
+		})
+		return rows > 0, err
+	}
+
+	rows, err := cfg.DB.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   mfa.UserID,
+		CodeHash: auth.HashRecoveryCode(code),
+	})
+	return rows > 0, err
+}
+
+// beginTOTPEnrollment stores a new secret awaiting confirmation, replacing
+// any earlier unconfirmed one.
+func beginTOTPEnrollment(ctx context.Context, cfg *config.Config, user database.User) (string, string, error) {
+	mfa, err := cfg.DB.StartTOTPEnrollment(ctx, database.StartTOTPEnrollmentParams{
+		UserID:     user.ID,
+		TotpSecret: auth.GenerateTOTPSecret(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", errMFAAlreadyEnabled
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return mfa.TotpSecret, auth.TOTPProvisioningURI(mfa.TotpSecret, user.Email, mfaIssuer), nil
+}
+
+// confirmTOTPEnrollment turns two-factor authentication on once the user
+// proves their app produces codes, and returns fresh recovery codes.
+func confirmTOTPEnrollment(ctx context.Context, cfg *config.Config, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := cfg.DB.GetUserMFA(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errInvalidMFACode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mfa.EnabledAt.Valid {
+		return nil, errMFAAlreadyEnabled
+	}
+	step, ok := auth.ValidateTOTP(mfa.TotpSecret, code, time.Now())
+	if !ok {
+		return nil, errInvalidMFACode
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	rows, err := qtx.EnableTOTP(ctx, database.EnableTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, errMFAAlreadyEnabled
+	}
+	if err := qtx.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	for _, c := range codes {
+		err := qtx.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(c),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func respondEnrollmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errMFAAlreadyEnabled):
+		response.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+	case errors.Is(err, errInvalidMFACode):
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid two-factor code", nil)
+	default:
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+	}
+}
+
+func handlerMFAStatus(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type statusResponse struct {
+		Enabled                bool  `json:"enabled"`
+		Required               bool  `json:"required"`
+		RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+	}
+
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	enabled, err := mfaEnabled(r.Context(), cfg, userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+	remaining, err := cfg.DB.CountUnusedRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't count recovery codes", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, statusResponse{
+		Enabled:                enabled,
+		Required:               cfg.MFARequiredRoles[r.Header.Get("X-User-Role")],
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+type enrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func handlerTOTPEnroll(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	secret, uri, err := beginTOTPEnrollment(r.Context(), cfg, user)
+	if err != nil {
+		respondEnrollmentError(w, err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, enrollResponse{
+		Secret:          secret,
+		ProvisioningURI: uri,
+	})
+}
+
+func handlerTOTPConfirm(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	type resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	codes, err := confirmTOTPEnrollment(r.Context(), cfg, userID, params.Code)
+	if err != nil {
+		respondEnrollmentError(w, err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, resp{RecoveryCodes: codes})
+}
+
+// handlerTOTPDisable turns two-factor authentication off. It takes a current
+// code so a stolen access token alone can't do it.
+func handlerTOTPDisable(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if cfg.MFARequiredRoles[r.Header.Get("X-User-Role")] {
+		response.RespondWithError(w, http.StatusForbidden, "Two-factor authentication is required for your role", nil)
+		return
+	}
+
+	mfa, err := cfg.DB.GetUserMFA(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !mfa.EnabledAt.Valid) {
+		response.RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled", nil)
+		return
+	}
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
+		return
+	}
+
+	ok, err = verifySecondFactor(r.Context(), cfg, mfa, params.Code)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor code", err)
+		return
+	}
+	if !ok {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid two-factor code", nil)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := qtx.DeleteUserMFA(r.Context(), userID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLoginMFA is the second login step for users with two-factor
+// authentication on. Wrong codes count as failed logins.
+func handlerLoginMFA(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := parseMFAChallenge(r.Context(), cfg, params.MFAToken, auth.PurposeMFALogin)
+	if err != nil {
+		response.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	ip := clientIP(r)
+	wait, err := loginWait(r.Context(), cfg, user.Email, ip)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
+		return
+	}
+	if wait > 0 {
+		respondLoginThrottled(w, wait)
+		return
+	}
+
+	mfa, err := cfg.DB.GetUserMFA(r.Context(), user.ID)
+	if err != nil || !mfa.EnabledAt.Valid {
+		response.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	ok, err := verifySecondFactor(r.Context(), cfg, mfa, params.Code)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check two-factor code", err)
+		return
+	}
+	if !ok {
+		if err := recordLoginFailure(r.Context(), cfg, user.Email, ip, user.ID); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", err)
+			return
+		}
+		response.RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code", nil)
+		return
+	}
+
+	completeLogin(cfg, w, r, user, nil)
+}
+
+// handlerLoginMFASetup starts enrollment for a user whose role requires
+// two-factor authentication but who hasn't set it up, so they can finish
+// logging in.
+func handlerLoginMFASetup(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken string `json:"mfa_token"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := parseMFAChallenge(r.Context(), cfg, params.MFAToken, auth.PurposeMFASetup)
+	if err != nil {
+		response.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	secret, uri, err := beginTOTPEnrollment(r.Context(), cfg, user)
+	if err != nil {
+		respondEnrollmentError(w, err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, enrollResponse{
+		Secret:          secret,
+		ProvisioningURI: uri,
+	})
+}
+
+// handlerLoginMFASetupConfirm enables two-factor authentication and
+// completes the login, returning the recovery codes alongside the session.
+// As with handlerLoginMFA, wrong codes count as failed logins.
+func handlerLoginMFASetupConfirm(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := parseMFAChallenge(r.Context(), cfg, params.MFAToken, auth.PurposeMFASetup)
+	if err != nil {
+		response.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+		return
+	}
+
+	ip := clientIP(r)
+	wait, err := loginWait(r.Context(), cfg, user.Email, ip)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't check login attempts", err)
+		return
+	}
+	if wait > 0 {
+		respondLoginThrottled(w, wait)
+		return
+	}
+
+	codes, err := confirmTOTPEnrollment(r.Context(), cfg, user.ID, params.Code)
+	if errors.Is(err, errInvalidMFACode) {
+		if err := recordLoginFailure(r.Context(), cfg, user.Email, ip, user.ID); err != nil {
+			response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record login attempt", err)
+			return
+		}
+	}
+	if err != nil {
+		respondEnrollmentError(w, err)
+		return
+	}
+
+	completeLogin(cfg, w, r, user, codes)
+}
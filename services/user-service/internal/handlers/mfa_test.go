@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+// recoveryCodeDB stands in for the mfa_recovery_codes table. It only answers
+// UseRecoveryCode and applies the same "used_at IS NULL" guard as the query.
+type recoveryCodeDB struct {
+	database.DBTX
+
+	mu     sync.Mutex
+	unused map[string]bool
+}
+
+func (db *recoveryCodeDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !strings.Contains(query, "-- name: UseRecoveryCode ") {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+	key := args[0].(uuid.UUID).String() + ":" + args[1].(string)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if !db.unused[key] {
+		return rowsAffected(0), nil
+	}
+	db.unused[key] = false
+	return rowsAffected(1), nil
+}
+
+type rowsAffected int64
+
+func (n rowsAffected) LastInsertId() (int64, error) { return 0, nil }
+func (n rowsAffected) RowsAffected() (int64, error) { return int64(n), nil }
+
+func TestVerifySecondFactorRecoveryCodeSingleUse(t *testing.T) {
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	codes := auth.GenerateRecoveryCodes(2)
+
+	db := &recoveryCodeDB{unused: map[string]bool{
+		userID.String() + ":" + auth.HashRecoveryCode(codes[0]): true,
+		userID.String() + ":" + auth.HashRecoveryCode(codes[1]): true,
+	}}
+	cfg := &config.Config{DB: database.New(db)}
+	mfa := database.UserMfa{UserID: userID, TotpSecret: auth.GenerateTOTPSecret()}
+
+	steps := []struct {
+		name   string
+		userID uuid.UUID
+		code   string
+		ok     bool
+	}{
+		{name: "first use", userID: userID, code: codes[0], ok: true},
+		{name: "second use", userID: userID, code: codes[0], ok: false},
+		{name: "second use reformatted", userID: userID, code: strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")), ok: false},
+		{name: "another user's code", userID: otherUserID, code: codes[1], ok: false},
+		{name: "other code still works", userID: userID, code: codes[1], ok: true},
+		{name: "unknown code", userID: userID, code: "aaaaa-aaaaa", ok: false},
+	}
+
+	for _, step := range steps {
+		mfa.UserID = step.userID
+		ok, err := verifySecondFactor(context.Background(), cfg, mfa, step.code)
+		if err != nil {
+			t.Fatalf("%s: verifySecondFactor: %v", step.name, err)
+		}
+		if ok != step.ok {
+			t.Fatalf("%s: ok = %v, want %v", step.name, ok, step.ok)
+		}
+	}
+}
+
+// TestHandlerLoginMFASetupConfirmLockout checks that guessing the enrollment
+// code is throttled like guessing the login code.
+func TestHandlerLoginMFASetupConfirmLockout(t *testing.T) {
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := database.User{ID: uuid.New(), Email: "a@example.com", HashedPassword: hash, Role: "admin"}
+
+	tests := []struct {
+		name       string
+		failures   int64
+		wantStatus int
+		wantRecord bool
+	}{
+		{name: "wrong code is recorded", wantStatus: http.StatusBadRequest, wantRecord: true},
+		{name: "throttled", failures: loginFreeFailures + 2, wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				switch q.name {
+				case "GetUserByID":
+					return userRow(user)
+				case "GetLoginFailures":
+					if tt.failures == 0 {
+						return fakeResult{}
+					}
+					return rowsOf(loginScopeEmail, q.args[0], tt.failures, now, nil)
+				case "GetUserMFA":
+					return rowsOf(user.ID.String(), auth.GenerateTOTPSecret(), nil, int64(0), now, now)
+				case "RecordLoginFailure":
+					return rowsOf(tt.failures + 1)
+				}
+				return fakeResult{}
+			}}
+			cfg := newLoginConfig(t, d)
+			token, err := auth.MakePurposeToken(user.ID, auth.PurposeMFASetup, auth.Fingerprint(hash), cfg.JWTKeys, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body := `{"mfa_token":"` + token + `","code":"not-a-code"}`
+			r := httptest.NewRequest(http.MethodPost, "/api/login/mfa/setup/confirm", strings.NewReader(body))
+			r.RemoteAddr = "203.0.113.7:5000"
+			rec := httptest.NewRecorder()
+			handlerLoginMFASetupConfirm(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			recorded, ok := d.find("RecordLoginFailure")
+			if ok != tt.wantRecord {
+				t.Fatalf("recorded a failure = %v, want %v", ok, tt.wantRecord)
+			}
+			if ok && recorded.args[1] != "a@example.com" {
+				t.Errorf("recorded the failure against %v, want the email", recorded.args[1])
+			}
+			if tt.wantStatus == http.StatusTooManyRequests {
+				if _, ok := d.find("GetUserMFA"); ok {
+					t.Error("throttled confirmation still checked the code")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+)
+
+// refreshFamily is one login's refresh tokens, held in memory and answered
+// the way the refresh token queries would.
+type refreshFamily struct {
+	mu       sync.Mutex
+	user     database.User
+	familyID uuid.UUID
+	tokens   map[string]*refreshTokenState
+}
+
+type refreshTokenState struct {
+	revoked    bool
+	replacedBy string
+	// rotatedAgo is how long ago the token was replaced
+	rotatedAgo time.Duration
+}
+
+func newRefreshFamily() *refreshFamily {
+	now := time.Now()
+	return &refreshFamily{
+		user:     database.User{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Email: "a@example.com", Role: "customer"},
+		familyID: uuid.New(),
+		tokens:   make(map[string]*refreshTokenState),
+	}
+}
+
+// rotated records that token was replaced by next, ago.
+func (f *refreshFamily) rotated(token, next string, ago time.Duration) {
+	f.tokens[token] = &refreshTokenState{revoked: true, replacedBy: next, rotatedAgo: ago}
+	if f.tokens[next] == nil {
+		f.tokens[next] = &refreshTokenState{}
+	}
+}
+
+func (f *refreshFamily) respond(q fakeQuery) fakeResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+
+	switch q.name {
+	case "GetRefreshTokenForUpdate":
+		token := q.args[0].(string)
+		state, ok := f.tokens[token]
+		if !ok {
+			return fakeResult{}
+		}
+		var revokedAt, replacedBy driver.Value
+		if state.revoked {
+			revokedAt = now.Add(-state.rotatedAgo)
+		}
+		if state.replacedBy != "" {
+			replacedBy = state.replacedBy
+		}
+		return rowsOf(token, now, now, f.user.ID.String(), now.Add(time.Hour), revokedAt, f.familyID.String(), replacedBy)
+	case "GetRecentRefreshTokenSuccessor":
+		prev := f.tokens[q.args[0].(string)]
+		grace := time.Duration(q.args[1].(int64)) * time.Second
+		next, ok := f.tokens[prev.replacedBy]
+		if prev.rotatedAgo < grace && ok && !next.revoked {
+			return rowsOf(prev.replacedBy)
+		}
+		return fakeResult{}
+	case "GetUserByRefreshToken":
+		if state, ok := f.tokens[q.args[0].(string)]; ok && !state.revoked {
+			return userRow(f.user)
+		}
+		return fakeResult{}
+	case "CreateRefreshToken":
+		f.tokens[q.args[0].(string)] = &refreshTokenState{}
+		return rowsOf(q.args[0], now, now, q.args[1], q.args[2], nil, q.args[3], nil)
+	case "RotateRefreshToken":
+		f.tokens[q.args[0].(string)] = &refreshTokenState{revoked: true, replacedBy: q.args[1].(string)}
+	case "RevokeRefreshTokenFamily":
+		for _, state := range f.tokens {
+			state.revoked = true
+		}
+	case "RevokeSession":
+		return fakeResult{affected: 1}
+	}
+	return fakeResult{}
+}
+
+func (f *refreshFamily) revoked(token string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tokens[token].revoked
+}
+
+func postRefresh(t *testing.T, d *fakeDriver, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	cfg := newLoginConfig(t, d)
+	r := httptest.NewRequest(http.MethodPost, "/api/refresh", nil)
+	r.AddCookie(&http.Cookie{Name: "refresh_token", Value: token})
+	rec := httptest.NewRecorder()
+	handlerRefresh(cfg, rec, r)
+	return rec
+}
+
+func refreshCookie(rec *httptest.ResponseRecorder) string {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestHandlerRefreshRotates(t *testing.T) {
+	f := newRefreshFamily()
+	f.tokens["first"] = &refreshTokenState{}
+	d := &fakeDriver{respond: f.respond}
+
+	rec := postRefresh(t, d, "first")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	next := refreshCookie(rec)
+	if next == "" || next == "first" {
+		t.Fatalf("refresh cookie = %q, want a new token", next)
+	}
+	if !f.revoked("first") || f.tokens["first"].replacedBy != next {
+		t.Errorf("old token = %+v, want it revoked and replaced by the new one", f.tokens["first"])
+	}
+	created, _ := d.find("CreateRefreshToken")
+	if created.args[3] != f.familyID.String() {
+		t.Errorf("new token family = %v, want %s", created.args[3], f.familyID)
+	}
+	if ran := d.ran(); ran[len(ran)-1] != "COMMIT" {
+		t.Errorf("ran %v, want a commit", ran)
+	}
+
+	// The new token works in its turn
+	again := postRefresh(t, d, next)
+	if again.Code != http.StatusOK || refreshCookie(again) == next {
+		t.Errorf("refreshing with the new token: status %d, cookie %q", again.Code, refreshCookie(again))
+	}
+}
+
+func TestHandlerRefreshReuse(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(f *refreshFamily)
+		wantStatus int
+		wantCookie string
+		wantRevoke bool
+	}{
+		{
+			// Two tabs refreshing at once, or a lost response
+			name:       "previous token within the grace window",
+			setup:      func(f *refreshFamily) { f.rotated("first", "second", 2*time.Second) },
+			wantStatus: http.StatusOK,
+			wantCookie: "second",
+		},
+		{
+			name:       "previous token after the grace window",
+			setup:      func(f *refreshFamily) { f.rotated("first", "second", refreshReuseGrace+time.Second) },
+			wantStatus: http.StatusUnauthorized,
+			wantRevoke: true,
+		},
+		{
+			// Only the token right before the live one gets the grace
+			name: "older token within the grace window",
+			setup: func(f *refreshFamily) {
+				f.rotated("first", "second", 2*time.Second)
+				f.rotated("second", "third", time.Second)
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantRevoke: true,
+		},
+		{
+			name: "family already revoked",
+			setup: func(f *refreshFamily) {
+				f.rotated("first", "second", time.Second)
+				f.tokens["second"].revoked = true
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantRevoke: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRefreshFamily()
+			tt.setup(f)
+			d := &fakeDriver{respond: f.respond}
+
+			rec := postRefresh(t, d, "first")
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := refreshCookie(rec); got != tt.wantCookie {
+				t.Errorf("refresh cookie = %q, want %q", got, tt.wantCookie)
+			}
+			ran := d.ran()
+			// No fork in the family either way
+			if slices.Contains(ran, "CreateRefreshToken") || slices.Contains(ran, "RotateRefreshToken") {
+				t.Errorf("ran %v, want no new token", ran)
+			}
+			if slices.Contains(ran, "RevokeRefreshTokenFamily") != tt.wantRevoke {
+				t.Fatalf("ran %v, want family revoked %v", ran, tt.wantRevoke)
+			}
+			if !tt.wantRevoke {
+				return
+			}
+
+			// Just this login is logged out, not the user's other sessions
+			session, ok := d.find("RevokeSession")
+			if !ok || session.args[0] != f.familyID.String() {
+				t.Errorf("revoked session %v, want %s", session.args, f.familyID)
+			}
+			for _, name := range []string{"RevokeUserTokensBefore", "RevokeUserSessions", "RevokeUserRefreshTokens"} {
+				if slices.Contains(ran, name) {
+					t.Errorf("ran %s on reuse, want only the family revoked", name)
+				}
+			}
+			if ran[len(ran)-1] != "COMMIT" {
+				t.Errorf("ran %v, want the revocation committed", ran)
+			}
+			for token := range f.tokens {
+				if !f.revoked(token) {
+					t.Errorf("token %s still live after the family was revoked", token)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerRefreshUnknownToken(t *testing.T) {
+	f := newRefreshFamily()
+	d := &fakeDriver{respond: f.respond}
+
+	rec := postRefresh(t, d, "never-issued")
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401: %s", rec.Code, rec.Body)
+	}
+	if slices.Contains(d.ran(), "RevokeRefreshTokenFamily") {
+		t.Error("revoked a family for a token that doesn't exist")
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+// revocationCutoff is the revoked_before for a revocation of all of a user's
+// tokens made now. Tokens carry iat in whole seconds, so the cutoff is too:
+// tokens from earlier seconds are revoked, and one issued later in this
+// second, such as the login right after logging out everywhere, is not.
+// Tokens from this second issued before the revocation are caught by ending
+// their sessions.
+func revocationCutoff() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// revokeAllUserTokens invalidates every access token issued to the user so
+// far, and ends all of their sessions.
+func revokeAllUserTokens(ctx context.Context, cfg *config.Config, userID uuid.UUID) error {
+	err := cfg.DB.RevokeUserTokensBefore(ctx, database.RevokeUserTokensBeforeParams{
+		UserID:        userID,
+		RevokedBefore: revocationCutoff(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := cfg.DB.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	return cfg.DB.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// revokeAccessToken revokes a single token. claims must come from
+// auth.ParseJWT, which guarantees a valid subject and jti.
+func revokeAccessToken(ctx context.Context, cfg *config.Config, claims *auth.Claims) error {
+	return cfg.DB.RevokeAccessToken(ctx, database.RevokeAccessTokenParams{
+		Jti:       uuid.MustParse(claims.ID),
+		UserID:    uuid.MustParse(claims.Subject),
+		ExpiresAt: claims.ExpiresAt.Time.UTC(),
+	})
+}
+
+// isAccessTokenRevoked reports whether the token was revoked on its own, by
+// a revocation of all the user's tokens issued before its iat, or by
+// ending the session it was issued under.
+func isAccessTokenRevoked(ctx context.Context, cfg *config.Config, claims *auth.Claims) (bool, error) {
+	// Tokens without a sid match no session
+	sessionID, _ := uuid.Parse(claims.SessionID)
+	return cfg.DB.IsAccessTokenRevoked(ctx, database.IsAccessTokenRevokedParams{
+		Jti:           uuid.MustParse(claims.ID),
+		UserID:        uuid.MustParse(claims.Subject),
+		RevokedBefore: claims.IssuedAt.Time.UTC(),
+		ID:            sessionID,
+	})
+}
+
+func handlerAdminRevokeUserTokens(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	user, actorID, ok := adminTarget(cfg, w, r)
+	if !ok {
+		return
+	}
+
+	if err := revokeAllUserTokens(r.Context(), cfg, user.ID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke tokens", err)
+		return
+	}
+
+	if err := recordAudit(r.Context(), cfg, auditUserTokensRevoked, user.ID, actorID, nil); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't record audit log", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRevocations is the feed the gateway polls. It returns revocations
+// made after since (all of them when since is omitted) and the time the
+// caller should pass as since next.
+func handlerRevocations(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type revokedToken struct {
+		JTI       uuid.UUID `json:"jti"`
+		UserID    uuid.UUID `json:"user_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	type userRevocation struct {
+		UserID        uuid.UUID `json:"user_id"`
+		RevokedBefore time.Time `json:"revoked_before"`
+	}
+
+	// Access tokens issued under the session stop being valid at expires_at
+	// anyway, so the gateway can forget the session after that.
+	type revokedSession struct {
+		SessionID uuid.UUID `json:"session_id"`
+		UserID    uuid.UUID `json:"user_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	type resp struct {
+		AsOf     time.Time        `json:"as_of"`
+		Tokens   []revokedToken   `json:"tokens"`
+		Users    []userRevocation `json:"users"`
+		Sessions []revokedSession `json:"sessions"`
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			response.RespondWithError(w, http.StatusBadRequest, "Invalid since timestamp", err)
+			return
+		}
+		since = parsed.UTC()
+	}
+
+	// Taken from the database, which stamps the revocations, before querying
+	// so nothing committed meanwhile is skipped next time
+	asOf, err := cfg.DB.GetRevocationFeedTime(r.Context())
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't read the database clock", err)
+		return
+	}
+	asOf = asOf.UTC()
+
+	tokens, err := cfg.DB.ListRevokedTokensSince(r.Context(), since)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list revoked tokens", err)
+		return
+	}
+	users, err := cfg.DB.ListUserRevocationsSince(r.Context(), since)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list user revocations", err)
+		return
+	}
+	// Sessions ended longer ago than an access token lives are no longer
+	// relevant
+	sessionsSince := since
+	if cutoff := asOf.Add(-accessTokenTTL); sessionsSince.Before(cutoff) {
+		sessionsSince = cutoff
+	}
+	sessions, err := cfg.DB.ListRevokedSessionsSince(r.Context(), sql.NullTime{Time: sessionsSince, Valid: true})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list revoked sessions", err)
+		return
+	}
+
+	out := resp{
+		AsOf:     asOf,
+		Tokens:   make([]revokedToken, 0, len(tokens)),
+		Users:    make([]userRevocation, 0, len(users)),
+		Sessions: make([]revokedSession, 0, len(sessions)),
+	}
+	for _, t := range tokens {
+		out.Tokens = append(out.Tokens, revokedToken{
+			JTI:       t.Jti,
+			UserID:    t.UserID,
+			ExpiresAt: t.ExpiresAt,
+		})
+	}
+	for _, u := range users {
+		out.Users = append(out.Users, userRevocation{
+			UserID:        u.UserID,
+			RevokedBefore: u.RevokedBefore,
+		})
+	}
+	for _, s := range sessions {
+		out.Sessions = append(out.Sessions, revokedSession{
+			SessionID: s.ID,
+			UserID:    s.UserID,
+			ExpiresAt: s.RevokedAt.Time.Add(accessTokenTTL),
+		})
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
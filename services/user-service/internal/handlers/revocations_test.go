@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerRevocations(t *testing.T) {
+	// The database clock, deliberately far from the test's own
+	dbNow := time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	since := dbNow.Add(-10 * time.Second)
+
+	tests := []struct {
+		name        string
+		query       string
+		clockErr    error
+		wantStatus  int
+		wantSince   time.Time
+		wantSession time.Time
+	}{
+		{
+			name:        "everything",
+			wantStatus:  http.StatusOK,
+			wantSession: dbNow.Add(-accessTokenTTL),
+		},
+		{
+			name:        "since the last poll",
+			query:       "?since=" + since.Format(time.RFC3339Nano),
+			wantStatus:  http.StatusOK,
+			wantSince:   since,
+			wantSession: since,
+		},
+		{
+			name:       "invalid since",
+			query:      "?since=yesterday",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "database down",
+			clockErr:   errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				if q.name == "GetRevocationFeedTime" {
+					if tt.clockErr != nil {
+						return fakeResult{err: tt.clockErr}
+					}
+					return rowsOf(dbNow)
+				}
+				return fakeResult{}
+			}}
+			cfg := newFakeConfig(t, d)
+
+			rec := httptest.NewRecorder()
+			handlerRevocations(cfg, rec, httptest.NewRequest(http.MethodGet, "/internal/revocations"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			// as_of is the database's time, so the gateway's next since is
+			// on the same clock as revoked_at
+			var feed struct {
+				AsOf time.Time `json:"as_of"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&feed); err != nil {
+				t.Fatal(err)
+			}
+			if !feed.AsOf.Equal(dbNow) {
+				t.Errorf("as_of = %v, want the database time %v", feed.AsOf, dbNow)
+			}
+
+			for name, want := range map[string]time.Time{
+				"ListRevokedTokensSince":   tt.wantSince,
+				"ListUserRevocationsSince": tt.wantSince,
+				"ListRevokedSessionsSince": tt.wantSession,
+			} {
+				q, ok := d.find(name)
+				if !ok {
+					t.Errorf("%s wasn't run", name)
+					continue
+				}
+				if got, _ := q.args[0].(time.Time); !got.Equal(want) {
+					t.Errorf("%s since %v, want %v", name, q.args[0], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+// startSession records a new login and returns the refresh token that
+// starts its family.
+func startSession(ctx context.Context, cfg *config.Config, userID uuid.UUID, r *http.Request) (uuid.UUID, string, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	session, err := qtx.CreateSession(ctx, database.CreateSessionParams{
+		ID:        uuid.New(),
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	})
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	refreshToken := auth.MakeRefreshToken()
+	_, err = qtx.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    userID,
+		Token:     refreshToken,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
+		FamilyID:  session.ID,
+	})
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, "", err
+	}
+	return session.ID, refreshToken, nil
+}
+
+// endSession revokes the user's session and every refresh token in its
+// family. It reports false if the session doesn't exist, belongs to someone
+// else or has already ended.
+func endSession(ctx context.Context, cfg *config.Config, sessionID, userID uuid.UUID) (bool, error) {
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	rows, err := qtx.RevokeSession(ctx, database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+	if err := qtx.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// clientIP prefers the address the gateway saw; requests only reach us
+// through the gateway, which overwrites any client-supplied X-Forwarded-For.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userIDStr := r.Header.Get("X-User-ID")
+	if userIDStr == "" {
+		response.RespondWithError(w, http.StatusUnauthorized, "missing user ID", nil)
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "invalid user ID", err)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func handlerSessionsList(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type sessionResponse struct {
+		ID         uuid.UUID `json:"id"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		UserAgent  string    `json:"user_agent"`
+		IPAddress  string    `json:"ip_address"`
+		Current    bool      `json:"current"`
+	}
+
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := cfg.DB.ListActiveSessions(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't list sessions", err)
+		return
+	}
+
+	// The refresh cookie, when the client sends it, identifies this device
+	var currentID uuid.UUID
+	if cookie, err := r.Cookie("refresh_token"); err == nil {
+		if current, err := cfg.DB.GetRefreshTokenSession(r.Context(), cookie.Value); err == nil && current.UserID == userID {
+			currentID = current.FamilyID
+		}
+	}
+
+	out := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, sessionResponse{
+			ID:         s.ID,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			UserAgent:  s.UserAgent,
+			IPAddress:  s.IpAddress,
+			Current:    s.ID == currentID,
+		})
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handlerSessionDelete ends one of the user's sessions. Its refresh tokens
+// stop working, and so do access tokens issued under it once the gateway
+// next syncs revocations.
+func handlerSessionDelete(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	ended, err := endSession(r.Context(), cfg, sessionID, userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	if !ended {
+		response.RespondWithError(w, http.StatusNotFound, "Session not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerSessionsDeleteAll logs the user out everywhere, including the
+// device making the request.
+func handlerSessionsDeleteAll(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := revokeAllUserTokens(r.Context(), cfg, userID); err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
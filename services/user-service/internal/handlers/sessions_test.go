@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		forwarded  string
+		remoteAddr string
+		want       string
+	}{
+		{name: "forwarded by gateway", forwarded: "203.0.113.7", remoteAddr: "10.0.0.2:5000", want: "203.0.113.7"},
+		{name: "forwarded chain", forwarded: "203.0.113.7, 10.0.0.9", remoteAddr: "10.0.0.2:5000", want: "203.0.113.7"},
+		{name: "direct", remoteAddr: "198.51.100.4:5000", want: "198.51.100.4"},
+		{name: "no port", remoteAddr: "198.51.100.4", want: "198.51.100.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartSession(t *testing.T) {
+	userID := uuid.New()
+	d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+		switch q.name {
+		case "CreateSession":
+			now := time.Now()
+			return rowsOf(q.args[0], q.args[1], now, now, q.args[2], q.args[3], nil)
+		case "CreateRefreshToken":
+			now := time.Now()
+			return rowsOf(q.args[0], now, now, q.args[1], q.args[2], nil, q.args[3], nil)
+		}
+		return fakeResult{}
+	}}
+	cfg := newFakeConfig(t, d)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+	r.Header.Set("User-Agent", "ecom-cli/1.0")
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	sessionID, refreshToken, err := startSession(context.Background(), cfg, userID, r)
+	if err != nil {
+		t.Fatalf("startSession: %v", err)
+	}
+	if refreshToken == "" {
+		t.Error("startSession returned an empty refresh token")
+	}
+
+	want := []string{"BEGIN", "CreateSession", "CreateRefreshToken", "COMMIT"}
+	if got := d.ran(); !slices.Equal(got, want) {
+		t.Fatalf("ran %v, want %v", got, want)
+	}
+	session, _ := d.find("CreateSession")
+	if session.args[1] != userID.String() || session.args[2] != "ecom-cli/1.0" || session.args[3] != "203.0.113.7" {
+		t.Errorf("CreateSession args = %v", session.args)
+	}
+	token, _ := d.find("CreateRefreshToken")
+	if token.args[3] != sessionID.String() {
+		t.Errorf("refresh token family = %v, want session %s", token.args[3], sessionID)
+	}
+}
+
+func TestHandlerSessionsList(t *testing.T) {
+	userID := uuid.New()
+	current, other := uuid.New(), uuid.New()
+	now := time.Now().UTC().Truncate(time.Second)
+	d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+		switch q.name {
+		case "ListActiveSessions":
+			return fakeResult{
+				columns: make([]string, 7),
+				rows: [][]driver.Value{
+					{other.String(), userID.String(), now, now, "curl/8.0", "198.51.100.4", nil},
+					{current.String(), userID.String(), now, now, "ecom-cli/1.0", "203.0.113.7", nil},
+				},
+			}
+		case "GetRefreshTokenSession":
+			if q.args[0] == "this-device" {
+				return rowsOf(current.String(), userID.String())
+			}
+		}
+		return fakeResult{}
+	}}
+	cfg := newFakeConfig(t, d)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+	r.Header.Set("X-User-ID", userID.String())
+	r.AddCookie(&http.Cookie{Name: "refresh_token", Value: "this-device"})
+	rec := httptest.NewRecorder()
+	handlerSessionsList(cfg, rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var got []struct {
+		ID        uuid.UUID `json:"id"`
+		UserAgent string    `json:"user_agent"`
+		IPAddress string    `json:"ip_address"`
+		Current   bool      `json:"current"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(got))
+	}
+	if got[0].ID != other || got[0].Current {
+		t.Errorf("sessions[0] = %+v, want other device, not current", got[0])
+	}
+	if got[1].ID != current || !got[1].Current || got[1].UserAgent != "ecom-cli/1.0" || got[1].IPAddress != "203.0.113.7" {
+		t.Errorf("sessions[1] = %+v, want this device marked current", got[1])
+	}
+}
+
+func TestHandlerSessionsListRequiresUser(t *testing.T) {
+	cfg := newFakeConfig(t, &fakeDriver{})
+	rec := httptest.NewRecorder()
+	handlerSessionsList(cfg, rec, httptest.NewRequest(http.MethodGet, "/api/sessions", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", rec.Code)
+	}
+}
+
+func TestHandlerSessionDelete(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name       string
+		sessionID  string
+		revoked    int64
+		wantStatus int
+		wantRan    []string
+	}{
+		{
+			name:       "own active session",
+			sessionID:  uuid.NewString(),
+			revoked:    1,
+			wantStatus: http.StatusNoContent,
+			wantRan:    []string{"BEGIN", "RevokeSession", "RevokeRefreshTokenFamily", "COMMIT"},
+		},
+		{
+			// Someone else's session looks the same as one that doesn't exist
+			name:       "not found or not owned",
+			sessionID:  uuid.NewString(),
+			revoked:    0,
+			wantStatus: http.StatusNotFound,
+			wantRan:    []string{"BEGIN", "RevokeSession", "ROLLBACK"},
+		},
+		{
+			name:       "invalid ID",
+			sessionID:  "not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+			wantRan:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				if q.name == "RevokeSession" {
+					return fakeResult{affected: tt.revoked}
+				}
+				return fakeResult{}
+			}}
+			cfg := newFakeConfig(t, d)
+
+			r := httptest.NewRequest(http.MethodDelete, "/api/sessions/"+tt.sessionID, nil)
+			r.SetPathValue("sessionID", tt.sessionID)
+			r.Header.Set("X-User-ID", userID.String())
+			rec := httptest.NewRecorder()
+			handlerSessionDelete(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := d.ran(); !slices.Equal(got, tt.wantRan) {
+				t.Errorf("ran %v, want %v", got, tt.wantRan)
+			}
+			if q, ok := d.find("RevokeSession"); ok && q.args[1] != userID.String() {
+				t.Errorf("RevokeSession scoped to user %v, want %s", q.args[1], userID)
+			}
+		})
+	}
+}
+
+func TestHandlerSessionsDeleteAll(t *testing.T) {
+	userID := uuid.New()
+	d := &fakeDriver{}
+	cfg := newFakeConfig(t, d)
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/sessions", nil)
+	r.Header.Set("X-User-ID", userID.String())
+	rec := httptest.NewRecorder()
+	handlerSessionsDeleteAll(cfg, rec, r)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rec.Code)
+	}
+	want := []string{"RevokeUserTokensBefore", "RevokeUserSessions", "RevokeUserRefreshTokens"}
+	if got := d.ran(); !slices.Equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/response"
+)
+
+const maxDisplayNameLength = 100
+
+// userResponse is how every endpoint shows a user. database.User holds the
+// password hash, so it must never be written out directly.
+type userResponse struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	DisplayName   string    `json:"display_name"`
+	Phone         string    `json:"phone"`
+}
+
+func newUserResponse(u database.User) userResponse {
+	return userResponse{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerifiedAt.Valid,
+		DisplayName:   u.DisplayName,
+		Phone:         u.Phone,
+	}
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// value for a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// handlerProfileUpdate changes the caller's profile. Omitted fields keep
+// their current value.
+func handlerProfileUpdate(cfg *config.Config, w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		DisplayName *string `json:"display_name"`
+		Phone       *string `json:"phone"`
+	}
+
+	userID, ok := requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	displayName, phone := user.DisplayName, user.Phone
+	if params.DisplayName != nil {
+		displayName = strings.TrimSpace(*params.DisplayName)
+	}
+	if params.Phone != nil {
+		phone = strings.TrimSpace(*params.Phone)
+	}
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		response.RespondWithError(w, http.StatusBadRequest, "display_name must be at most 100 characters", nil)
+		return
+	}
+	if err := validatePhone(phone); err != nil {
+		response.RespondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	user, err = cfg.DB.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		ID:          userID,
+		DisplayName: displayName,
+		Phone:       phone,
+	})
+	if err != nil {
+		response.RespondWithError(w, http.StatusInternalServerError, "Couldn't update profile", err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/mailer"
+)
+
+// recordingMailer keeps every message it is asked to send.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) messages() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.sent...)
+}
+
+// checkUserJSON fails unless body is the public user representation.
+func checkUserJSON(t *testing.T, body []byte) {
+	t.Helper()
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{"id", "created_at", "updated_at", "email", "role", "email_verified", "display_name", "phone"}
+	for _, key := range keys {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("user JSON is missing %q: %s", key, body)
+		}
+	}
+	if len(fields) != len(keys) {
+		t.Errorf("user JSON has unexpected fields: %s", body)
+	}
+	if strings.Contains(string(body), "argon2id") {
+		t.Errorf("user JSON leaks the password hash: %s", body)
+	}
+}
+
+func TestNewUserResponse(t *testing.T) {
+	now := time.Now()
+	user := database.User{
+		ID:              uuid.New(),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Email:           "a@example.com",
+		HashedPassword:  "$argon2id$secret-hash",
+		Role:            "user",
+		EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+	}
+
+	body, err := json.Marshal(newUserResponse(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUserJSON(t, body)
+	if !newUserResponse(user).EmailVerified {
+		t.Error("EmailVerified = false for a verified user")
+	}
+}
+
+func TestHandlerUsersCreate(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		createErr  error
+		wantStatus int
+		wantMail   bool
+	}{
+		{
+			name:       "created",
+			body:       `{"email":"a@example.com","password":"correct horse"}`,
+			wantStatus: http.StatusCreated,
+			wantMail:   true,
+		},
+		{
+			name:       "duplicate email",
+			body:       `{"email":"a@example.com","password":"correct horse"}`,
+			createErr:  &pq.Error{Code: "23505", Constraint: "users_email_key"},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "other database error",
+			body:       `{"email":"a@example.com","password":"correct horse"}`,
+			createErr:  &pq.Error{Code: "23502"},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "missing password",
+			body:       `{"email":"a@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+				if q.name == "CreateUser" {
+					if tt.createErr != nil {
+						return fakeResult{err: tt.createErr}
+					}
+					return userRow(database.User{
+						ID:             uuid.New(),
+						Email:          q.args[0].(string),
+						HashedPassword: q.args[1].(string),
+						Role:           "user",
+					})
+				}
+				return fakeResult{}
+			}}
+			mail := &recordingMailer{}
+			cfg := newLoginConfig(t, d)
+			cfg.Mailer = mail
+
+			r := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handlerUsersCreate(cfg, rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusCreated {
+				checkUserJSON(t, rec.Body.Bytes())
+			}
+			if got := len(mail.messages()) > 0; got != tt.wantMail {
+				t.Errorf("sent verification mail = %v, want %v", got, tt.wantMail)
+			}
+		})
+	}
+}
+
+func TestHandlerGetUserByID(t *testing.T) {
+	userID := uuid.New()
+	d := &fakeDriver{respond: func(q fakeQuery) fakeResult {
+		if q.name == "GetUserByID" && q.args[0] == userID.String() {
+			return userRow(database.User{
+				ID:             userID,
+				Email:          "a@example.com",
+				HashedPassword: "$argon2id$secret-hash",
+				Role:           "user",
+			})
+		}
+		return fakeResult{}
+	}}
+	cfg := newFakeConfig(t, d)
+
+	for _, tt := range []struct {
+		id         string
+		wantStatus int
+	}{
+		{userID.String(), http.StatusOK},
+		{uuid.NewString(), http.StatusNotFound},
+		{"nope", http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/internal/users/"+tt.id, nil)
+		r.SetPathValue("userID", tt.id)
+		rec := httptest.NewRecorder()
+		handlerGetUserByID(cfg, rec, r)
+
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("GET %s: status = %d, want %d", tt.id, rec.Code, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusOK {
+			checkUserJSON(t, rec.Body.Bytes())
+		}
+	}
+}
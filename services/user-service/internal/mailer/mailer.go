@@ -0,0 +1,234 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email to users.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func validate(msg Message) error {
+	// Header values with line breaks could inject extra headers or recipients
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return errors.New("mail headers must not contain line breaks")
+	}
+	if msg.To == "" {
+		return errors.New("mail has no recipient")
+	}
+	return nil
+}
+
+// SMTPMailer sends mail through an SMTP relay.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends from the from address through the relay at addr
+// (host:port). username may be empty for relays that don't authenticate.
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return m.deliver(ctx, msg.To, b.String())
+}
+
+// deliver is smtp.SendMail with the whole exchange bounded by ctx.
+func (m *SMTPMailer) deliver(ctx context.Context, to, data string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := strings.Cut(m.addr, ":")
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// FileMailer writes each message to its own file in a directory, for
+// development and tests.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.txt", time.Now().UnixNano(), strings.NewReplacer("/", "_", "\\", "_").Replace(msg.To))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o644)
+}
+
+// LogMailer prints messages to the log instead of sending them, for
+// development.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	log.Printf("mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// Async hands messages to another Mailer in the background, so requests
+// neither wait on the mail server nor reveal through their timing whether
+// mail was sent. Delivery errors are logged.
+type Async struct {
+	next    Mailer
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+func NewAsync(next Mailer, timeout time.Duration) *Async {
+	return &Async{next: next, timeout: timeout}
+}
+
+func (a *Async) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	a.SendDeferred(ctx, func(ctx context.Context) (Message, bool, error) {
+		return msg, true, nil
+	})
+	return nil
+}
+
+// SendDeferred composes a message and sends it, both in the background.
+func (a *Async) SendDeferred(ctx context.Context, compose Deferred) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		// Outlive the request that queued the message
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.timeout)
+		defer cancel()
+		if err := sendDeferred(sendCtx, a.next, compose); err != nil {
+			log.Printf("failed to send mail: %v", err)
+		}
+	}()
+}
+
+// Deferred builds a message just before it is sent, for mail whose
+// recipient has to be looked up first. ok is false when there is nothing
+// to send.
+type Deferred func(ctx context.Context) (msg Message, ok bool, err error)
+
+// SendDeferred composes and sends mail in the background if m is an Async,
+// so a request does the same work whether or not there turns out to be
+// anything to send. Other mailers compose and send in place.
+func SendDeferred(ctx context.Context, m Mailer, compose Deferred) error {
+	if a, ok := m.(*Async); ok {
+		a.SendDeferred(ctx, compose)
+		return nil
+	}
+	return sendDeferred(ctx, m, compose)
+}
+
+func sendDeferred(ctx context.Context, m Mailer, compose Deferred) error {
+	msg, ok, err := compose(ctx)
+	if err != nil || !ok {
+		return err
+	}
+	if err := m.Send(ctx, msg); err != nil {
+		return fmt.Errorf("sending %q: %w", msg.Subject, err)
+	}
+	return nil
+}
+
+// Wait blocks until queued messages have been handed off or ctx is done.
+func (a *Async) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time to run database queries, by sqlc query name.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"query"})
+
+	dbErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Database queries that returned an error, by sqlc query name.",
+	}, []string{"query"})
+)
+
+// DBTX matches the interface sqlc generates, so an instrumented DB can be
+// passed to database.New.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+// DB times every query sent through it.
+type DB struct {
+	db DBTX
+}
+
+func InstrumentDB(db DBTX) *DB {
+	return &DB{db: db}
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := d.db.ExecContext(ctx, query, args...)
+	observeQuery(query, start, err)
+	return result, err
+}
+
+func (d *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	start := time.Now()
+	stmt, err := d.db.PrepareContext(ctx, query)
+	observeQuery(query, start, err)
+	return stmt, err
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	observeQuery(query, start, err)
+	return rows, err
+}
+
+// QueryRowContext can only be timed; its error surfaces later on Scan.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.db.QueryRowContext(ctx, query, args...)
+	observeQuery(query, start, nil)
+	return row
+}
+
+func observeQuery(query string, start time.Time, err error) {
+	name := queryName(query)
+	dbDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		dbErrors.WithLabelValues(name).Inc()
+	}
+}
+
+// queryName extracts the name from sqlc's "-- name: GetUser :one" header.
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	if i := strings.IndexAny(rest, " \n"); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
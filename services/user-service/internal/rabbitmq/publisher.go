@@ -0,0 +1,101 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/requestid"
+)
+
+type Publisher struct {
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	exchange string
+}
+
+func NewPublisher(url string) (*Publisher, error) {
+	fmt.Println("user-service connecting to RabbitMQ")
+
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to RabbitMQ: %w", err)
+	}
+	fmt.Println("user-service connected to RabbitMQ")
+
+	publishCh, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel: %w", err)
+	}
+
+	exchangeName := "users"
+	err = publishCh.ExchangeDeclare(
+		exchangeName,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not declare exchange: %w", err)
+	}
+	return &Publisher{
+		conn:     conn,
+		channel:  publishCh,
+		exchange: exchangeName,
+	}, nil
+}
+
+// Publish sends event to the exchange, stamping the request ID from ctx so
+// consumers can correlate the message with the request that caused it.
+func (p *Publisher) Publish(ctx context.Context, routingKey string, event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cant marshal the struct: %w", err)
+	}
+
+	requestID := requestid.FromContext(ctx)
+	err = p.channel.PublishWithContext(
+		ctx,
+		p.exchange,
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: requestID,
+			Headers:       amqp.Table{"x-request-id": requestID},
+			Body:          data,
+		},
+	)
+	if err != nil {
+		metrics.PublishFailures.WithLabelValues(routingKey).Inc()
+		return fmt.Errorf("cant publish user event: %w", err)
+	}
+	metrics.MessagesPublished.WithLabelValues(routingKey).Inc()
+	log.Printf("request_id=%s published %s", requestID, routingKey)
+	return nil
+}
+
+// Ready reports an error if the RabbitMQ connection or channel has closed.
+func (p *Publisher) Ready() error {
+	if p.conn.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if p.channel.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
+	return nil
+}
+
+func (p *Publisher) Close() {
+	p.channel.Close()
+	p.conn.Close()
+}
@@ -1,29 +1,67 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/auth"
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/config"
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/database"
 	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/handlers"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/internalauth"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/mailer"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/metrics"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/rabbitmq"
+	"github.com/herodragmon/scalable-ecommerce/services/user-service/internal/requestid"
 )
 
 func main() {
 	godotenv.Load()
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	platform := os.Getenv("PLATFORM")
-	secretKey := os.Getenv("SECRET_KEY")
 	dbURL := os.Getenv("DB_URL")
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8081"
 	}
+	shutdownTimeout := getDurationOrDefault("SHUTDOWN_TIMEOUT", 15*time.Second)
+	internalAuthKey := os.Getenv("INTERNAL_AUTH_KEY")
+	if internalAuthKey == "" {
+		log.Fatal("INTERNAL_AUTH_KEY is not set")
+	}
+	signer := internalauth.NewSigner(internalAuthKey, "user-service")
+	rabbitmqURL := os.Getenv("RABBITMQ_URL")
+	if rabbitmqURL == "" {
+		log.Fatal("RABBITMQ_URL is not set")
+	}
+
+	jwtKeys, err := loadJWTKeys(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_ACTIVE_KID"), platform)
+	if err != nil {
+		log.Fatalf("failed to load JWT signing keys: %v", err)
+	}
+	log.Printf("Signing access tokens with key %s", jwtKeys.ActiveKID())
+	auth.WarmDummyHash()
+
+	mail, err := newMailer(platform)
+	if err != nil {
+		log.Fatalf("failed to set up mailer: %v", err)
+	}
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -34,12 +72,26 @@ func main() {
 		log.Fatalf("failed to ping database: %v", err)
 	}
 
-	dbQueries := database.New(db)
+	dbQueries := database.New(metrics.InstrumentDB(db))
+
+	publisher, err := rabbitmq.NewPublisher(rabbitmqURL)
+	if err != nil {
+		log.Fatalf("failed to publish exchange: %v", err)
+	}
 
 	cfg := &config.Config{
-		DB:        dbQueries,
-		Platform:  platform,
-		JWTSecret: secretKey,
+		DB:       dbQueries,
+		DBConn:   db,
+		Platform: platform,
+		JWTKeys:  jwtKeys,
+		Login: config.LoginPolicy{
+			MaxFailures:   getIntOrDefault("LOGIN_MAX_FAILURES", 5),
+			IPMaxFailures: getIntOrDefault("LOGIN_IP_MAX_FAILURES", 50),
+			Lockout:       getDurationOrDefault("LOGIN_LOCKOUT", 15*time.Minute),
+		},
+		Mailer:           mail,
+		MFARequiredRoles: parseRoles(os.Getenv("MFA_REQUIRED_ROLES")),
+		Publisher:        publisher,
 	}
 
 	mux := http.NewServeMux()
@@ -47,9 +99,118 @@ func main() {
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: requestid.Middleware(metrics.Middleware(mux, internalauth.Middleware(signer, mux))),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("User service starting on port %s", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("server failed: %v", err)
+	case <-ctx.Done():
 	}
+	stop()
+	log.Printf("User service shutting down")
 
-	log.Printf("User service starting on port %s", port)
-	log.Fatal(server.ListenAndServe())
+	// Drain HTTP first so in-flight requests can still use the dependencies
+	// closed below
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to drain HTTP server: %v", err)
+	}
+	if err := mail.Wait(shutdownCtx); err != nil {
+		log.Printf("failed to send queued mail: %v", err)
+	}
+	publisher.Close()
+	db.Close()
+	log.Printf("User service stopped")
+}
+
+// loadJWTKeys reads signing keys from dir. Development runs without a key
+// directory get a throwaway key.
+func loadJWTKeys(dir, activeKID, platform string) (*auth.KeySet, error) {
+	if dir == "" {
+		if platform != "dev" {
+			return nil, errors.New("JWT_KEYS_DIR is not set")
+		}
+		log.Printf("JWT_KEYS_DIR is not set, generating an ephemeral signing key")
+		return auth.GenerateKeySet()
+	}
+	return auth.LoadKeySet(dir, activeKID)
+}
+
+// newMailer picks the mail transport from MAILER: "smtp", "file" (one file
+// per message in MAIL_DIR) or "log". Sending happens in the background.
+func newMailer(platform string) (*mailer.Async, error) {
+	var m mailer.Mailer
+	switch kind := getEnvOrDefault("MAILER", "log"); kind {
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		from := os.Getenv("MAIL_FROM")
+		if addr == "" || from == "" {
+			return nil, errors.New("SMTP_ADDR and MAIL_FROM must be set for the smtp mailer")
+		}
+		m = mailer.NewSMTPMailer(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	case "file":
+		fm, err := mailer.NewFileMailer(getEnvOrDefault("MAIL_DIR", "mail"))
+		if err != nil {
+			return nil, err
+		}
+		m = fm
+	case "log":
+		if platform != "dev" {
+			log.Printf("MAILER is log: emails will be written to the log, not sent")
+		}
+		m = mailer.LogMailer{}
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", kind)
+	}
+	return mailer.NewAsync(m, getDurationOrDefault("MAIL_TIMEOUT", 30*time.Second)), nil
+}
+
+// parseRoles reads a comma-separated list of roles.
+func parseRoles(value string) map[string]bool {
+	roles := make(map[string]bool)
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles[role] = true
+		}
+	}
+	return roles
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid duration for %s: %v", key, err)
+	}
+	return d
+}
+
+func getIntOrDefault(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid integer for %s: %v", key, err)
+	}
+	return n
 }
@@ -0,0 +1,72 @@
+-- name: ListAddresses :many
+SELECT * FROM addresses
+WHERE user_id = $1
+ORDER BY created_at, id;
+
+-- name: GetAddress :one
+SELECT * FROM addresses
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: CountAddresses :one
+SELECT COUNT(*) FROM addresses
+WHERE user_id = $1;
+
+-- name: CreateAddress :one
+INSERT INTO addresses (
+    user_id,
+    label,
+    recipient_name,
+    line1,
+    line2,
+    city,
+    region,
+    postal_code,
+    country,
+    phone,
+    is_default_shipping,
+    is_default_billing
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+)
+RETURNING *;
+
+-- name: UpdateAddress :one
+UPDATE addresses
+SET
+    label = $3,
+    recipient_name = $4,
+    line1 = $5,
+    line2 = $6,
+    city = $7,
+    region = $8,
+    postal_code = $9,
+    country = $10,
+    phone = $11,
+    is_default_shipping = $12,
+    is_default_billing = $13,
+    updated_at = now()
+WHERE id = $1
+  AND user_id = $2
+RETURNING *;
+
+-- name: DeleteAddress :execrows
+DELETE FROM addresses
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: ClearDefaultShippingAddress :exec
+UPDATE addresses
+SET
+    is_default_shipping = false,
+    updated_at = now()
+WHERE user_id = $1
+  AND is_default_shipping;
+
+-- name: ClearDefaultBillingAddress :exec
+UPDATE addresses
+SET
+    is_default_billing = false,
+    updated_at = now()
+WHERE user_id = $1
+  AND is_default_billing;
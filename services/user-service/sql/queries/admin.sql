@@ -1,5 +1,13 @@
 -- name: Reset :exec
 TRUNCATE TABLE
+    addresses,
+    audit_log,
+    login_failures,
+    mfa_recovery_codes,
     refresh_tokens,
+    revoked_tokens,
+    sessions,
+    user_mfa,
+    user_token_revocations,
     users
 RESTART IDENTITY CASCADE;
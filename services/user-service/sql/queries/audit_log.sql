@@ -0,0 +1,16 @@
+-- name: CreateAuditLog :exec
+INSERT INTO audit_log (
+    id,
+    created_at,
+    action,
+    user_id,
+    actor_id,
+    details
+) VALUES (
+    gen_random_uuid(),
+    now(),
+    $1,
+    $2,
+    $3,
+    $4
+);
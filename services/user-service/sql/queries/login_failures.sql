@@ -0,0 +1,36 @@
+-- name: GetLoginFailures :many
+SELECT scope, subject, failures, last_failure_at, locked_until
+FROM login_failures
+WHERE (scope = 'email' AND subject = sqlc.arg(email))
+   OR (scope = 'ip' AND subject = sqlc.arg(ip));
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures (
+    scope,
+    subject,
+    failures,
+    last_failure_at
+) VALUES (
+    sqlc.arg(scope),
+    sqlc.arg(subject),
+    1,
+    sqlc.arg(failed_at)
+)
+ON CONFLICT (scope, subject) DO UPDATE SET
+    failures = CASE
+        WHEN login_failures.last_failure_at < sqlc.arg(reset_before) THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING failures;
+
+-- name: LockLogin :exec
+UPDATE login_failures
+SET locked_until = $3
+WHERE scope = $1
+  AND subject = $2;
+
+-- name: ClearLoginFailures :exec
+DELETE FROM login_failures
+WHERE scope = $1
+  AND subject = $2;
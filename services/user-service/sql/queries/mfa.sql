@@ -0,0 +1,75 @@
+-- name: GetUserMFA :one
+SELECT user_id, totp_secret, enabled_at, last_used_step, created_at, updated_at
+FROM user_mfa
+WHERE user_id = $1;
+
+-- name: StartTOTPEnrollment :one
+INSERT INTO user_mfa (
+    user_id,
+    totp_secret,
+    created_at,
+    updated_at
+) VALUES (
+    $1,
+    $2,
+    now(),
+    now()
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    totp_secret = EXCLUDED.totp_secret,
+    last_used_step = 0,
+    updated_at = now()
+WHERE user_mfa.enabled_at IS NULL
+RETURNING user_id, totp_secret, enabled_at, last_used_step, created_at, updated_at;
+
+-- name: EnableTOTP :execrows
+UPDATE user_mfa
+SET
+    enabled_at = now(),
+    last_used_step = $2,
+    updated_at = now()
+WHERE user_id = $1
+  AND enabled_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_mfa
+SET
+    last_used_step = $2,
+    updated_at = now()
+WHERE user_id = $1
+  AND enabled_at IS NOT NULL
+  AND last_used_step < $2;
+
+-- name: DeleteUserMFA :exec
+DELETE FROM user_mfa
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO mfa_recovery_codes (
+    id,
+    user_id,
+    code_hash,
+    created_at
+) VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    now()
+);
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = now()
+WHERE user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*)
+FROM mfa_recovery_codes
+WHERE user_id = $1
+  AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
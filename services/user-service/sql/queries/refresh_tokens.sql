@@ -4,15 +4,35 @@ INSERT INTO refresh_tokens (
     created_at,
     updated_at,
     user_id,
-    expires_at
+    expires_at,
+    family_id
 ) VALUES (
     $1,
     now(),
     now(),
     $2,
-    $3
+    $3,
+    $4
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at;
+RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by;
+
+-- name: GetRecentRefreshTokenSuccessor :one
+-- The token that replaced $1, if $1 was rotated in the last grace_seconds
+-- and its replacement is still the family's live token
+SELECT next.token
+FROM refresh_tokens prev
+JOIN refresh_tokens next ON next.token = prev.replaced_by
+WHERE prev.token = $1
+  AND prev.revoked_at > now() - sqlc.arg(grace_seconds)::int * interval '1 second'
+  AND next.revoked_at IS NULL
+  AND next.expires_at > now()
+FOR UPDATE OF next;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, replaced_by
+FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE;
 
 -- name: GetUserByRefreshToken :one
 SELECT
@@ -21,18 +41,38 @@ SELECT
     u.updated_at,
     u.email,
     u.hashed_password,
-    u.role
+    u.role,
+    u.email_verified_at,
+    u.disabled_at,
+    u.display_name,
+    u.phone
 FROM refresh_tokens rt
 JOIN users u ON u.id = rt.user_id
 WHERE rt.token = $1
   AND rt.revoked_at IS NULL
   AND rt.expires_at > now();
 
+-- name: RotateRefreshToken :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = now(),
+    updated_at = now(),
+    replaced_by = $2
+WHERE token = $1
+  AND revoked_at IS NULL;
 
--- name: RevokeRefreshToken :exec
+-- name: RevokeRefreshTokenFamily :exec
 UPDATE refresh_tokens
 SET
     revoked_at = now(),
     updated_at = now()
-WHERE token = $1
+WHERE family_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET
+    revoked_at = now(),
+    updated_at = now()
+WHERE user_id = $1
   AND revoked_at IS NULL;
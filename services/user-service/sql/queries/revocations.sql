@@ -0,0 +1,56 @@
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_tokens (
+    jti,
+    user_id,
+    expires_at,
+    revoked_at
+) VALUES (
+    $1,
+    $2,
+    $3,
+    now()
+)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: RevokeUserTokensBefore :exec
+INSERT INTO user_token_revocations (
+    user_id,
+    revoked_before,
+    updated_at
+) VALUES (
+    $1,
+    $2,
+    now()
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before),
+    updated_at = now();
+
+-- name: GetRevocationFeedTime :one
+SELECT now()::timestamptz AS as_of;
+
+-- name: ListRevokedTokensSince :many
+SELECT jti, user_id, expires_at, revoked_at
+FROM revoked_tokens
+WHERE revoked_at > $1
+  AND expires_at > now()
+ORDER BY revoked_at;
+
+-- name: ListUserRevocationsSince :many
+SELECT user_id, revoked_before, updated_at
+FROM user_token_revocations
+WHERE updated_at > $1
+ORDER BY updated_at;
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1 FROM revoked_tokens WHERE jti = $1
+) OR EXISTS (
+    SELECT 1 FROM user_token_revocations
+    WHERE user_id = $2
+      AND revoked_before > $3
+) OR EXISTS (
+    SELECT 1 FROM sessions
+    WHERE id = $4
+      AND revoked_at IS NOT NULL
+);
@@ -0,0 +1,65 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+    id,
+    user_id,
+    created_at,
+    last_used_at,
+    user_agent,
+    ip_address
+) VALUES (
+    $1,
+    $2,
+    now(),
+    now(),
+    $3,
+    $4
+)
+RETURNING id, user_id, created_at, last_used_at, user_agent, ip_address, revoked_at;
+
+-- name: ListActiveSessions :many
+SELECT s.id, s.user_id, s.created_at, s.last_used_at, s.user_agent, s.ip_address, s.revoked_at
+FROM sessions s
+WHERE s.user_id = $1
+  AND s.revoked_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens rt
+    WHERE rt.family_id = s.id
+      AND rt.revoked_at IS NULL
+      AND rt.expires_at > now()
+  )
+ORDER BY s.last_used_at DESC;
+
+-- name: GetRefreshTokenSession :one
+SELECT family_id, user_id
+FROM refresh_tokens
+WHERE token = $1;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = now()
+WHERE id = $1;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = now()
+WHERE id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = now()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: ListRevokedSessionsSince :many
+SELECT id, user_id, revoked_at
+FROM sessions
+WHERE revoked_at > $1
+ORDER BY revoked_at;
+
+-- name: ListSessions :many
+SELECT id, user_id, created_at, last_used_at, user_agent, ip_address, revoked_at
+FROM sessions
+WHERE user_id = $1
+ORDER BY created_at DESC;
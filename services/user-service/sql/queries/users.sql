@@ -16,7 +16,13 @@ RETURNING
     id,
     created_at,
     updated_at,
-    email;
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone;
 
 -- name: GetUserByEmail :one
 SELECT
@@ -25,11 +31,109 @@ SELECT
     updated_at,
     email,
     hashed_password,
-    role
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
 FROM users
 WHERE email = $1;
 
 -- name: GetUserByID :one
-SELECT id, created_at, updated_at, email
+SELECT
+    id,
+    created_at,
+    updated_at,
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
 FROM users
 WHERE id = $1;
+
+-- name: MarkEmailVerified :exec
+UPDATE users
+SET
+    email_verified_at = now(),
+    updated_at = now()
+WHERE id = $1
+  AND email_verified_at IS NULL;
+
+-- name: UpdateUserPassword :execrows
+UPDATE users
+SET
+    hashed_password = sqlc.arg(new_hashed_password),
+    updated_at = now()
+WHERE id = sqlc.arg(id)
+  AND hashed_password = sqlc.arg(old_hashed_password);
+
+-- name: ListUsers :many
+SELECT
+    id,
+    created_at,
+    updated_at,
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone
+FROM users
+WHERE email ILIKE sqlc.arg(pattern)
+ORDER BY created_at DESC, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+WHERE email ILIKE sqlc.arg(pattern);
+
+-- name: UpdateUserRole :exec
+UPDATE users
+SET
+    role = $2,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: DisableUser :execrows
+UPDATE users
+SET
+    disabled_at = now(),
+    updated_at = now()
+WHERE id = $1
+  AND disabled_at IS NULL;
+
+-- name: EnableUser :execrows
+UPDATE users
+SET
+    disabled_at = NULL,
+    updated_at = now()
+WHERE id = $1
+  AND disabled_at IS NOT NULL;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET
+    display_name = $2,
+    phone = $3,
+    updated_at = now()
+WHERE id = $1
+RETURNING
+    id,
+    created_at,
+    updated_at,
+    email,
+    hashed_password,
+    role,
+    email_verified_at,
+    disabled_at,
+    display_name,
+    phone;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
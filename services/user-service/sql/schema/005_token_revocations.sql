@@ -0,0 +1,23 @@
+-- +goose Up
+-- Individually revoked access tokens, kept until they would have expired
+CREATE TABLE revoked_tokens (
+    jti UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_tokens_revoked_at_idx ON revoked_tokens (revoked_at);
+
+-- Every access token a user was issued before revoked_before is revoked
+CREATE TABLE user_token_revocations (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX user_token_revocations_updated_at_idx ON user_token_revocations (updated_at);
+
+-- +goose Down
+DROP TABLE user_token_revocations;
+DROP TABLE revoked_tokens;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Failed logins counted per account (scope 'email') and per client address
+-- (scope 'ip'). Unknown emails are tracked too so they behave like real ones.
+CREATE TABLE login_failures (
+    scope TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    PRIMARY KEY (scope, subject)
+);
+
+CREATE TABLE audit_log (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    details JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, created_at);
+
+-- +goose Down
+DROP TABLE audit_log;
+DROP TABLE login_failures;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT users_role_check;
+
+ALTER TABLE users DROP COLUMN disabled_at;
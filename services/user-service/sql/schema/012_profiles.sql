@@ -0,0 +1,35 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN phone TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE addresses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    label TEXT NOT NULL DEFAULT '',
+    recipient_name TEXT NOT NULL,
+    line1 TEXT NOT NULL,
+    line2 TEXT NOT NULL DEFAULT '',
+    city TEXT NOT NULL,
+    region TEXT NOT NULL DEFAULT '',
+    postal_code TEXT NOT NULL,
+    country CHAR(2) NOT NULL,
+    phone TEXT NOT NULL DEFAULT '',
+    is_default_shipping BOOLEAN NOT NULL DEFAULT false,
+    is_default_billing BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_addresses_user_id ON addresses(user_id);
+
+-- At most one default of each kind per user
+CREATE UNIQUE INDEX idx_addresses_default_shipping ON addresses(user_id) WHERE is_default_shipping;
+CREATE UNIQUE INDEX idx_addresses_default_billing ON addresses(user_id) WHERE is_default_billing;
+
+-- +goose Down
+DROP TABLE addresses;
+
+ALTER TABLE users
+    DROP COLUMN phone,
+    DROP COLUMN display_name;
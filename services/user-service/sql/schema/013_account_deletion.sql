@@ -0,0 +1,16 @@
+-- +goose Up
+-- Revocations outlive deleted accounts so the gateway still learns that
+-- their access tokens are no longer valid
+ALTER TABLE revoked_tokens DROP CONSTRAINT revoked_tokens_user_id_fkey;
+ALTER TABLE user_token_revocations DROP CONSTRAINT user_token_revocations_user_id_fkey;
+
+-- +goose Down
+DELETE FROM revoked_tokens WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM user_token_revocations WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE user_token_revocations
+    ADD CONSTRAINT user_token_revocations_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE revoked_tokens
+    ADD CONSTRAINT revoked_tokens_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
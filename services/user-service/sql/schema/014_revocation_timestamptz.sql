@@ -0,0 +1,25 @@
+-- +goose Up
+-- The revocation feed is read with the database clock and polled with times
+-- from it, so revocation times carry a zone. Existing values are UTC.
+ALTER TABLE revoked_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ USING revoked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_token_revocations
+    ALTER COLUMN revoked_before TYPE TIMESTAMPTZ USING revoked_before AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE sessions
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ USING revoked_at AT TIME ZONE 'UTC';
+
+-- +goose Down
+ALTER TABLE sessions
+    ALTER COLUMN revoked_at TYPE TIMESTAMP USING revoked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_token_revocations
+    ALTER COLUMN updated_at TYPE TIMESTAMP USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_before TYPE TIMESTAMP USING revoked_before AT TIME ZONE 'UTC';
+
+ALTER TABLE revoked_tokens
+    ALTER COLUMN revoked_at TYPE TIMESTAMP USING revoked_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMP USING expires_at AT TIME ZONE 'UTC';